@@ -0,0 +1,126 @@
+// Package api serves the extracted profile as plain JSON for clients that do
+// not want to query RDF. The endpoints are described by the OpenAPI document
+// served at /openapi.json.
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/recommend"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+// EntitySummary is an entry of the entity listing.
+type EntitySummary struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// Handler serves the JSON endpoints, the graph is fetched for every request
+// so it can be replaced while serving.
+func Handler(current func() *graph.Graph, rules []recommend.Rule) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	})
+	mux.HandleFunc("/entities", func(w http.ResponseWriter, r *http.Request) {
+		g := current()
+		entities := []EntitySummary{}
+		for _, name := range EntityNames(g) {
+			e, _ := FindEntity(g, name)
+			s := EntitySummary{Name: name, Columns: []string{}}
+			for _, c := range e.Columns {
+				s.Columns = append(s.Columns, c.Name)
+			}
+			entities = append(entities, s)
+		}
+		writeJSON(w, entities)
+	})
+	mux.HandleFunc("/entities/", func(w http.ResponseWriter, r *http.Request) {
+		// entities/{name} or entities/{name}/columns/{col}, the name of a
+		// JSON path holds slashes of its own
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.EscapedPath(), "/entities/"), "/", 3)
+		for i, p := range parts {
+			if unescaped, err := url.PathUnescape(p); err == nil {
+				parts[i] = unescaped
+			}
+		}
+		g := current()
+		switch {
+		case len(parts) == 1 && parts[0] != "":
+			if e, ok := FindEntity(g, parts[0]); ok {
+				writeJSON(w, e)
+				return
+			}
+		case len(parts) == 3 && parts[1] == "columns":
+			if c, ok := FindColumn(g, parts[0], parts[2]); ok {
+				writeJSON(w, c)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/relationships", func(w http.ResponseWriter, r *http.Request) {
+		entity := r.FormValue("entity")
+		kind := r.FormValue("kind")
+		rels := []Relationship{}
+		for _, rel := range Relationships(current()) {
+			if (kind == "" || rel.Kind == kind) && (entity == "" || rel.involves(entity)) {
+				rels = append(rels, rel)
+			}
+		}
+		writeJSON(w, rels)
+	})
+	mux.HandleFunc("/recommendations", func(w http.ResponseWriter, r *http.Request) {
+		limit, err := strconv.Atoi(r.FormValue("limit"))
+		if r.FormValue("limit") != "" && (err != nil || limit < 0) {
+			http.Error(w, "limit must be a non negative integer", http.StatusBadRequest)
+			return
+		}
+		entity := r.FormValue("entity")
+		recs := []recommend.Recommendation{}
+		for _, rec := range recommend.Recommend(current(), rules) {
+			if entity == "" || rec.Entity == entity {
+				recs = append(recs, rec)
+			}
+		}
+		if limit > 0 && len(recs) > limit {
+			recs = recs[:limit]
+		}
+		writeJSON(w, recs)
+	})
+	return mux
+}
+
+// involves reports whether a relationship is within or points at entity.
+func (r Relationship) involves(entity string) bool {
+	if r.Entity == entity || r.ReferencedEntity == entity {
+		return true
+	}
+	for _, level := range r.Levels {
+		for _, c := range level {
+			if strings.HasPrefix(c, entity+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/recommend"
+)
+
+const mondial = `<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/name> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/country> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/population> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasKey> <http://dooodle/entity/city/column/name> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasKey> <http://dooodle/entity/city/column/country> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasCompoundKey> <http://dooodle/entity/city/compound/country/name> .
+<http://dooodle/entity/city/compound/country/name> <http://dooodle/predicate/hasStrongKey> <http://dooodle/entity/city/column/country> .
+<http://dooodle/entity/city/compound/country/name> <http://dooodle/predicate/hasWeakKey> <http://dooodle/entity/city/column/name> .
+<http://dooodle/entity/city/column/population> <http://dooodle/predicate/hasDataType> <http://dooodle/dataType/int4> .
+<http://dooodle/entity/city/column/population> <http://dooodle/predicate/hasDimension> <http://dooodle/dimension/scalar> .
+<http://dooodle/entity/city/column/population> <http://dooodle/predicate/numDistinct> "2900"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://dooodle/entity/city/column/country> <http://dooodle/predicate/numDistinct> "240"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://dooodle/entity/city/column/name> <http://dooodle/predicate/numDistinct> "3000"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasOne2ManyKey> <http://dooodle/entity/city/one2many/country/name> .
+<http://dooodle/entity/city/one2many/country/name> <http://dooodle/predicate/hasOneKey> <http://dooodle/entity/city/column/country> .
+<http://dooodle/entity/city/one2many/country/name> <http://dooodle/predicate/hasManyKey> <http://dooodle/entity/city/column/name> .
+<http://dooodle/entity/country> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/country/column/code> .
+`
+
+func get(t *testing.T, h http.Handler, path string, v interface{}) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code == http.StatusOK && v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestHandler(t *testing.T) {
+	g, err := graph.Load(strings.NewReader(mondial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(func() *graph.Graph { return g }, recommend.DefaultRules())
+
+	entities := []EntitySummary{}
+	get(t, h, "/entities", &entities)
+	if len(entities) != 2 || entities[0].Name != "city" || len(entities[0].Columns) != 3 {
+		t.Errorf("unexpected entities %+v", entities)
+	}
+
+	e := Entity{}
+	get(t, h, "/entities/city", &e)
+	if len(e.CompoundKeys) != 1 || e.CompoundKeys[0].Strong != "country" || e.CompoundKeys[0].Weak != "name" {
+		t.Errorf("unexpected compound keys %+v", e.CompoundKeys)
+	}
+
+	c := Column{}
+	get(t, h, "/entities/city/columns/population", &c)
+	if c.DataType != "int4" || c.Dimension != "scalar" || c.NumDistinct == nil || *c.NumDistinct != 2900 {
+		t.Errorf("unexpected column %+v", c)
+	}
+	if code := get(t, h, "/entities/city/columns/area", nil); code != http.StatusNotFound {
+		t.Errorf("wanted 404 for an unknown column got %d", code)
+	}
+
+	rels := []Relationship{}
+	get(t, h, "/relationships?kind=oneToMany&entity=city", &rels)
+	if len(rels) != 1 || rels[0].One != "country" || rels[0].Many != "name" {
+		t.Errorf("unexpected relationships %+v", rels)
+	}
+
+	recs := []recommend.Recommendation{}
+	get(t, h, "/recommendations?limit=1", &recs)
+	if len(recs) != 1 || recs[0].Entity != "city" {
+		t.Errorf("unexpected recommendations %+v", recs)
+	}
+
+	doc := map[string]interface{}{}
+	get(t, h, "/openapi.json", &doc)
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("unexpected OpenAPI document %v", doc["openapi"])
+	}
+}
+
+const events = `<http://dooodle/entity/event> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/event/column/id> .
+<http://dooodle/entity/event> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/event/column/payload> .
+<http://dooodle/entity/event> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/event/column/level> .
+<http://dooodle/entity/event> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/event/column/payload/-%3Ecountry> .
+<http://dooodle/entity/event> <http://dooodle/predicate/hasEntityKind> <http://dooodle/kind/table> .
+<http://dooodle/entity/event> <http://www.w3.org/2000/01/rdf-schema#comment> "Audit events" .
+<http://dooodle/entity/event> <http://dooodle/predicate/hasCandidateKey> <http://dooodle/entity/event/candidate/id> .
+<http://dooodle/entity/event/candidate/id> <http://dooodle/predicate/isDeclared> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+<http://dooodle/entity/event> <http://dooodle/predicate/partitionStrategy> "range" .
+<http://dooodle/entity/event> <http://dooodle/predicate/hasPartitionKey> <http://dooodle/entity/event/column/id> .
+<http://dooodle/entity/event> <http://dooodle/predicate/hasCheck> "CHECK (id > 0)" .
+<http://dooodle/entity/event/column/id> <http://dooodle/predicate/hasCheck> "CHECK (id > 0)" .
+<http://dooodle/entity/event/column/id> <http://dooodle/predicate/notNull> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+<http://dooodle/entity/event/column/level> <http://dooodle/predicate/hasDataType> <http://dooodle/dataType/level> .
+<http://dooodle/dataType/level> <http://dooodle/predicate/hasTypeKind> <http://dooodle/typeKind/enum> .
+<http://dooodle/dataType/level> <http://dooodle/predicate/hasLabel> <http://dooodle/dataType/level/label/2> .
+<http://dooodle/dataType/level/label/2> <http://dooodle/predicate/labelOrder> "2"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://dooodle/dataType/level/label/2> <http://dooodle/predicate/value> "error" .
+<http://dooodle/dataType/level> <http://dooodle/predicate/hasLabel> <http://dooodle/dataType/level/label/1> .
+<http://dooodle/dataType/level/label/1> <http://dooodle/predicate/labelOrder> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://dooodle/dataType/level/label/1> <http://dooodle/predicate/value> "info" .
+<http://dooodle/entity/event/column/payload> <http://dooodle/predicate/hasJSONPath> <http://dooodle/entity/event/column/payload/-%3Ecountry> .
+<http://dooodle/entity/event/column/payload/-%3Ecountry> <http://dooodle/predicate/jsonPath> "$.country" .
+<http://dooodle/entity/event/column/payload/-%3Ecountry> <http://dooodle/predicate/jsonValueType> "string" .
+`
+
+// TestHandlerSchema checks the constraints, types and JSON paths of an
+// entity are served, and a JSON path is found with its slashes.
+func TestHandlerSchema(t *testing.T) {
+	g, err := graph.Load(strings.NewReader(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(func() *graph.Graph { return g }, recommend.DefaultRules())
+
+	e := Entity{}
+	get(t, h, "/entities/event", &e)
+	if e.Kind != "table" || e.Comment != "Audit events" || len(e.Checks) != 1 {
+		t.Errorf("unexpected entity %+v", e)
+	}
+	if len(e.CandidateKeys) != 1 || !e.CandidateKeys[0].Declared || e.CandidateKeys[0].Columns[0] != "id" {
+		t.Errorf("unexpected candidate keys %+v", e.CandidateKeys)
+	}
+	if e.Partitioning == nil || e.Partitioning.Strategy != "range" || len(e.Partitioning.Key) != 1 {
+		t.Errorf("unexpected partitioning %+v", e.Partitioning)
+	}
+
+	c := Column{}
+	get(t, h, "/entities/event/columns/level", &c)
+	if c.Type == nil || c.Type.Kind != "enum" || strings.Join(c.Type.Labels, ",") != "info,error" {
+		t.Errorf("unexpected type %+v", c.Type)
+	}
+	c = Column{}
+	get(t, h, "/entities/event/columns/payload", &c)
+	if len(c.JSONPaths) != 1 || c.JSONPaths[0] != "payload/-%3Ecountry" {
+		t.Errorf("unexpected JSON paths %+v", c.JSONPaths)
+	}
+	for _, path := range []string{
+		"/entities/event/columns/payload/-%3Ecountry",
+		"/entities/event/columns/" + url.PathEscape("payload/-%3Ecountry"),
+	} {
+		c = Column{}
+		if code := get(t, h, path, &c); code != http.StatusOK || c.JSONPath != "$.country" || c.JSONValueType != "string" {
+			t.Errorf("%s: unexpected column %d %+v", path, code, c)
+		}
+	}
+}
+
+// TestOpenAPI checks every field served is described in the OpenAPI
+// document.
+func TestOpenAPI(t *testing.T) {
+	doc := struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	if err := json.Unmarshal(openAPI, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []interface{}{EntitySummary{}, Entity{}, CompoundKey{}, CandidateKey{}, Partitioning{}, Column{}, DataType{}, Distribution{}, Frequency{}, Relationship{}} {
+		typ := reflect.TypeOf(v)
+		schema, ok := doc.Components.Schemas[typ.Name()]
+		if !ok {
+			t.Errorf("no schema for %s", typ.Name())
+			continue
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+			if _, ok := schema.Properties[name]; !ok {
+				t.Errorf("schema %s has no %s", typ.Name(), name)
+			}
+		}
+	}
+}
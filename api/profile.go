@@ -0,0 +1,499 @@
+package api
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// Relationship kinds.
+const (
+	OneToMany            = "oneToMany"
+	ManyToMany           = "manyToMany"
+	Correlation          = "correlation"
+	FunctionalDependency = "functionalDependency"
+	ForeignKey           = "foreignKey"
+	Recursive            = "recursive"
+	Hierarchy            = "hierarchy"
+)
+
+// Entity is a table or view with its columns and keys.
+type Entity struct {
+	Name          string         `json:"name"`
+	Kind          string         `json:"kind,omitempty"`
+	Comment       string         `json:"comment,omitempty"`
+	Columns       []Column       `json:"columns"`
+	Keys          []string       `json:"keys,omitempty"`
+	SingleKey     string         `json:"singleKey,omitempty"`
+	CompoundKeys  []CompoundKey  `json:"compoundKeys,omitempty"`
+	CandidateKeys []CandidateKey `json:"candidateKeys,omitempty"`
+	Checks        []string       `json:"checks,omitempty"`
+	DependsOn     []string       `json:"dependsOn,omitempty"`
+	Partitioning  *Partitioning  `json:"partitioning,omitempty"`
+}
+
+// CompoundKey is a pair of key columns, the strong key has fewer distinct
+// values than the weak key when the two could be told apart.
+type CompoundKey struct {
+	Columns []string `json:"columns"`
+	Strong  string   `json:"strong,omitempty"`
+	Weak    string   `json:"weak,omitempty"`
+}
+
+// CandidateKey is a minimal unique set of columns, declared by a constraint
+// or index or discovered in the data.
+type CandidateKey struct {
+	Columns  []string `json:"columns"`
+	Declared bool     `json:"declared"`
+}
+
+// Partitioning is the strategy and key of a partitioned or inherited entity,
+// the partitions are only listed when extracted with -partitions.
+type Partitioning struct {
+	Strategy   string      `json:"strategy"`
+	Key        []string    `json:"key,omitempty"`
+	Partitions []Partition `json:"partitions,omitempty"`
+}
+
+// Partition is a child of a partitioned entity.
+type Partition struct {
+	Name  string `json:"name"`
+	Bound string `json:"bound,omitempty"`
+}
+
+// Column is the profile of a single column. JSON paths are virtual columns
+// named after their JSON column, such as payload/-%3Ecountry.
+type Column struct {
+	Name            string        `json:"name"`
+	Comment         string        `json:"comment,omitempty"`
+	DataType        string        `json:"dataType,omitempty"`
+	Type            *DataType     `json:"type,omitempty"`
+	ArrayDimensions *int          `json:"arrayDimensions,omitempty"`
+	NotNull         bool          `json:"notNull,omitempty"`
+	Default         string        `json:"default,omitempty"`
+	Checks          []string      `json:"checks,omitempty"`
+	NumDistinct     *int          `json:"numDistinct,omitempty"`
+	Dimension       string        `json:"dimension,omitempty"`
+	Ordinal         bool          `json:"ordinal,omitempty"`
+	Distribution    *Distribution `json:"distribution,omitempty"`
+	Histogram       *Histogram    `json:"histogram,omitempty"`
+	TopValues       []Frequency   `json:"topValues,omitempty"`
+	JSONPaths       []string      `json:"jsonPaths,omitempty"`
+	JSONPath        string        `json:"jsonPath,omitempty"`
+	JSONValueType   string        `json:"jsonValueType,omitempty"`
+	PathFrequency   *float64      `json:"pathFrequency,omitempty"`
+}
+
+// DataType describes a user defined type, which fields are set depends on
+// Kind.
+type DataType struct {
+	Kind        string   `json:"kind"`
+	Labels      []string `json:"labels,omitempty"`
+	BaseType    string   `json:"baseType,omitempty"`
+	Checks      []string `json:"checks,omitempty"`
+	ElementType string   `json:"elementType,omitempty"`
+	Fields      []Field  `json:"fields,omitempty"`
+}
+
+// Field is an attribute of a composite type.
+type Field struct {
+	Name     string `json:"name"`
+	DataType string `json:"dataType"`
+}
+
+// Distribution describes the shape of a scalar column.
+type Distribution struct {
+	Skewness            float64 `json:"skewness"`
+	Kurtosis            float64 `json:"kurtosis"`
+	InterquartileRange  float64 `json:"interquartileRange"`
+	LowerFence          float64 `json:"lowerFence"`
+	UpperFence          float64 `json:"upperFence"`
+	NumOutliers         int     `json:"numOutliers"`
+	LogScaleRecommended bool    `json:"logScaleRecommended"`
+}
+
+// Histogram holds the buckets of a scalar column in order.
+type Histogram struct {
+	Kind    string   `json:"kind"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Bucket is one histogram bin.
+type Bucket struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}
+
+// Frequency is one of the most common values of a discrete column.
+type Frequency struct {
+	Rank  int    `json:"rank"`
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Relationship is any relationship node of the graph, which fields are set
+// depends on Kind. Columns are named entity/column when a relationship can
+// span entities.
+type Relationship struct {
+	ID                string     `json:"id"`
+	Kind              string     `json:"kind"`
+	Entity            string     `json:"entity,omitempty"`
+	Columns           []string   `json:"columns,omitempty"`
+	One               string     `json:"one,omitempty"`
+	Many              string     `json:"many,omitempty"`
+	Dependent         string     `json:"dependent,omitempty"`
+	ReferencedEntity  string     `json:"referencedEntity,omitempty"`
+	ReferencedColumns []string   `json:"referencedColumns,omitempty"`
+	Pearson           *float64   `json:"pearson,omitempty"`
+	Spearman          *float64   `json:"spearman,omitempty"`
+	Error             *float64   `json:"error,omitempty"`
+	MaxDepth          *int       `json:"maxDepth,omitempty"`
+	Structure         string     `json:"structure,omitempty"`
+	Declared          *bool      `json:"declared,omitempty"`
+	Levels            [][]string `json:"levels,omitempty"`
+}
+
+// EntityNames lists the entities of g in name order.
+func EntityNames(g *graph.Graph) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, t := range g.Match(nil, graph.IRI(vocab.HasColumn), nil) {
+		name := vocab.EntityName(t.Subj.String())
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindEntity profiles the named entity, ok is false if g has no columns for it.
+func FindEntity(g *graph.Graph, name string) (e Entity, ok bool) {
+	iri := vocab.Entity(name)
+	e = Entity{Name: name, Columns: []Column{}}
+	for _, c := range g.Objects(iri, vocab.HasColumn) {
+		e.Columns = append(e.Columns, column(g, c.String()))
+	}
+	if len(e.Columns) == 0 {
+		return e, false
+	}
+	if t := first(g, iri, vocab.HasEntityKind); t != nil {
+		e.Kind = strings.TrimPrefix(t.String(), vocab.Root+"kind/")
+	}
+	if t := first(g, iri, vocab.Comment); t != nil {
+		e.Comment = t.String()
+	}
+	e.Keys = columnNames(g.Objects(iri, vocab.HasKey))
+	if single := columnNames(g.Objects(iri, vocab.HasSingleKey)); len(single) > 0 {
+		e.SingleKey = single[0]
+	}
+	for _, k := range g.Objects(iri, vocab.HasCompoundKey) {
+		key := CompoundKey{Columns: strings.Split(after(k.String(), "/compound/"), "/")}
+		if strong := columnNames(g.Objects(k.String(), vocab.HasStrongKey)); len(strong) > 0 {
+			key.Strong = strong[0]
+		}
+		if weak := columnNames(g.Objects(k.String(), vocab.HasWeakKey)); len(weak) > 0 {
+			key.Weak = weak[0]
+		}
+		e.CompoundKeys = append(e.CompoundKeys, key)
+	}
+	for _, k := range g.Objects(iri, vocab.HasCandidateKey) {
+		key := CandidateKey{Columns: strings.Split(after(k.String(), "/candidate/"), ",")}
+		if t := first(g, k.String(), vocab.IsDeclared); t != nil {
+			key.Declared = t.String() == "true"
+		}
+		e.CandidateKeys = append(e.CandidateKeys, key)
+	}
+	e.Checks = literals(g.Objects(iri, vocab.HasCheck))
+	for _, t := range g.Objects(iri, vocab.DependsOn) {
+		e.DependsOn = append(e.DependsOn, vocab.EntityName(t.String()))
+	}
+	sort.Strings(e.DependsOn)
+	if t := first(g, iri, vocab.PartitionStrategy); t != nil {
+		e.Partitioning = &Partitioning{Strategy: t.String(), Key: columnNames(g.Objects(iri, vocab.HasPartitionKey))}
+		for _, p := range g.Objects(iri, vocab.HasPartition) {
+			part := Partition{Name: after(p.String(), "/partition/")}
+			if b := first(g, p.String(), vocab.PartitionBound); b != nil {
+				part.Bound = b.String()
+			}
+			e.Partitioning.Partitions = append(e.Partitioning.Partitions, part)
+		}
+		sort.Slice(e.Partitioning.Partitions, func(i, j int) bool {
+			return e.Partitioning.Partitions[i].Name < e.Partitioning.Partitions[j].Name
+		})
+	}
+	return e, true
+}
+
+// FindColumn profiles a single column of an entity. The name of a JSON path
+// may be given with its IRI escaping or without it.
+func FindColumn(g *graph.Graph, entity string, col string) (Column, bool) {
+	for _, c := range g.Objects(vocab.Entity(entity), vocab.HasColumn) {
+		name := vocab.ColumnName(c.String())
+		if unescaped, err := url.PathUnescape(name); name == col || err == nil && unescaped == col {
+			return column(g, c.String()), true
+		}
+	}
+	return Column{}, false
+}
+
+func column(g *graph.Graph, iri string) Column {
+	c := Column{Name: vocab.ColumnName(iri)}
+	if t := first(g, iri, vocab.Comment); t != nil {
+		c.Comment = t.String()
+	}
+	if t := first(g, iri, vocab.HasDataType); t != nil {
+		c.DataType = strings.TrimPrefix(t.String(), vocab.DataTypePrefix)
+		c.Type = dataType(g, t.String())
+	}
+	if t := first(g, iri, vocab.ArrayDimensions); t != nil {
+		n := int(graph.Number(t))
+		c.ArrayDimensions = &n
+	}
+	if t := first(g, iri, vocab.NotNull); t != nil {
+		c.NotNull = t.String() == "true"
+	}
+	if t := first(g, iri, vocab.HasDefault); t != nil {
+		c.Default = t.String()
+	}
+	c.Checks = literals(g.Objects(iri, vocab.HasCheck))
+	if t := first(g, iri, vocab.NumDistinct); t != nil {
+		n := int(graph.Number(t))
+		c.NumDistinct = &n
+	}
+	if t := first(g, iri, vocab.HasDimension); t != nil {
+		c.Dimension = strings.TrimPrefix(t.String(), vocab.Root+"dimension/")
+	}
+	if t := first(g, iri, vocab.IsOrdinal); t != nil {
+		c.Ordinal = t.String() == "true"
+	}
+	if t := first(g, iri, vocab.Skewness); t != nil {
+		c.Distribution = &Distribution{
+			Skewness:           graph.Number(t),
+			Kurtosis:           graph.Number(first(g, iri, vocab.Kurtosis)),
+			InterquartileRange: graph.Number(first(g, iri, vocab.InterquartileRange)),
+			LowerFence:         graph.Number(first(g, iri, vocab.LowerFence)),
+			UpperFence:         graph.Number(first(g, iri, vocab.UpperFence)),
+			NumOutliers:        int(graph.Number(first(g, iri, vocab.NumOutliers))),
+		}
+		if t := first(g, iri, vocab.LogScaleRecommended); t != nil {
+			c.Distribution.LogScaleRecommended = t.String() == "true"
+		}
+	}
+	if h := first(g, iri, vocab.HasHistogram); h != nil {
+		c.Histogram = &Histogram{Buckets: []Bucket{}}
+		if kind := first(g, h.String(), vocab.HistogramKind); kind != nil {
+			c.Histogram.Kind = kind.String()
+		}
+		buckets := g.Objects(h.String(), vocab.HasBucket)
+		sort.Slice(buckets, func(i, j int) bool {
+			return graph.Number(first(g, buckets[i].String(), vocab.BucketIndex)) < graph.Number(first(g, buckets[j].String(), vocab.BucketIndex))
+		})
+		for _, b := range buckets {
+			c.Histogram.Buckets = append(c.Histogram.Buckets, Bucket{
+				Lower: graph.Number(first(g, b.String(), vocab.LowerBound)),
+				Upper: graph.Number(first(g, b.String(), vocab.UpperBound)),
+				Count: int(graph.Number(first(g, b.String(), vocab.Frequency))),
+			})
+		}
+	}
+	for _, f := range g.Objects(iri, vocab.HasFrequentValue) {
+		freq := Frequency{
+			Rank:  int(graph.Number(first(g, f.String(), vocab.Rank))),
+			Count: int(graph.Number(first(g, f.String(), vocab.Frequency))),
+		}
+		if v := first(g, f.String(), vocab.Value); v != nil {
+			freq.Value = v.String()
+		}
+		c.TopValues = append(c.TopValues, freq)
+	}
+	sort.Slice(c.TopValues, func(i, j int) bool { return c.TopValues[i].Rank < c.TopValues[j].Rank })
+	c.JSONPaths = columnNames(g.Objects(iri, vocab.HasJSONPath))
+	sort.Strings(c.JSONPaths)
+	if t := first(g, iri, vocab.JSONPath); t != nil {
+		c.JSONPath = t.String()
+	}
+	if t := first(g, iri, vocab.JSONValueType); t != nil {
+		c.JSONValueType = t.String()
+	}
+	c.PathFrequency = optionalNumber(first(g, iri, vocab.PathFrequency))
+	return c
+}
+
+// dataType describes a user defined type, or returns nil for a built in one.
+func dataType(g *graph.Graph, iri string) *DataType {
+	kind := first(g, iri, vocab.HasTypeKind)
+	if kind == nil {
+		return nil
+	}
+	t := &DataType{Kind: strings.TrimPrefix(kind.String(), vocab.Root+"typeKind/")}
+	labels := g.Objects(iri, vocab.HasLabel)
+	sort.Slice(labels, func(i, j int) bool {
+		return graph.Number(first(g, labels[i].String(), vocab.LabelOrder)) < graph.Number(first(g, labels[j].String(), vocab.LabelOrder))
+	})
+	for _, l := range labels {
+		if v := first(g, l.String(), vocab.Value); v != nil {
+			t.Labels = append(t.Labels, v.String())
+		}
+	}
+	if b := first(g, iri, vocab.HasBaseType); b != nil {
+		t.BaseType = strings.TrimPrefix(b.String(), vocab.DataTypePrefix)
+	}
+	t.Checks = literals(g.Objects(iri, vocab.HasCheck))
+	if e := first(g, iri, vocab.HasElementType); e != nil {
+		t.ElementType = strings.TrimPrefix(e.String(), vocab.DataTypePrefix)
+	}
+	fields := g.Objects(iri, vocab.HasField)
+	sort.Slice(fields, func(i, j int) bool {
+		return graph.Number(first(g, fields[i].String(), vocab.FieldIndex)) < graph.Number(first(g, fields[j].String(), vocab.FieldIndex))
+	})
+	for _, f := range fields {
+		field := Field{}
+		if n := first(g, f.String(), vocab.FieldName); n != nil {
+			field.Name = n.String()
+		}
+		if d := first(g, f.String(), vocab.HasDataType); d != nil {
+			field.DataType = strings.TrimPrefix(d.String(), vocab.DataTypePrefix)
+		}
+		t.Fields = append(t.Fields, field)
+	}
+	return t
+}
+
+// Relationships lists every relationship node of g grouped by kind.
+func Relationships(g *graph.Graph) []Relationship {
+	rels := []Relationship{}
+	each := func(p string, f func(node string, r *Relationship)) {
+		for _, t := range g.Match(nil, graph.IRI(p), nil) {
+			r := Relationship{ID: t.Obj.String(), Entity: vocab.EntityName(t.Subj.String())}
+			f(t.Obj.String(), &r)
+			rels = append(rels, r)
+		}
+	}
+	each(vocab.HasOne2ManyKey, func(node string, r *Relationship) {
+		r.Kind = OneToMany
+		r.One = columnName(first(g, node, vocab.HasOneKey))
+		r.Many = columnName(first(g, node, vocab.HasManyKey))
+	})
+	each(vocab.HasMany2ManyKey, func(node string, r *Relationship) {
+		r.Kind = ManyToMany
+		r.Columns = columnNames(g.Objects(node, vocab.HasManyKey))
+	})
+	each(vocab.HasCorrelation, func(node string, r *Relationship) {
+		r.Kind = Correlation
+		r.Columns = columnNames(g.Objects(node, vocab.HasCorrelatedColumn))
+		r.Pearson = optionalNumber(first(g, node, vocab.Pearson))
+		r.Spearman = optionalNumber(first(g, node, vocab.Spearman))
+	})
+	each(vocab.HasFunctionalDependency, func(node string, r *Relationship) {
+		r.Kind = FunctionalDependency
+		r.Columns = columnNames(g.Objects(node, vocab.HasDeterminant))
+		r.Dependent = columnName(first(g, node, vocab.HasDependent))
+		r.Error = optionalNumber(first(g, node, vocab.FDError))
+	})
+	each(vocab.HasForeignKey, func(node string, r *Relationship) {
+		r.Kind = ForeignKey
+		if ref := first(g, node, vocab.ReferencesEntity); ref != nil {
+			r.ReferencedEntity = vocab.EntityName(ref.String())
+		}
+		cols := g.Objects(node, vocab.HasForeignKeyColumn)
+		r.Columns = columnNames(cols)
+		for _, c := range cols {
+			r.ReferencedColumns = append(r.ReferencedColumns, columnName(first(g, c.String(), vocab.ReferencesColumn)))
+		}
+	})
+	each(vocab.HasRecursiveRelationship, func(node string, r *Relationship) {
+		r.Kind = Recursive
+		r.ReferencedEntity = r.Entity
+		r.Columns = columnNames(g.Objects(node, vocab.HasReferencingColumn))
+		r.ReferencedColumns = columnNames(g.Objects(node, vocab.HasReferencedColumn))
+		if t := first(g, node, vocab.MaxDepth); t != nil {
+			n := int(graph.Number(t))
+			r.MaxDepth = &n
+		}
+		if t := first(g, node, vocab.RecursiveStructure); t != nil {
+			r.Structure = strings.TrimPrefix(t.String(), vocab.Root+"structure/")
+		}
+		if t := first(g, node, vocab.IsDeclared); t != nil {
+			declared := t.String() == "true"
+			r.Declared = &declared
+		}
+	})
+
+	// hierarchies hang off every entity they touch, list each once
+	seen := map[string]bool{}
+	for _, t := range g.Match(nil, graph.IRI(vocab.HasHierarchy), nil) {
+		node := t.Obj.String()
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		r := Relationship{ID: node, Kind: Hierarchy}
+		levels := g.Objects(node, vocab.HasLevel)
+		sort.Slice(levels, func(i, j int) bool {
+			return graph.Number(first(g, levels[i].String(), vocab.LevelIndex)) < graph.Number(first(g, levels[j].String(), vocab.LevelIndex))
+		})
+		for _, l := range levels {
+			cols := []string{}
+			for _, c := range g.Objects(l.String(), vocab.LevelColumn) {
+				cols = append(cols, vocab.EntityName(c.String())+"/"+vocab.ColumnName(c.String()))
+			}
+			r.Levels = append(r.Levels, cols)
+		}
+		rels = append(rels, r)
+	}
+	return rels
+}
+
+func first(g *graph.Graph, subj string, p string) rdf.Term {
+	objs := g.Objects(subj, p)
+	if len(objs) == 0 {
+		return nil
+	}
+	return objs[0]
+}
+
+func columnName(t rdf.Term) string {
+	if t == nil {
+		return ""
+	}
+	return vocab.ColumnName(t.String())
+}
+
+func columnNames(terms []rdf.Term) []string {
+	names := []string{}
+	for _, t := range terms {
+		names = append(names, columnName(t))
+	}
+	return names
+}
+
+func literals(terms []rdf.Term) []string {
+	var values []string
+	for _, t := range terms {
+		values = append(values, t.String())
+	}
+	sort.Strings(values)
+	return values
+}
+
+func after(s string, sep string) string {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[i+len(sep):]
+	}
+	return s
+}
+
+func optionalNumber(t rdf.Term) *float64 {
+	if t == nil {
+		return nil
+	}
+	n := graph.Number(t)
+	return &n
+}
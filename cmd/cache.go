@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// statsCache keeps the results of the profiling queries between runs. Each
+// table carries the fingerprint it had when its results were computed, a
+// table whose fingerprint changed starts again from nothing.
+type statsCache struct {
+	Tables map[string]*tableCache `json:"tables"`
+	hits   int
+	misses int
+}
+
+type tableCache struct {
+	Fingerprint string                     `json:"fingerprint"`
+	Results     map[string]json.RawMessage `json:"results"`
+}
+
+// cache is nil when no cache file is given.
+var cache *statsCache
+
+// keepCache holds the cache in memory between extractions of a long running
+// process even without a cache file.
+var keepCache bool
+
+// openCache loads the cache file and drops the tables that changed since it
+// was written. Tables without a fingerprint, such as views, are not cached.
+func openCache() *statsCache {
+	if *cacheFile == "" && !keepCache {
+		return nil
+	}
+	c := &statsCache{Tables: map[string]*tableCache{}}
+	if *cacheFile == "" {
+		if cache != nil {
+			c.Tables = cache.Tables
+		}
+	} else if b, err := ioutil.ReadFile(*cacheFile); err == nil {
+		if err := json.Unmarshal(b, c); err != nil {
+			fmt.Println(err)
+			c.Tables = map[string]*tableCache{}
+		}
+	} else if !os.IsNotExist(err) {
+		report(err)
+	}
+	fingerprints, err := src.Fingerprints(*cacheFingerprint == "checksum")
+	if err != nil {
+		// without fingerprints nothing can be trusted
+		fmt.Println(err)
+		fingerprints = map[string]string{}
+	}
+	for table, t := range c.Tables {
+		if t.Fingerprint != fingerprints[table] {
+			if *verbose {
+				log.Printf("cache: %s changed", table)
+			}
+			delete(c.Tables, table)
+		}
+	}
+	for table, fp := range fingerprints {
+		if _, ok := c.Tables[table]; !ok {
+			c.Tables[table] = &tableCache{Fingerprint: fp, Results: map[string]json.RawMessage{}}
+		}
+	}
+	return c
+}
+
+// save writes the cache back, through a temporary file so an interrupted
+// run never leaves a truncated cache behind.
+func (c *statsCache) save() {
+	if c == nil {
+		return
+	}
+	if *verbose {
+		log.Printf("cache: %d hits, %d misses", c.hits, c.misses)
+	}
+	if *cacheFile == "" {
+		return
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	tmp := *cacheFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := os.Rename(tmp, *cacheFile); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// invalidate forgets the results of the given tables.
+func (c *statsCache) invalidate(tables []string) {
+	if c == nil {
+		return
+	}
+	for _, t := range tables {
+		delete(c.Tables, t)
+	}
+}
+
+// cached fills v from the cache when the entity is unchanged, otherwise it
+// runs compute, which must fill v, and remembers the result. Keys name the
+// statistic, its columns and any flag that changes its value.
+func cached(entity string, key string, v interface{}, compute func() error) error {
+	var t *tableCache
+	if cache != nil {
+		t = cache.Tables[entity]
+	}
+	if t != nil {
+		if raw, ok := t.Results[key]; ok && json.Unmarshal(raw, v) == nil {
+			cache.hits++
+			return nil
+		}
+		cache.misses++
+	}
+	if err := compute(); err != nil {
+		return err
+	}
+	if t != nil {
+		// values JSON cannot hold, such as NaN, are simply not cached
+		if raw, err := json.Marshal(v); err == nil {
+			t.Results[key] = raw
+		}
+	}
+	return nil
+}
+
+// cacheKey joins the parts of a cache key.
+func cacheKey(parts ...interface{}) string {
+	s := make([]string, len(parts))
+	for i, p := range parts {
+		s[i] = fmt.Sprint(p)
+	}
+	return strings.Join(s, "/")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/source"
+)
+
+func TestCached(t *testing.T) {
+	defer func() { cache = nil }()
+	cache = &statsCache{Tables: map[string]*tableCache{
+		"city": {Fingerprint: "f", Results: map[string]json.RawMessage{}},
+	}}
+	calls := 0
+	histogram := func(entity string) []source.Bucket {
+		var buckets []source.Bucket
+		err := cached(entity, cacheKey("histogram", "population", "width", 2), &buckets, func() error {
+			calls++
+			buckets = []source.Bucket{{Lower: 0, Upper: 5, Count: 3}, {Lower: 5, Upper: 10, Count: 7}}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buckets
+	}
+
+	first := histogram("city")
+	second := histogram("city")
+	if calls != 1 {
+		t.Errorf("wanted the second lookup served from the cache, computed %d times", calls)
+	}
+	if len(second) != 2 || second[1] != first[1] {
+		t.Errorf("wanted %v from the cache got %v", first, second)
+	}
+
+	// tables without a fingerprint are always computed
+	histogram("river")
+	histogram("river")
+	if calls != 3 {
+		t.Errorf("wanted uncached table computed every time, computed %d times", calls)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// candidateKey is a set of columns identifying every row of an entity, either
+// declared by a UNIQUE constraint or index or discovered in the data.
+type candidateKey struct {
+	cols     []string
+	declared bool
+}
+
+// writeCandidateKeys records the declared unique keys of every entity and,
+// for entities without a primary key, the minimal unique column combinations
+// up to -key-width columns. The primary keys are returned with keyless
+// entities taking their first candidate key, so the key phases that follow
+// cover views, imported and keyless tables too.
+func writeCandidateKeys(w io.Writer) map[string][]string {
+	if *verbose {
+		log.Println("extracting candidate keys")
+	}
+	pks := queryPrimaryKeys()
+	unique, err := src.UniqueKeys()
+	if err != nil {
+		report(err)
+	}
+	tables := map[string][]string{}
+	for _, c := range queryColumns() {
+		tables[c.Entity] = append(tables[c.Entity], c.Name)
+	}
+	entities := make([]string, 0, len(tables))
+	for entity := range tables {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+
+	triples := []rdf.Triple{}
+	for _, entity := range entities {
+		keys := []candidateKey{}
+		for _, cols := range unique[entity] {
+			keys = append(keys, candidateKey{cols: cols, declared: true})
+		}
+		if len(pks[entity]) == 0 && *keyWidth > 0 {
+			keys = append(keys, discoverKeys(w, entity, tables[entity], keys)...)
+		}
+		for _, k := range keys {
+			node := tablePrefix + entity + candidateMiddle + strings.Join(k.cols, ",")
+			triples = append(triples, iriTriple(tablePrefix+entity, vocab.HasCandidateKey, node))
+			for _, c := range k.cols {
+				triples = append(triples, iriTriple(node, vocab.HasKeyColumn, tablePrefix+entity+colMiddle+c))
+			}
+			triples = append(triples, literalTriple(node, vocab.IsDeclared, k.declared))
+		}
+		if len(pks[entity]) == 0 && len(keys) > 0 {
+			if *verbose {
+				log.Printf("%s has no primary key, using %v", entity, keys[0].cols)
+			}
+			pks[entity] = keys[0].cols
+		}
+	}
+	writeTriples(w, triples)
+	return pks
+}
+
+// discoverKeys checks the column combinations of an entity level by level,
+// skipping supersets of the keys already known so only minimal ones are
+// found. An empty entity has no duplicates to rule any combination out, so
+// nothing is discovered in it.
+func discoverKeys(w io.Writer, entity string, cols []string, known []candidateKey) []candidateKey {
+	if len(cols) == 0 {
+		return nil
+	}
+	var empty bool
+	err := cached(entity, cacheKey("empty"), &empty, func() error {
+		rows, err := src.SampleRows(entity, cols[:1], 1)
+		empty = len(rows) == 0
+		return err
+	})
+	if writeUnavailable(w, err, tablePrefix+entity, vocab.HasCandidateKey) {
+		return nil
+	}
+	if err != nil {
+		report(err)
+		return nil
+	}
+	if empty {
+		return nil
+	}
+	found := []candidateKey{}
+	covered := func(combo []string) bool {
+		for _, k := range append(append([]candidateKey{}, known...), found...) {
+			if isSubset(k.cols, combo) {
+				return true
+			}
+		}
+		return false
+	}
+	for width := 1; width <= *keyWidth && width <= len(cols); width++ {
+		stop := false
+		combinations(len(cols), width, func(idx []int) {
+			if stop {
+				return
+			}
+			combo := make([]string, len(idx))
+			for i, j := range idx {
+				combo[i] = cols[j]
+			}
+			if covered(combo) {
+				return
+			}
+			var ok bool
+			err := cached(entity, cacheKey("unique", strings.Join(combo, ",")), &ok, func() (err error) {
+				ok, err = src.IsUnique(entity, combo)
+				return err
+			})
+			if writeUnavailable(w, err, tablePrefix+entity, vocab.HasCandidateKey) {
+				stop = true
+				return
+			}
+			if err != nil {
+				report(err)
+				return
+			}
+			if ok {
+				found = append(found, candidateKey{cols: combo})
+			}
+		})
+		if stop {
+			break
+		}
+	}
+	return found
+}
+
+// combinations calls fn with every k of n indexes in increasing order, fn
+// must not keep the slice.
+func combinations(n int, k int, fn func([]int)) {
+	idx := make([]int, 0, k)
+	var search func(int)
+	search = func(start int) {
+		if len(idx) == k {
+			fn(idx)
+			return
+		}
+		for i := start; i <= n-(k-len(idx)); i++ {
+			idx = append(idx, i)
+			search(i + 1)
+			idx = idx[:len(idx)-1]
+		}
+	}
+	search(0)
+}
+
+func isSubset(sub []string, set []string) bool {
+	for _, s := range sub {
+		found := false
+		for _, t := range set {
+			if s == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
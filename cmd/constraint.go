@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// writeConstraints writes the comments of the entities and columns as
+// rdfs:comment, which make human readable chart titles, along with NOT NULL,
+// defaults and CHECK constraints. A check is written on its entity and on
+// each column it reads, where it bounds the values without a scan.
+func writeConstraints(w io.Writer) {
+	triples := []rdf.Triple{}
+	for _, e := range queryEntities() {
+		if e.Comment != "" {
+			triples = append(triples, literalTriple(tablePrefix+e.Name, vocab.Comment, e.Comment))
+		}
+	}
+	for _, data := range queryColumns() {
+		col := tablePrefix + data.Entity + colMiddle + data.Name
+		if data.Comment != "" {
+			triples = append(triples, literalTriple(col, vocab.Comment, data.Comment))
+		}
+		if data.NotNull {
+			triples = append(triples, literalTriple(col, vocab.NotNull, true))
+		}
+		if data.Default != "" {
+			triples = append(triples, literalTriple(col, vocab.HasDefault, data.Default))
+		}
+	}
+	checks, err := src.Checks()
+	if err != nil {
+		report(err)
+	}
+	for _, c := range checks {
+		triples = append(triples, literalTriple(tablePrefix+c.Entity, vocab.HasCheck, c.Definition))
+		for _, col := range c.Columns {
+			triples = append(triples, literalTriple(tablePrefix+c.Entity+colMiddle+col, vocab.HasCheck, c.Definition))
+		}
+	}
+	writeTriples(w, triples)
+}
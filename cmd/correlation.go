@@ -0,0 +1,49 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"log"
+
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// writeCorrelationItem records the Pearson and Spearman coefficients of two
+// scalar columns of the same entity, strongly correlated pairs make good
+// scatter plots.
+func writeCorrelationItem(w io.Writer, entity string, col1 string, col2 string) {
+	if *verbose {
+		log.Printf("entering correlation checker for %s:%s,%s", entity, col1, col2)
+	}
+	var res struct {
+		Pearson  sql.NullFloat64
+		Spearman sql.NullFloat64
+	}
+	err := cached(entity, cacheKey("correlation", col1, col2, *corrSample), &res, func() (err error) {
+		res.Pearson, res.Spearman, err = src.Correlation(entity, col1, col2, *corrSample)
+		return err
+	})
+	if err != nil {
+		report(err)
+		return
+	}
+	pearson, spearman := res.Pearson, res.Spearman
+	if !pearson.Valid && !spearman.Valid {
+		// one of the columns is constant or there are no rows
+		return
+	}
+	node := tablePrefix + entity + corrMiddle + col1 + "/" + col2
+	triples := []rdf.Triple{
+		iriTriple(tablePrefix+entity, vocab.HasCorrelation, node),
+		iriTriple(node, vocab.HasCorrelatedColumn, tablePrefix+entity+colMiddle+col1),
+		iriTriple(node, vocab.HasCorrelatedColumn, tablePrefix+entity+colMiddle+col2),
+	}
+	if pearson.Valid {
+		triples = append(triples, literalTriple(node, vocab.Pearson, pearson.Float64))
+	}
+	if spearman.Valid {
+		triples = append(triples, literalTriple(node, vocab.Spearman, spearman.Float64))
+	}
+	writeTriples(w, triples)
+}
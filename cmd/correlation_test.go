@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// TestCorrelations checks a monotone but not linear pair of scalar columns
+// has a Spearman of one and a Pearson below it.
+func TestCorrelations(t *testing.T) {
+	g := extractSQLite(t,
+		`create table square (x integer, y integer)`,
+		`insert into square with recursive n(i) as (select 1 union all select i + 1 from n where i < 150) select i, i * i from n`,
+	)
+
+	square := tablePrefix + "square"
+	node := square + corrMiddle + "x/y"
+	hasTriples(t, g,
+		iriTriple(square, vocab.HasCorrelation, node),
+		iriTriple(node, vocab.HasCorrelatedColumn, square+colMiddle+"x"),
+		iriTriple(node, vocab.HasCorrelatedColumn, square+colMiddle+"y"),
+	)
+	for pred, want := range map[string]float64{
+		vocab.Pearson:  0.9686508356200644,
+		vocab.Spearman: 1,
+	} {
+		objs := g.Objects(node, pred)
+		if len(objs) != 1 {
+			t.Errorf("wanted one %s got %d", pred, len(objs))
+			continue
+		}
+		got, _ := objs[0].(rdf.Literal).Typed()
+		if f, ok := got.(float64); !ok || math.Abs(f-want) > 1e-9 {
+			t.Errorf("wanted %s %v got %v", pred, want, got)
+		}
+	}
+}
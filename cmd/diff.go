@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/dooodle/vis-extractor/diff"
+)
+
+// runDiff reports how the graph changed between two N-Triple files, or
+// between a file and the live database when only one file is given.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "text", "report format: text, json or patch for RDF Patch")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		log.Fatal("usage: diff [-format text|json|patch] old.nt [new.nt]")
+	}
+
+	old, err := loadGraph(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// with no second file fs.Arg(1) is "" and the database is extracted
+	new, err := loadGraph(fs.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := diff.Compare(old, new)
+
+	w, closer := output()
+	defer closer.Close()
+	switch *format {
+	case "json":
+		err = r.WriteJSON(w)
+	case "patch":
+		err = r.WritePatch(w)
+	default:
+		err = r.WriteText(w)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
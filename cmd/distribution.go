@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"math"
+
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// distribution holds the moments and quartiles of a scalar column.
+type distribution source.Moments
+
+func (d distribution) skewness() float64 {
+	if d.StdDev == 0 {
+		return 0
+	}
+	return d.M3 / math.Pow(d.StdDev, 3)
+}
+
+// kurtosis is the excess kurtosis, 0 for a normal distribution.
+func (d distribution) kurtosis() float64 {
+	if d.StdDev == 0 {
+		return 0
+	}
+	return d.M4/math.Pow(d.StdDev, 4) - 3
+}
+
+func (d distribution) iqr() float64 {
+	return d.Q3 - d.Q1
+}
+
+// fences are the usual Tukey fences, values outside them count as outliers.
+func (d distribution) fences() (float64, float64) {
+	return d.Q1 - 1.5*d.iqr(), d.Q3 + 1.5*d.iqr()
+}
+
+// logScale suggests a log axis for strictly positive columns that are either
+// right skewed or span several orders of magnitude.
+func (d distribution) logScale() bool {
+	if d.Min <= 0 {
+		return false
+	}
+	return d.skewness() > logSkewHeuristic || d.Max/d.Min >= logRangeHeuristic
+}
+
+// writeDistribution attaches shape metrics to a scalar column so later rules
+// can pick log axes or clip outliers.
+func writeDistribution(w io.Writer, entity string, col string) {
+	var res struct {
+		Distribution distribution
+		OK           bool
+	}
+	err := cached(entity, cacheKey("distribution", col), &res, func() (err error) {
+		var m source.Moments
+		m, res.OK, err = src.Moments(entity, col)
+		res.Distribution = distribution(m)
+		return err
+	})
+	if err != nil {
+		report(err)
+		return
+	}
+	if !res.OK {
+		return
+	}
+	d := res.Distribution
+	lower, upper := d.fences()
+	colIRI := tablePrefix + entity + colMiddle + col
+	triples := []rdf.Triple{
+		literalTriple(colIRI, vocab.Skewness, d.skewness()),
+		literalTriple(colIRI, vocab.Kurtosis, d.kurtosis()),
+		literalTriple(colIRI, vocab.InterquartileRange, d.iqr()),
+		literalTriple(colIRI, vocab.LowerFence, lower),
+		literalTriple(colIRI, vocab.UpperFence, upper),
+		literalTriple(colIRI, vocab.NumOutliers, d.Outliers),
+		literalTriple(colIRI, vocab.LogScaleRecommended, d.logScale()),
+	}
+	writeTriples(w, triples)
+}
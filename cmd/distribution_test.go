@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistributionShape(t *testing.T) {
+	// values 1, 2, 3, 10
+	d := distribution{
+		Mean:   4,
+		StdDev: math.Sqrt(12.5),
+		Q1:     1.75,
+		Q3:     4.75,
+		Min:    1,
+		Max:    10,
+		M3:     (-27 - 8 - 1 + 216) / 4.0,
+		M4:     (81 + 16 + 1 + 1296) / 4.0,
+	}
+	if s := d.skewness(); math.Abs(s-1.0182) > 1e-3 {
+		t.Errorf("wanted skewness 1.0182 got %v", s)
+	}
+	if k := d.kurtosis(); math.Abs(k+0.7696) > 1e-3 {
+		t.Errorf("wanted kurtosis -0.7696 got %v", k)
+	}
+	if lo, hi := d.fences(); lo != -2.75 || hi != 9.25 {
+		t.Errorf("wanted fences -2.75, 9.25 got %v, %v", lo, hi)
+	}
+	if !d.logScale() {
+		t.Errorf("wanted log scale for skewed positive column")
+	}
+	d.Min = 0
+	if d.logScale() {
+		t.Errorf("log scale recommended for column containing 0")
+	}
+}
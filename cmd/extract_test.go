@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// extractSQLite runs the statements against a new SQLite file and extracts
+// it, as -driver sqlite would.
+func extractSQLite(t *testing.T, stmts ...string) *graph.Graph {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return extractSource(t, source.NewSQLite(db))
+}
+
+// extractDDL extracts a schema dump, as -driver ddl would.
+func extractDDL(t *testing.T, dump string) *graph.Graph {
+	t.Helper()
+	d, err := source.ParseDDL(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return extractSource(t, d)
+}
+
+// extractSource extracts the graph of s, skipping views when -skip-views is
+// set as openSource does.
+func extractSource(t *testing.T, s source.Source) *graph.Graph {
+	t.Helper()
+	t.Cleanup(func() { src, cache = nil, nil })
+	src = s
+	if *skipViews != "" {
+		src = viewSkipper{src}
+	}
+	buf := bytes.Buffer{}
+	if err := extract(&buf); err != nil {
+		t.Fatal(err)
+	}
+	g, err := graph.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// hasTriples reports each of the triples missing from g.
+func hasTriples(t *testing.T, g *graph.Graph, triples ...rdf.Triple) {
+	t.Helper()
+	for _, want := range triples {
+		if !g.Has(want) {
+			t.Errorf("missing %s", want.Serialize(rdf.NTriples))
+		}
+	}
+}
+
+// TestExtractSQLite runs a whole extraction against a SQLite file, which
+// needs no server.
+func TestExtractSQLite(t *testing.T) {
+	g := extractSQLite(t,
+		`create table country (code text primary key, name text unique, continent text)`,
+		`create table border (country1 text, country2 text, length integer)`,
+		`insert into border values ('D', 'F', 451), ('F', 'D', 451), ('D', 'B', 451), ('B', 'D', 451)`,
+		`create table city (name text, country text references country, population integer, primary key (country, name))`,
+		`insert into country values ('D', 'Germany', 'Europe'), ('F', 'France', 'Europe'), ('J', 'Japan', 'Asia')`,
+		`insert into city values ('Berlin', 'D', 3500000), ('Hamburg', 'D', 1800000), ('Paris', 'F', 2100000), ('Lyon', 'F', 500000), ('Tokyo', 'J', 9000000)`,
+		`create view big_city as select name, population from city where population > 2000000`,
+		`create table visit (who text, at text)`,
+	)
+
+	city := tablePrefix + "city"
+	country := tablePrefix + "country"
+	border := tablePrefix + "border"
+	hasTriples(t, g,
+		iriTriple(city, vocab.HasColumn, city+colMiddle+"population"),
+		iriTriple(city+colMiddle+"population", vocab.HasDataType, dataTypePrefix+"integer"),
+		iriTriple(country+colMiddle+"name", vocab.HasDataType, dataTypePrefix+"text"),
+		literalTriple(country+colMiddle+"continent", vocab.NumDistinct, 2),
+		iriTriple(country+colMiddle+"continent", vocab.HasDimension, discreteDimension),
+		iriTriple(country, vocab.HasKey, country+colMiddle+"code"),
+		iriTriple(country, vocab.HasSingleKey, country+colMiddle+"code"),
+		iriTriple(city, vocab.HasCompoundKey, city+compoundMiddle+"country/name"),
+		iriTriple(country, vocab.HasOne2ManyKey, country+one2mMiddle+"continent/code"),
+		iriTriple(city, vocab.HasForeignKey, city+fkMiddle+"city_fk0"),
+		iriTriple(country, vocab.HasCandidateKey, country+candidateMiddle+"name"),
+		literalTriple(country+candidateMiddle+"name", vocab.IsDeclared, true),
+		// border has no primary key, its discovered key stands in
+		iriTriple(border, vocab.HasCandidateKey, border+candidateMiddle+"country1,country2"),
+		literalTriple(border+candidateMiddle+"country1,country2", vocab.IsDeclared, false),
+		iriTriple(border, vocab.HasCompoundKey, border+compoundMiddle+"country1/country2"),
+		iriTriple(city, vocab.HasEntityKind, vocab.TableKind),
+		iriTriple(tablePrefix+"big_city", vocab.HasEntityKind, vocab.ViewKind),
+		literalTriple(tablePrefix+"big_city"+colMiddle+"population", vocab.NumDistinct, 3),
+	)
+	// every column of an empty table is unique, none of them is a key
+	if n := len(g.Match(graph.IRI(tablePrefix+"visit"), graph.IRI(vocab.HasCandidateKey), nil)); n != 0 {
+		t.Errorf("wanted no candidate keys for an empty table got %d", n)
+	}
+}
+
+// TestExtractCSV checks the key of a file is discovered and marked as such.
+func TestExtractCSV(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "city.csv")
+	if err := ioutil.WriteFile(name, []byte("name,country\nBerlin,D\nHamburg,D\nParis,F\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := source.NewFiles([]string{name}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := extractSource(t, f)
+
+	city := tablePrefix + "city"
+	hasTriples(t, g,
+		iriTriple(city, vocab.HasCandidateKey, city+candidateMiddle+"name"),
+		literalTriple(city+candidateMiddle+"name", vocab.IsDeclared, false),
+		iriTriple(city, vocab.HasSingleKey, city+colMiddle+"name"),
+	)
+}
+
+// TestExtractDDL checks a schema dump gives the structure and marks the
+// statistics as unavailable instead of leaving them out.
+func TestExtractDDL(t *testing.T) {
+	g := extractDDL(t, `
+CREATE TYPE public.climate AS ENUM ('arctic', 'temperate', 'tropical');
+CREATE TABLE public.country (code text PRIMARY KEY, name text NOT NULL, climate public.climate, area numeric DEFAULT 0 CHECK (area >= 0));
+COMMENT ON COLUMN public.country.area IS 'Area in square kilometres';
+CREATE TABLE public.city (name text, country text REFERENCES public.country, population integer);
+ALTER TABLE ONLY public.city ADD CONSTRAINT citykey PRIMARY KEY (name, country);
+`)
+
+	city := tablePrefix + "city"
+	country := tablePrefix + "country"
+	population := city + colMiddle + "population"
+	compound := city + compoundMiddle + "name/country"
+	hasTriples(t, g,
+		iriTriple(city, vocab.HasColumn, population),
+		iriTriple(population, vocab.HasDataType, dataTypePrefix+"int4"),
+		iriTriple(country, vocab.HasSingleKey, country+colMiddle+"code"),
+		iriTriple(city, vocab.HasCompoundKey, compound),
+		iriTriple(city, vocab.HasForeignKey, city+fkMiddle+"city_country_fkey"),
+		iriTriple(population, vocab.Unavailable, vocab.NumDistinct),
+		iriTriple(population, vocab.Unavailable, vocab.HasDimension),
+		iriTriple(compound, vocab.Unavailable, vocab.HasStrongKey),
+		iriTriple(city, vocab.Unavailable, vocab.HasOne2ManyKey),
+		// an enum is discrete and ordered without any data
+		iriTriple(country+colMiddle+"climate", vocab.HasDimension, vocab.DiscreteDimension),
+		literalTriple(country+colMiddle+"climate", vocab.IsOrdinal, true),
+		iriTriple(dataTypePrefix+"climate", vocab.HasTypeKind, typeKindPrefix+"enum"),
+		literalTriple(dataTypePrefix+"climate/label/2", vocab.LabelOrder, 2),
+		literalTriple(country+colMiddle+"name", vocab.NotNull, true),
+		literalTriple(country+colMiddle+"area", vocab.HasDefault, "0"),
+		literalTriple(country+colMiddle+"area", vocab.HasCheck, "CHECK (area >= 0)"),
+		literalTriple(country, vocab.HasCheck, "CHECK (area >= 0)"),
+		literalTriple(country+colMiddle+"area", vocab.Comment, "Area in square kilometres"),
+	)
+	if n := len(g.Match(nil, graph.IRI(vocab.NumDistinct), nil)); n != 0 {
+		t.Errorf("wanted no distinct counts from a dump got %d", n)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"log"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/fd"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// writeFunctionalDependencies generalises the two column check in
+// writeOneOrManyToManyItem to minimal dependencies with several columns on
+// the left, these point at denormalised tables and hidden hierarchies.
+func writeFunctionalDependencies(w io.Writer, tables map[string][]string) {
+	if *verbose {
+		log.Println("extracting functional dependencies")
+	}
+	for entity, cols := range tables {
+		if len(cols) < 2 {
+			continue
+		}
+		if len(cols) > fd.MaxColumns {
+			cols = cols[:fd.MaxColumns]
+		}
+		var deps []fd.Dependency
+		key := cacheKey("fd", strings.Join(cols, ","), *fdWidth, *fdError, *fdRows)
+		err := cached(entity, key, &deps, func() error {
+			rows, err := src.SampleRows(entity, cols, *fdRows)
+			if err != nil {
+				return err
+			}
+			deps = fd.Discover(rows, *fdWidth, *fdError)
+			return nil
+		})
+		if writeUnavailable(w, err, tablePrefix+entity, vocab.HasFunctionalDependency) {
+			continue
+		}
+		if err != nil {
+			report(err)
+			continue
+		}
+		triples := []rdf.Triple{}
+		for _, dep := range deps {
+			lhs := make([]string, len(dep.LHS))
+			for i, c := range dep.LHS {
+				lhs[i] = cols[c]
+			}
+			rhs := cols[dep.RHS]
+			if *verbose {
+				log.Printf("%s: %v -> %s (%v)", entity, lhs, rhs, dep.Error)
+			}
+			node := tablePrefix + entity + fdMiddle + strings.Join(lhs, ",") + "/" + rhs
+			triples = append(triples, iriTriple(tablePrefix+entity, vocab.HasFunctionalDependency, node))
+			for _, c := range lhs {
+				triples = append(triples, iriTriple(node, vocab.HasDeterminant, tablePrefix+entity+colMiddle+c))
+			}
+			triples = append(triples,
+				iriTriple(node, vocab.HasDependent, tablePrefix+entity+colMiddle+rhs),
+				literalTriple(node, vocab.FDError, dep.Error),
+			)
+		}
+		writeTriples(w, triples)
+	}
+}
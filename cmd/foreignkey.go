@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// foreignKey is a declared foreign key, cols[i] references refCols[i].
+type foreignKey struct {
+	name      string
+	entity    string
+	cols      []string
+	refEntity string
+	refCols   []string
+}
+
+// writeForeignKeys writes out the declared foreign keys and returns them for
+// the phases that follow relationships across entities.
+func writeForeignKeys(w io.Writer) []foreignKey {
+	fks, err := queryForeignKeys()
+	if err != nil {
+		report(err)
+		return nil
+	}
+	triples := []rdf.Triple{}
+	for _, fk := range fks {
+		node := tablePrefix + fk.entity + fkMiddle + fk.name
+		triples = append(triples,
+			iriTriple(tablePrefix+fk.entity, vocab.HasForeignKey, node),
+			iriTriple(node, vocab.ReferencesEntity, tablePrefix+fk.refEntity),
+		)
+		for i, c := range fk.cols {
+			col := tablePrefix + fk.entity + colMiddle + c
+			triples = append(triples,
+				iriTriple(node, vocab.HasForeignKeyColumn, col),
+				iriTriple(col, vocab.ReferencesColumn, tablePrefix+fk.refEntity+colMiddle+fk.refCols[i]),
+			)
+		}
+	}
+	writeTriples(w, triples)
+	return fks
+}
+
+func queryForeignKeys() ([]foreignKey, error) {
+	declared, err := src.ForeignKeys()
+	if err != nil {
+		return nil, err
+	}
+	fks := make([]foreignKey, len(declared))
+	for i, fk := range declared {
+		fks[i] = foreignKey{name: fk.Name, entity: fk.Entity, cols: fk.Columns, refEntity: fk.RefEntity, refCols: fk.RefColumns}
+	}
+	return fks, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// one2many is a one to many relationship between two columns of an entity,
+// each value of many goes with exactly one value of one, as each province
+// lies in one country.
+type one2many struct {
+	entity string
+	one    string
+	many   string
+}
+
+// maxHierarchies stops the path enumeration on pathological graphs.
+const maxHierarchies = 1000
+
+// writeHierarchies chains one to many relationships into drill down
+// hierarchies such as continent -> country -> province -> city. Columns
+// joined by a foreign key are the same level, so chains can cross entities.
+func writeHierarchies(w io.Writer, rels []one2many, fks []foreignKey) {
+	if *verbose {
+		log.Println("extracting hierarchies")
+	}
+	triples := []rdf.Triple{}
+	for _, levels := range hierarchies(rels, fks) {
+		names := make([]string, len(levels))
+		for i, level := range levels {
+			names[i] = strings.Replace(level[0], "/", ".", 1)
+		}
+		node := hierarchyPrefix + strings.Join(names, "/")
+		entities := map[string]bool{}
+		triples = append(triples, literalTriple(node, vocab.NumLevels, len(levels)))
+		for i, level := range levels {
+			levelNode := node + "/level/" + strconv.Itoa(i)
+			triples = append(triples,
+				iriTriple(node, vocab.HasLevel, levelNode),
+				literalTriple(levelNode, vocab.LevelIndex, i),
+			)
+			for _, col := range level {
+				parts := strings.SplitN(col, "/", 2)
+				entities[parts[0]] = true
+				triples = append(triples, iriTriple(levelNode, vocab.LevelColumn, tablePrefix+parts[0]+colMiddle+parts[1]))
+			}
+		}
+		for entity := range entities {
+			triples = append(triples, iriTriple(tablePrefix+entity, vocab.HasHierarchy, node))
+		}
+	}
+	writeTriples(w, triples)
+}
+
+// hierarchies returns every maximal chain of at least minHierarchyLevels
+// levels, coarsest level first. A level is the set of entity/column names
+// merged through foreign keys, sorted with the referenced column first.
+func hierarchies(rels []one2many, fks []foreignKey) [][][]string {
+	// merge columns joined by a foreign key, the referenced side leads
+	parent := map[string]string{}
+	var find func(string) string
+	find = func(c string) string {
+		p, ok := parent[c]
+		if !ok || p == c {
+			return c
+		}
+		root := find(p)
+		parent[c] = root
+		return root
+	}
+	for _, fk := range fks {
+		for i := range fk.cols {
+			from := find(fk.entity + "/" + fk.cols[i])
+			to := find(fk.refEntity + "/" + fk.refCols[i])
+			if from != to {
+				parent[from] = to
+			}
+		}
+	}
+	members := map[string][]string{}
+	addMember := func(c string) {
+		root := find(c)
+		for _, m := range members[root] {
+			if m == c {
+				return
+			}
+		}
+		members[root] = append(members[root], c)
+	}
+
+	edges := map[string]map[string]bool{}
+	incoming := map[string]int{}
+	for _, r := range rels {
+		one, many := r.entity+"/"+r.one, r.entity+"/"+r.many
+		addMember(one)
+		addMember(many)
+		from, to := find(one), find(many)
+		if from == to || edges[from][to] {
+			continue
+		}
+		if edges[from] == nil {
+			edges[from] = map[string]bool{}
+		}
+		edges[from][to] = true
+		incoming[to]++
+	}
+
+	// drop edges implied by a longer path, a -> c when a -> b -> c
+	reachable := func(from string, to string, skip string) bool {
+		seen := map[string]bool{}
+		stack := []string{}
+		for next := range edges[from] {
+			if next != skip {
+				stack = append(stack, next)
+			}
+		}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if n == to {
+				return true
+			}
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			for next := range edges[n] {
+				stack = append(stack, next)
+			}
+		}
+		return false
+	}
+	nodes := make([]string, 0, len(edges))
+	for n := range edges {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+	for _, from := range nodes {
+		for _, to := range sortedKeys(edges[from]) {
+			if reachable(from, to, to) {
+				delete(edges[from], to)
+				incoming[to]--
+			}
+		}
+	}
+
+	result := [][][]string{}
+	var path []string
+	onPath := map[string]bool{}
+	var walk func(string)
+	walk = func(n string) {
+		if len(result) >= maxHierarchies {
+			return
+		}
+		path = append(path, n)
+		onPath[n] = true
+		extended := false
+		for _, next := range sortedKeys(edges[n]) {
+			if !onPath[next] {
+				extended = true
+				walk(next)
+			}
+		}
+		if !extended && len(path) >= minHierarchyLevels {
+			levels := make([][]string, len(path))
+			for i, p := range path {
+				level := append([]string{}, members[p]...)
+				sort.Slice(level, func(a, b int) bool {
+					// the representative is the referenced column
+					if (level[a] == p) != (level[b] == p) {
+						return level[a] == p
+					}
+					return level[a] < level[b]
+				})
+				levels[i] = level
+			}
+			result = append(result, levels)
+		}
+		onPath[n] = false
+		path = path[:len(path)-1]
+	}
+	for _, n := range nodes {
+		if incoming[n] == 0 {
+			walk(n)
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHierarchies(t *testing.T) {
+	rels := []one2many{
+		{entity: "province", one: "country", many: "name"},
+		{entity: "city", one: "province", many: "name"},
+		{entity: "city", one: "country", many: "name"},
+		{entity: "city", one: "country", many: "province"},
+		{entity: "airport", one: "iata", many: "name"},
+	}
+	fks := []foreignKey{
+		{name: "fk_city_province", entity: "city", cols: []string{"province", "country"}, refEntity: "province", refCols: []string{"name", "country"}},
+	}
+	got := hierarchies(rels, fks)
+	want := [][][]string{
+		{
+			{"province/country", "city/country"},
+			{"province/name", "city/province"},
+			{"city/name"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v got %v", want, got)
+	}
+}
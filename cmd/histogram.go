@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// writeHistogram records how the values of a scalar column are spread, this is
+// what separates a histogram from a bar chart and hints at log scales.
+func writeHistogram(w io.Writer, entity string, col string) {
+	if *histBuckets < 1 {
+		return
+	}
+	var buckets []source.Bucket
+	err := cached(entity, cacheKey("histogram", col, *histKind, *histBuckets), &buckets, func() (err error) {
+		buckets, err = src.Histogram(entity, col, *histBuckets, *histKind == "depth")
+		return err
+	})
+	if err != nil {
+		report(err)
+		return
+	}
+	if len(buckets) == 0 {
+		return
+	}
+	kind := "equi-width"
+	if *histKind == "depth" {
+		kind = "equi-depth"
+	}
+	colIRI := tablePrefix + entity + colMiddle + col
+	hist := colIRI + histSuffix
+	triples := []rdf.Triple{
+		iriTriple(colIRI, vocab.HasHistogram, hist),
+		literalTriple(hist, vocab.HistogramKind, kind),
+		literalTriple(hist, vocab.NumBuckets, len(buckets)),
+	}
+	for i, b := range buckets {
+		node := hist + "/" + strconv.Itoa(i)
+		triples = append(triples,
+			iriTriple(hist, vocab.HasBucket, node),
+			literalTriple(node, vocab.BucketIndex, i),
+			literalTriple(node, vocab.LowerBound, b.Lower),
+			literalTriple(node, vocab.UpperBound, b.Upper),
+			literalTriple(node, vocab.Frequency, b.Count),
+		)
+	}
+	writeTriples(w, triples)
+}
+
+// writeTopValues records the k most frequent values of a discrete column with
+// their frequencies, ranked from 1.
+func writeTopValues(w io.Writer, entity string, col string) {
+	if *topK < 1 {
+		return
+	}
+	var freqs []source.Frequency
+	err := cached(entity, cacheKey("top", col, *topK), &freqs, func() (err error) {
+		freqs, err = src.TopValues(entity, col, *topK)
+		return err
+	})
+	if err != nil {
+		report(err)
+		return
+	}
+	colIRI := tablePrefix + entity + colMiddle + col
+	triples := []rdf.Triple{}
+	for i, f := range freqs {
+		node := colIRI + topMiddle + strconv.Itoa(i+1)
+		triples = append(triples,
+			iriTriple(colIRI, vocab.HasFrequentValue, node),
+			literalTriple(node, vocab.Rank, i+1),
+			literalTriple(node, vocab.Value, f.Value),
+			literalTriple(node, vocab.Frequency, f.Count),
+		)
+	}
+	writeTriples(w, triples)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// jsonMiddle separates the keys of a path from its JSON column in the name
+// payload/->address/->country, which reads payload->'address'->'country'.
+// Its IRI escapes each key as payload/-%3Eaddress/-%3Ecountry.
+const jsonMiddle = "/->"
+
+// virtualColumn returns the IRI of the virtual column of a path under the
+// column IRI col, the keys are escaped as > is not allowed in an IRI.
+func virtualColumn(col string, keys []string) string {
+	for _, k := range keys {
+		col += "/" + url.PathEscape("->"+k)
+	}
+	return col
+}
+
+// jsonPath is a key path found in the sampled documents of a JSON column,
+// profiled like a column of its own.
+type jsonPath struct {
+	Keys []string `json:"keys"`
+	// Type is the most common JSON type of the values, string, number,
+	// boolean, object or array.
+	Type string `json:"type"`
+	// Frequency is the share of the sampled documents holding the path.
+	Frequency   float64 `json:"frequency"`
+	NumDistinct int     `json:"numDistinct"`
+	// Temporal is set when every string value is a date or timestamp.
+	Temporal bool `json:"temporal"`
+	Sampled  int  `json:"sampled"`
+}
+
+// writeJSONPaths samples the JSON columns and writes each key path found in
+// at least -json-min-frequency of the documents as a virtual column of the
+// entity, with a distinct count and dimension from the sample.
+func writeJSONPaths(w io.Writer) {
+	for _, data := range queryColumns() {
+		if data.DataType != "json" && data.DataType != "jsonb" {
+			continue
+		}
+		colIRI := tablePrefix + data.Entity + colMiddle + data.Name
+		var paths []jsonPath
+		err := cached(data.Entity, cacheKey("jsonPaths", data.Name, *jsonRows, *jsonMinFrequency), &paths, func() error {
+			rows, err := src.RandomRows(data.Entity, []string{data.Name}, *jsonRows)
+			if err != nil {
+				return err
+			}
+			docs := make([]string, len(rows))
+			for i, row := range rows {
+				docs[i] = row[0]
+			}
+			paths = profileJSONPaths(docs, *jsonMinFrequency)
+			return nil
+		})
+		if writeUnavailable(w, err, colIRI, vocab.HasJSONPath) {
+			continue
+		}
+		if err != nil {
+			report(err)
+			continue
+		}
+		triples := []rdf.Triple{}
+		for _, p := range paths {
+			virtual := virtualColumn(colIRI, p.Keys)
+			triples = append(triples,
+				iriTriple(tablePrefix+data.Entity, vocab.HasColumn, virtual),
+				iriTriple(colIRI, vocab.HasJSONPath, virtual),
+				literalTriple(virtual, vocab.JSONPath, sqlJSONPath(p.Keys)),
+				literalTriple(virtual, vocab.JSONValueType, p.Type),
+				literalTriple(virtual, vocab.PathFrequency, p.Frequency),
+				literalTriple(virtual, vocab.SampleSize, p.Sampled),
+				literalTriple(virtual, vocab.NumDistinct, p.NumDistinct),
+			)
+			if dim := jsonDimension(p); dim != "" {
+				triples = append(triples, iriTriple(virtual, vocab.HasDimension, dim))
+			}
+		}
+		writeTriples(w, triples)
+	}
+}
+
+// jsonDimension classifies a path the way writeScalarOrDiscrete classifies
+// a column, objects and arrays are left unclassified.
+func jsonDimension(p jsonPath) string {
+	switch {
+	case p.Type == "object" || p.Type == "array":
+		return ""
+	case p.NumDistinct <= 100:
+		return discreteDimension
+	case p.Type == "number":
+		return scalarDimension
+	case p.Type == "string" && p.Temporal:
+		return temporalDimension
+	}
+	return ""
+}
+
+// jsonIdentifier is a key that needs no quoting in a SQL/JSON path.
+var jsonIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqlJSONPath writes keys as a SQL/JSON path such as $.address."zip code",
+// which Postgres, MySQL and SQLite all read.
+func sqlJSONPath(keys []string) string {
+	path := "$"
+	for _, k := range keys {
+		if !jsonIdentifier.MatchString(k) {
+			b, _ := json.Marshal(k)
+			k = string(b)
+		}
+		path += "." + k
+	}
+	return path
+}
+
+// profileJSONPaths walks the objects of the sampled documents, NULLs and
+// values that are not JSON are left out. Arrays are values, their elements
+// are not walked.
+func profileJSONPaths(docs []string, minFrequency float64) []jsonPath {
+	type stats struct {
+		keys     []string
+		seen     int
+		types    map[string]int
+		values   map[string]bool
+		temporal bool
+	}
+	found := map[string]*stats{}
+	sampled := 0
+	var walk func(obj map[string]interface{}, prefix []string)
+	walk = func(obj map[string]interface{}, prefix []string) {
+		for k, v := range obj {
+			keys := append(append([]string{}, prefix...), k)
+			name := strings.Join(keys, jsonMiddle)
+			s, ok := found[name]
+			if !ok {
+				s = &stats{keys: keys, types: map[string]int{}, values: map[string]bool{}, temporal: true}
+				found[name] = s
+			}
+			s.seen++
+			typ, text := jsonValue(v)
+			if typ == "null" {
+				continue
+			}
+			s.types[typ]++
+			s.values[text] = true
+			if typ == "string" && !isTime(text) {
+				s.temporal = false
+			}
+			if child, ok := v.(map[string]interface{}); ok {
+				walk(child, keys)
+			}
+		}
+	}
+	for _, doc := range docs {
+		if doc == source.NullValue {
+			continue
+		}
+		d := json.NewDecoder(strings.NewReader(doc))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			continue
+		}
+		sampled++
+		if obj, ok := v.(map[string]interface{}); ok {
+			walk(obj, nil)
+		}
+	}
+
+	paths := []jsonPath{}
+	for _, s := range found {
+		freq := float64(s.seen) / float64(sampled)
+		if freq < minFrequency || len(s.types) == 0 {
+			continue
+		}
+		p := jsonPath{Keys: s.keys, Frequency: freq, NumDistinct: len(s.values), Sampled: sampled}
+		for typ, n := range s.types {
+			if n > s.types[p.Type] || n == s.types[p.Type] && typ < p.Type {
+				p.Type = typ
+			}
+		}
+		p.Temporal = p.Type == "string" && s.temporal
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.Join(paths[i].Keys, jsonMiddle) < strings.Join(paths[j].Keys, jsonMiddle)
+	})
+	return paths
+}
+
+// jsonValue returns the JSON type of a decoded value and its text, objects
+// and arrays are compacted so equal ones count once.
+func jsonValue(v interface{}) (string, string) {
+	switch x := v.(type) {
+	case nil:
+		return "null", ""
+	case string:
+		return "string", x
+	case json.Number:
+		return "number", x.String()
+	case bool:
+		return "boolean", fmt.Sprint(x)
+	case map[string]interface{}:
+		b, _ := json.Marshal(x)
+		return "object", string(b)
+	}
+	b, _ := json.Marshal(v)
+	return "array", string(b)
+}
+
+// isTime reports whether a string is a date or an RFC 3339 timestamp.
+func isTime(v string) bool {
+	for _, layout := range []string{"2006-01-02", time.RFC3339, time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if _, err := time.Parse(layout, v); err == nil {
+			return true
+		}
+	}
+	return false
+}
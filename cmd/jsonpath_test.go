@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+)
+
+func TestProfileJSONPaths(t *testing.T) {
+	docs := []string{
+		`{"country": "DE", "amount": 3, "at": "2019-01-02", "address": {"zip": "10115"}}`,
+		`{"country": "FR", "amount": 4.5, "at": "2019-01-03", "rare": true}`,
+		`{"country": "DE", "amount": null, "at": "2019-01-04", "tags": [1, 2]}`,
+		`not json`,
+		source.NullValue,
+	}
+	got := profileJSONPaths(docs, 0.5)
+	want := []jsonPath{
+		{Keys: []string{"amount"}, Type: "number", Frequency: 1, NumDistinct: 2, Sampled: 3},
+		{Keys: []string{"at"}, Type: "string", Frequency: 1, NumDistinct: 3, Temporal: true, Sampled: 3},
+		{Keys: []string{"country"}, Type: "string", Frequency: 1, NumDistinct: 2, Sampled: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %+v\ngot    %+v", want, got)
+	}
+	if got := len(profileJSONPaths(docs, 0.3)); got != 7 {
+		t.Errorf("wanted 7 paths seen at least once got %d", got)
+	}
+	if got := sqlJSONPath([]string{"address", "zip code"}); got != `$.address."zip code"` {
+		t.Errorf("wanted a quoted key got %s", got)
+	}
+	if got := virtualColumn("c", []string{"address", "zip code"}); got != "c/-%3Eaddress/-%3Ezip%20code" {
+		t.Errorf("wanted an escaped IRI got %s", got)
+	}
+}
+
+// TestExtractJSONPaths checks the frequent paths of a JSON column become
+// virtual columns of its entity.
+func TestExtractJSONPaths(t *testing.T) {
+	old := *jsonPaths
+	defer func() { *jsonPaths = old }()
+	*jsonPaths = true
+	g := extractSQLite(t,
+		`create table event (id integer primary key, payload json)`,
+		`insert into event values (1, '{"country": "DE", "user": {"age": 31}}'), (2, '{"country": "FR", "user": {"age": 45}}'), (3, null)`,
+	)
+
+	event := tablePrefix + "event"
+	payload := event + colMiddle + "payload"
+	country := payload + "/-%3Ecountry"
+	age := payload + "/-%3Euser/-%3Eage"
+	hasTriples(t, g,
+		iriTriple(event, vocab.HasColumn, country),
+		iriTriple(payload, vocab.HasJSONPath, country),
+		iriTriple(payload, vocab.HasJSONPath, age),
+		literalTriple(country, vocab.NumDistinct, 2),
+		iriTriple(country, vocab.HasDimension, vocab.DiscreteDimension),
+		literalTriple(age, vocab.JSONPath, "$.user.age"),
+		literalTriple(age, vocab.JSONValueType, "number"),
+	)
+}
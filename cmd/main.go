@@ -1,20 +1,48 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+	_ "github.com/go-sql-driver/mysql"
 	"github.com/knakk/rdf"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"sort"
 	"strings"
 )
 
 // note file suffix for a n triple is .nt
 var fileName = flag.String("f", "", "filename to save N-Triple DB")
 var verbose = flag.Bool("v", false, "output extra logging")
+var histBuckets = flag.Int("buckets", 10, "number of histogram buckets for scalar columns")
+var histKind = flag.String("histogram", "width", "histogram kind for scalar columns, width or depth")
+var topK = flag.Int("topk", 5, "number of most frequent values to record for discrete columns")
+var fdDiscovery = flag.Bool("fd", false, "discover functional dependencies with multi-column left hand sides")
+var fdWidth = flag.Int("fd-width", 3, "maximum number of columns on the left hand side of a functional dependency")
+var fdError = flag.Float64("fd-error", 0, "maximum g3 error for approximate functional dependencies, 0 finds exact ones only")
+var fdRows = flag.Int("fd-rows", 10000, "number of rows sampled per table for functional dependency discovery, 0 reads every row")
+var inferRecursive = flag.Bool("infer-recursive", true, "look for undeclared self references from columns to their own table's key")
+var corrSample = flag.Float64("corr-sample", 0, "percentage of rows to sample when correlating scalar columns, 0 uses every row")
+var cacheFile = flag.String("cache", "", "file keeping statistics between runs so unchanged tables are not profiled again")
+var cacheFingerprint = flag.String("cache-fingerprint", "stats", "how table changes are detected for the cache, stats for the table statistics or checksum to hash every row")
+var keyWidth = flag.Int("key-width", 2, "most columns in a candidate key discovered for tables without a primary key, 0 only reads UNIQUE constraints and indexes")
+var driver = flag.String("driver", "postgres", "database to profile, postgres, mysql, sqlite, csv or ddl")
+var skipViews = flag.String("skip-views", "", "comma separated views and materialized views to list without profiling their data, * for all of them")
+var listPartitions = flag.Bool("partitions", false, "list the partitions and inheritance children of each table with their bounds")
+var jsonPaths = flag.Bool("json-paths", false, "sample JSON columns and profile their frequent key paths as virtual columns")
+var jsonRows = flag.Int("json-rows", 10000, "number of rows sampled per JSON column, 0 reads every row")
+var jsonMinFrequency = flag.Float64("json-min-frequency", 0.1, "share of the sampled documents a key path must appear in to be profiled")
+var memoryRows = flag.Int("memory-rows", 1000000, "rows of a CSV file held in memory, larger files are read again for every statistic and spill to temporary files")
 
 // useful reading material
 // https://newfivefour.com/postgresql-information-schema.html
@@ -29,55 +57,199 @@ var port = os.Getenv("VIS_MONDIAL_PORT")
 var sslmode = os.Getenv("VIS_MONDIAL_SSLMODE")
 
 var db *sql.DB
+var connStr string
+var src source.Source
 
 const (
-	rootPrefix        = "http://dooodle/"
-	colMiddle         = "/column/"
+	rootPrefix        = vocab.Root
+	colMiddle         = vocab.ColumnMiddle
 	compoundMiddle    = "/compound/"
-	one2mMiddle    	  = "/one2many/"
-	m2mMiddle    	  = "/many2many/"
-	tablePrefix       = rootPrefix + "entity/"
-	predPrefix        = rootPrefix + "predicate/"
-	dataTypePrefix    = rootPrefix + "dataType/"
-	discreteDimension = rootPrefix + "dimension/discrete"
-	scalarDimension   = rootPrefix + "dimension/scalar"
+	one2mMiddle       = "/one2many/"
+	m2mMiddle         = "/many2many/"
+	corrMiddle        = "/correlation/"
+	fdMiddle          = "/fd/"
+	candidateMiddle   = "/candidate/"
+	fkMiddle          = "/fk/"
+	partitionMiddle   = "/partition/"
+	hierarchyPrefix   = rootPrefix + "hierarchy/"
+	recursiveMiddle   = "/recursive/"
+	kindPrefix        = rootPrefix + "kind/"
+	typeKindPrefix    = rootPrefix + "typeKind/"
+	treeStructure     = rootPrefix + "structure/tree"
+	graphStructure    = rootPrefix + "structure/graph"
+	histSuffix        = "/histogram"
+	topMiddle         = "/top/"
+	tablePrefix       = vocab.EntityPrefix
+	dataTypePrefix    = vocab.DataTypePrefix
+	discreteDimension = vocab.DiscreteDimension
+	scalarDimension   = vocab.ScalarDimension
+	temporalDimension = vocab.TemporalDimension
 	similarCond       = rootPrefix + "cond/similar"
 	complete          = rootPrefix + "cond/complete"
 
 	similarHeuristic = 15
+	// a scalar column is drawn on a log axis past either of these
+	logSkewHeuristic  = 1
+	logRangeHeuristic = 1000
+	// shorter chains are already covered by hasOne2ManyKey
+	minHierarchyLevels = 3
 )
 
-func init() {
-	connStr := fmt.Sprintf("user=%s dbname=%s password=%s host=%s port=%s sslmode=%s",
-		user, dbname, password, host, port, sslmode)
+//openSource connects to the database chosen with -driver the first time it
+//is needed, so the subcommands reading -in never open or parse the source
+func openSource() {
+	if src != nil {
+		return
+	}
 	var err error
-	db, err = sql.Open("postgres", connStr)
+	switch *driver {
+	case "postgres":
+		connStr = fmt.Sprintf("user=%s dbname=%s password=%s host=%s port=%s sslmode=%s",
+			user, dbname, password, host, port, sslmode)
+		db, err = sql.Open("postgres", connStr)
+		src = source.NewPostgres(db)
+	case "mysql":
+		// sslmode follows the libpq names, anything but disable asks for TLS
+		tls := "true"
+		switch sslmode {
+		case "", "disable":
+			tls = "false"
+		case "allow", "prefer", "require":
+			tls = "skip-verify"
+		}
+		connStr = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?tls=%s", user, password, host, port, dbname, tls)
+		db, err = sql.Open("mysql", connStr)
+		src = source.NewMySQL(db)
+	case "sqlite":
+		// the database name is the path of the file, opened read only
+		connStr = "file:" + dbname + "?mode=ro"
+		db, err = sql.Open("sqlite3", connStr)
+		src = source.NewSQLite(db)
+	case "ddl":
+		// the database name is the path of a schema only dump, there is no data
+		var f *os.File
+		if f, err = os.Open(dbname); err == nil {
+			src, err = source.ParseDDL(f)
+			f.Close()
+		}
+	case "csv":
+		// the database name lists the files or directories, comma separated
+		src, err = source.NewFiles(strings.Split(dbname, ","), *memoryRows)
+	default:
+		log.Fatalf("unknown driver %s, expected postgres, mysql, sqlite, csv or ddl", *driver)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *skipViews != "" {
+		src = viewSkipper{src}
+	}
 }
 
 func main() {
 	flag.Parse()
-	var w io.Writer = os.Stdout
-	if *fileName != "" {
-		f, err := os.Create(*fileName)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer f.Close()
-		w = f
+	switch flag.Arg(0) {
+	case "recommend":
+		runRecommend(flag.Args()[1:])
+		return
+	case "query":
+		runQuery(flag.Args()[1:])
+		return
+	case "serve":
+		runServe(flag.Args()[1:])
+		return
+	case "diff":
+		runDiff(flag.Args()[1:])
+		return
+	case "watch":
+		runWatch(flag.Args()[1:])
+		return
+	}
+	w, closer := output()
+	err := extract(w)
+	closer.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+//output opens the file given with -f, or stdout when there is none
+func output() (io.Writer, io.Closer) {
+	if *fileName == "" {
+		return os.Stdout, ioutil.NopCloser(nil)
 	}
+	f, err := os.Create(*fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return f, f
+}
+
+//extractErr is the first error met by the extraction running
+var extractErr error
+
+//report prints an error of an extraction phase, which carries on with what
+//it has, and keeps the first one as the error of the extraction
+func report(err error) {
+	fmt.Println(err)
+	if extractErr == nil {
+		extractErr = err
+	}
+}
+
+//extract writes out the triples for the whole database, it returns the first
+//error reported so a partial graph is not taken for the whole one
+func extract(w io.Writer) error {
 	if *verbose {
 		fmt.Printf("starting db graph extractor for %s on %s:%s\n", dbname, host, port)
 	}
+	openSource()
+	extractErr = nil
+	cache = openCache()
+	defer cache.save()
+	unavailableMarked = map[string]bool{}
+	skipped = skippedViews()
 	//write out the triples
+	writeEntityKinds(w)
 	writeTableColS(w)
-	writeColsDataType(w)
-	counts := writeScalarOrDiscrete(w, 100)
-	writeKeys(w)
-	_ = writeCompoundKeys(w, counts)
-	writeOneOrManyToManyRels(w)
+	writeConstraints(w)
+	writePartitions(w)
+	types := writeColsDataType(w)
+	udts := writeTypes(w)
+	counts, dims := writeScalarOrDiscrete(w, 100, udts)
+	if *jsonPaths {
+		writeJSONPaths(w)
+	}
+	pks := writeCandidateKeys(w)
+	writeKeys(w, pks)
+	keys := writeCompoundKeys(w, counts, pks)
+	cols, rels := writeOneOrManyToManyRels(w, dims)
+	if *fdDiscovery {
+		writeFunctionalDependencies(w, cols)
+	}
+	fks := writeForeignKeys(w)
+	writeHierarchies(w, rels, fks)
+	writeRecursiveRels(w, keys, fks, types)
+	writeLineage(w)
+	return extractErr
+}
+
+//loadGraph reads a saved N-Triple file into memory, or extracts the database
+//when no file is given
+func loadGraph(fileName string) (*graph.Graph, error) {
+	if fileName != "" {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return graph.Load(f)
+	}
+	buf := bytes.Buffer{}
+	if err := extract(&buf); err != nil {
+		return nil, err
+	}
+	return graph.Load(&buf)
 }
 
 //some reference definitions from the principal paper.
@@ -88,80 +260,85 @@ func main() {
 //natural numeric ordering (e.g. integers, floats, timestamps, dates); these are
 //represented by a channel associated with a mark.
 
-//when extracting use limit to decide if its scalar or discrete
-func writeScalarOrDiscrete(w io.Writer, limit int) map[string]int {
+//when extracting use limit to decide if its scalar or discrete, enums are
+//always discrete and ordered by their labels
+//the returned maps hold the distinct count and dimension for each table/column
+func writeScalarOrDiscrete(w io.Writer, limit int, udts map[string]source.Type) (map[string]int, map[string]string) {
 	counts := map[string]int{}
-	q := `SELECT 
-		  columns.table_name,
-		  columns.column_name,
-		  columns.data_type,
-		  columns.udt_name
-	FROM information_schema.columns
-	LEFT JOIN information_schema.tables ON columns.table_name = tables.table_name
-	WHERE tables.table_schema = 'public' 
-	`
-
-	rows, _ := db.Query(q)
-
-	data := struct {
-		tableName string
-		colName   string
-		dataType  string
-		udtName   string
-	}{}
-
-	defer rows.Close()
+	dims := map[string]string{}
 	triples := []rdf.Triple{}
 
-	for rows.Next() {
-		err := rows.Scan(&(data.tableName), &(data.colName), &(data.dataType), &(data.udtName))
-		if err != nil {
-			fmt.Println(err)
+	for _, data := range queryColumns() {
+		count, err := queryNumDistinct(data.Entity, data.Name)
+		enum := udts[data.Native].Kind == source.EnumType
+		if enum && errors.Is(err, source.ErrUnavailable) {
+			// the labels make it discrete without counting
+			col := tablePrefix + data.Entity + colMiddle + data.Name
+			triples = append(triples,
+				iriTriple(col, vocab.HasDimension, discreteDimension),
+				literalTriple(col, vocab.IsOrdinal, true),
+			)
+			dims[data.Entity+"/"+data.Name] = discreteDimension
+			writeUnavailable(w, err, col, vocab.NumDistinct)
+			continue
+		}
+		if writeUnavailable(w, err, tablePrefix+data.Entity+colMiddle+data.Name, vocab.NumDistinct, vocab.HasDimension) {
+			continue
 		}
-
-		subQuery := fmt.Sprintf("SELECT COUNT (DISTINCT %s) FROM %s", data.colName, data.tableName)
-		subRows, err := db.Query(subQuery)
 		if err != nil {
-			fmt.Println(err)
+			report(err)
+			continue
 		}
-
-		for subRows.Next() {
-			var count int
-			err := subRows.Scan(&count)
+		subject, _ := rdf.NewIRI(tablePrefix + data.Entity + colMiddle + data.Name)
+		pred, _ := rdf.NewIRI(vocab.NumDistinct)
+		object, _ := rdf.NewLiteral(count)
+		triple := rdf.Triple{
+			Subj: subject,
+			Pred: pred,
+			Obj:  object,
+		}
+		triples = append(triples, triple)
+		counts[data.Entity+"/"+data.Name] = count
+		dSubject, _ := rdf.NewIRI(tablePrefix + data.Entity + colMiddle + data.Name)
+		dPred, _ := rdf.NewIRI(vocab.HasDimension)
+		var dObject rdf.IRI
+		switch {
+		case count <= 100 || enum:
+			dObject, err = rdf.NewIRI(discreteDimension)
 			if err != nil {
-				fmt.Println(err)
+				report(err)
 			}
-			subject, _ := rdf.NewIRI(tablePrefix + data.tableName + colMiddle + data.colName)
-			pred, _ := rdf.NewIRI(predPrefix + "numDistinct")
-			object, _ := rdf.NewLiteral(count)
-			triple := rdf.Triple{
-				Subj: subject,
-				Pred: pred,
-				Obj:  object,
-			}
-			triples = append(triples, triple)
-			counts[data.tableName+"/"+data.colName] = count
-			dSubject, _ := rdf.NewIRI(tablePrefix + data.tableName + colMiddle + data.colName)
-			dPred, _ := rdf.NewIRI(predPrefix + "hasDimension")
-			var dObject rdf.IRI
-			switch {
-			case count <= 100:
-				dObject, err = rdf.NewIRI(discreteDimension)
-				if err != nil {
-					fmt.Println(err)
-				}
-			case !strings.Contains(data.colName,"latitude") && !strings.Contains(data.colName, "longitude") && (data.dataType == "integer" || data.dataType == "numeric"): // need a better way to exclude geo data like this
-				dObject, err = rdf.NewIRI(scalarDimension)
-				if err != nil {
-					fmt.Println(err)
-				}
+		case !strings.Contains(data.Name, "latitude") && !strings.Contains(data.Name, "longitude") && (data.DataType == "integer" || data.DataType == "numeric"): // need a better way to exclude geo data like this
+			dObject, err = rdf.NewIRI(scalarDimension)
+			if err != nil {
+				report(err)
 			}
-			dtriple := rdf.Triple{
-				Subj: dSubject,
-				Pred: dPred,
-				Obj:  dObject,
+		case data.DataType == "date" || strings.HasPrefix(data.DataType, "timestamp"):
+			dObject, err = rdf.NewIRI(temporalDimension)
+			if err != nil {
+				report(err)
 			}
-			triples = append(triples, dtriple)
+		}
+		if dObject == (rdf.IRI{}) {
+			// neither discrete, scalar nor temporal, leave the column unclassified
+			continue
+		}
+		dtriple := rdf.Triple{
+			Subj: dSubject,
+			Pred: dPred,
+			Obj:  dObject,
+		}
+		triples = append(triples, dtriple)
+		if enum {
+			triples = append(triples, literalTriple(dSubject.String(), vocab.IsOrdinal, true))
+		}
+		dims[data.Entity+"/"+data.Name] = dObject.String()
+		switch dObject.String() {
+		case scalarDimension:
+			writeHistogram(w, data.Entity, data.Name)
+			writeDistribution(w, data.Entity, data.Name)
+		case discreteDimension:
+			writeTopValues(w, data.Entity, data.Name)
 		}
 	}
 
@@ -170,36 +347,76 @@ func writeScalarOrDiscrete(w io.Writer, limit int) map[string]int {
 		w.Write([]byte(str))
 	}
 
-	return counts
+	return counts, dims
 }
 
-func writeTableColS(w io.Writer) {
-	q := `SELECT columns.table_name,
-		  columns.column_name
-	FROM information_schema.columns
-	LEFT JOIN information_schema.tables ON columns.table_name = tables.table_name
-	WHERE tables.table_schema = 'public' 
-	`
+// example sql
+// select count(distinct population) from city;
+func queryNumDistinct(entity string, col string) (int, error) {
+	var count int
+	err := cached(entity, cacheKey("numDistinct", col), &count, func() error {
+		var err error
+		count, err = src.NumDistinct(entity, col)
+		return err
+	})
+	return count, err
+}
 
-	rows, _ := db.Query(q)
+// queryMaxDistinctPer returns the largest number of distinct values of other
+// found with a single value of col, 1 when col determines other. Empty tables
+// give 0.
+//
+// example sql
+// select max(output) from (select iata_code, count(distinct city) as output from airport group by iata_code) as Derived;
+func queryMaxDistinctPer(entity string, col string, other string) (int, error) {
+	var max int
+	err := cached(entity, cacheKey("maxDistinctPer", col, other), &max, func() error {
+		var err error
+		max, err = src.MaxDistinctPer(entity, col, other)
+		return err
+	})
+	return max, err
+}
 
-	data := struct {
-		tableName string
-		colName   string
-	}{}
+//queryColumns lists the columns of every table, errors are printed and leave
+//the list empty
+func queryColumns() []source.Column {
+	cols, err := src.Columns()
+	if err != nil {
+		report(err)
+	}
+	return cols
+}
 
-	defer rows.Close()
-	triples := []rdf.Triple{}
+//queryPrimaryKeys returns the primary key columns of each table in key order
+func queryPrimaryKeys() map[string][]string {
+	keys, err := src.PrimaryKeys()
+	if err != nil {
+		report(err)
+	}
+	if keys == nil {
+		keys = map[string][]string{}
+	}
+	return keys
+}
 
-	for rows.Next() {
-		err := rows.Scan(&(data.tableName), &(data.colName))
-		if err != nil {
-			fmt.Println(err)
-		}
+//entityNames returns the tables of keys in name order
+func entityNames(keys map[string][]string) []string {
+	names := []string{}
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeTableColS(w io.Writer) {
+	triples := []rdf.Triple{}
 
-		subject, _ := rdf.NewIRI(tablePrefix + data.tableName)
-		pred, _ := rdf.NewIRI(predPrefix + "hasColumn")
-		object, _ := rdf.NewIRI(tablePrefix + data.tableName + colMiddle + data.colName)
+	for _, data := range queryColumns() {
+		subject, _ := rdf.NewIRI(tablePrefix + data.Entity)
+		pred, _ := rdf.NewIRI(vocab.HasColumn)
+		object, _ := rdf.NewIRI(tablePrefix + data.Entity + colMiddle + data.Name)
 		triple := rdf.Triple{
 			Subj: subject,
 			Pred: pred,
@@ -214,43 +431,21 @@ func writeTableColS(w io.Writer) {
 	}
 }
 
-func writeKeys(w io.Writer) {
-	q := `select tc.table_schema, tc.table_name, kc.column_name
-             from information_schema.table_constraints tc
-             join information_schema.key_column_usage kc 
-             on kc.table_name = tc.table_name and kc.table_schema = tc.table_schema and kc.constraint_name = tc.constraint_name
-             where tc.constraint_type = 'PRIMARY KEY'
-             and kc.ordinal_position is not null
-             order by tc.table_schema,
-             tc.table_name,
-             kc.position_in_unique_constraint;`
-
-	rows, _ := db.Query(q)
-
-	data := struct {
-		tableSchema string
-		tableName   string
-		colName     string
-	}{}
-
-	defer rows.Close()
+func writeKeys(w io.Writer, keys map[string][]string) {
 	triples := []rdf.Triple{}
 
-	for rows.Next() {
-		err := rows.Scan(&(data.tableSchema), &(data.tableName), &(data.colName))
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		subject, _ := rdf.NewIRI(tablePrefix + data.tableName)
-		pred, _ := rdf.NewIRI(predPrefix + "hasKey")
-		object, _ := rdf.NewIRI(tablePrefix + data.tableName + colMiddle + data.colName)
-		triple := rdf.Triple{
-			Subj: subject,
-			Pred: pred,
-			Obj:  object,
+	for _, tableName := range entityNames(keys) {
+		for _, colName := range keys[tableName] {
+			subject, _ := rdf.NewIRI(tablePrefix + tableName)
+			pred, _ := rdf.NewIRI(vocab.HasKey)
+			object, _ := rdf.NewIRI(tablePrefix + tableName + colMiddle + colName)
+			triple := rdf.Triple{
+				Subj: subject,
+				Pred: pred,
+				Obj:  object,
+			}
+			triples = append(triples, triple)
 		}
-		triples = append(triples, triple)
 	}
 
 	for _, t := range triples {
@@ -260,50 +455,14 @@ func writeKeys(w io.Writer) {
 
 }
 
-func writeCompoundKeys(w io.Writer, counts map[string]int) map[string][]string {
-	q := `select tc.table_schema, tc.table_name, kc.column_name
-             from information_schema.table_constraints tc
-             join information_schema.key_column_usage kc 
-             on kc.table_name = tc.table_name and kc.table_schema = tc.table_schema and kc.constraint_name = tc.constraint_name
-             where tc.constraint_type = 'PRIMARY KEY'
-             and kc.ordinal_position is not null
-             order by tc.table_schema,
-             tc.table_name,
-             kc.position_in_unique_constraint;`
-
-	rows, _ := db.Query(q)
-
-	data := struct {
-		tableSchema string
-		tableName   string
-		colName     string
-	}{}
-
-	defer rows.Close()
-
-	//collect the keys
-
-	keys := map[string][]string{}
-	for rows.Next() {
-		err := rows.Scan(&(data.tableSchema), &(data.tableName), &(data.colName))
-		if err != nil {
-			fmt.Println(err)
-		}
-		vals, ok := keys[data.tableName]
-		if !ok {
-			vals = []string{}
-		}
-		vals = append(vals, data.colName)
-		keys[data.tableName] = vals
-	}
-
+func writeCompoundKeys(w io.Writer, counts map[string]int, keys map[string][]string) map[string][]string {
 	singleTriples := []rdf.Triple{}
 	for k, v := range keys {
 		// need to write out all possible poirs of keys
 		if len(v) == 1 {
 			//single key
 			subject, _ := rdf.NewIRI(tablePrefix + k)
-			pred, _ := rdf.NewIRI(predPrefix + "hasSingleKey")
+			pred, _ := rdf.NewIRI(vocab.HasSingleKey)
 			object, _ := rdf.NewIRI(tablePrefix + k + colMiddle + v[0])
 			triple := rdf.Triple{
 				Subj: subject,
@@ -324,68 +483,52 @@ func writeCompoundKeys(w io.Writer, counts map[string]int) map[string][]string {
 	return keys
 }
 
-func writeOneOrManyToManyRels(w io.Writer) map[string][]string {
+//the returned map holds the columns of each table along with the one to many
+//relationships found between them
+func writeOneOrManyToManyRels(w io.Writer, dims map[string]string) (map[string][]string, []one2many) {
 	//compare all possible cols for all tables
 	if *verbose {
 		log.Println("extracting one to many")
 	}
 
-	q := `SELECT columns.table_name,
-		  columns.column_name
-	FROM information_schema.columns
-	LEFT JOIN information_schema.tables ON columns.table_name = tables.table_name
-	WHERE tables.table_schema = 'public' 
-	`
-
-	rows, _ := db.Query(q)
-
-	data := struct {
-		tableName   string
-		colName     string
-	}{}
-
-	defer rows.Close()
-
-	//collect the keys
+	//collect the columns of each table
 	keys := map[string][]string{}
-	for rows.Next() {
-		err := rows.Scan(&(data.tableName), &(data.colName))
-		if err != nil {
-			fmt.Println(err)
-		}
-		vals, ok := keys[data.tableName]
-		if !ok {
-			vals = []string{}
-		}
-		vals = append(vals, data.colName)
-		keys[data.tableName] = vals
+	for _, data := range queryColumns() {
+		keys[data.Entity] = append(keys[data.Entity], data.Name)
 	}
 
 	singleTriples := []rdf.Triple{}
+	rels := []one2many{}
 	for k, v := range keys {
 		if len(v) > 1 {
-			subsetsForOneOrManyToMany(w, k, v)
+			rels = append(rels, subsetsForOneOrManyToMany(w, k, v, dims)...)
 		}
 	}
 	for _, t := range singleTriples {
 		str := t.Serialize(rdf.NTriples)
 		w.Write([]byte(str))
 	}
-	return keys
+	return keys, rels
 }
 
-func subsetsForOneOrManyToMany(w io.Writer, entity string, keys []string) {
+func subsetsForOneOrManyToMany(w io.Writer, entity string, keys []string, dims map[string]string) []one2many {
 	if *verbose {
 		log.Printf("entering subset streamer for %s:%v",entity,keys)
 	}
 	n := len(keys)
 	var subset = make([]string, 0, n)
 	triples := []rdf.Triple{}
+	rels := []one2many{}
 	var search func(int)
 	search = func(i int) {
 		if i == n {
 			if len(subset) == 2 {
-				writeOneOrManyToManyItem(w, entity, subset[0], subset[1])
+				if rel := writeOneOrManyToManyItem(w, entity, subset[0], subset[1]); rel != nil {
+					rels = append(rels, *rel)
+				}
+				if dims[entity+"/"+subset[0]] == scalarDimension && dims[entity+"/"+subset[1]] == scalarDimension {
+					writeCorrelationItem(w, entity, subset[0], subset[1])
+				}
 			}
 			return
 		}
@@ -402,46 +545,30 @@ func subsetsForOneOrManyToMany(w io.Writer, entity string, keys []string) {
 		str := t.Serialize(rdf.NTriples)
 		w.Write([]byte(str))
 	}
-
+	return rels
 }
 
 //example sql
 //select iata_code, count(distinct city)  from airport group by iata_code having count(distinct city) > 1;
 //select max(output) from (select iata_code, count(distinct city) as output from airport group by iata_code) as Derived ;
-func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 string) {
+func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 string) *one2many {
 	if *verbose {
 		log.Printf("entering one to many checker for %s:%s,%s",entity,col1,col2)
 	}
 
-	q1 := fmt.Sprintf("select max(output) from (select %s, count(distinct %s) as output from %s group by %s) as Derived",col1,col2,entity,col1)
-	q2 := fmt.Sprintf("select max(output) from (select %s, count(distinct %s) as output from %s group by %s) as Derived",col2,col1,entity,col2)
-
-	i1,i2 := 0,0
-	rows1, err := db.Query(q1)
-	if err != nil {
-		fmt.Println(err)
+	i1, err := queryMaxDistinctPer(entity, col1, col2)
+	if writeUnavailable(w, err, tablePrefix+entity, vocab.HasOne2ManyKey, vocab.HasMany2ManyKey) {
+		return nil
 	}
-	rows2, err := db.Query(q2)
 	if err != nil {
-		fmt.Println(err)
-	}
-	defer rows1.Close()
-	defer rows2.Close()
-
-	for rows1.Next() {
-		err := rows1.Scan(&i1)
-		if err != nil && *verbose { // could be null
-			fmt.Println(err)
-		}
+		report(err)
 	}
-
-	for rows2.Next() {
-		err := rows2.Scan(&i2)
-		if err != nil && *verbose { // could be null
-			fmt.Println(err)
-		}
+	i2, err := queryMaxDistinctPer(entity, col2, col1)
+	if err != nil {
+		report(err)
 	}
 	triples := []rdf.Triple{}
+	var rel *one2many
 	if *verbose {
 		log.Printf("%s -> %v",col1,i1)
 		log.Printf("%s -> %v",col2,i2)
@@ -449,8 +576,9 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 	switch {
 	//one to many key relationships
 	case i1 == 1 && i2 > 1 :
+		rel = &one2many{entity: entity, one: col2, many: col1}
 		subject, _ := rdf.NewIRI(tablePrefix + entity)
-		pred, _ := rdf.NewIRI(predPrefix + "hasOne2ManyKey")
+		pred, _ := rdf.NewIRI(vocab.HasOne2ManyKey)
 		object, _ := rdf.NewIRI(tablePrefix + entity + one2mMiddle + col2 + "/" + col1)
 		triple := rdf.Triple{
 			Subj: subject,
@@ -459,7 +587,7 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 		}
 		triples = append(triples, triple)
 		subjectOne, _ := rdf.NewIRI(tablePrefix + entity + one2mMiddle + col2 + "/" + col1)
-		predOne, _ := rdf.NewIRI(predPrefix + "hasOneKey")
+		predOne, _ := rdf.NewIRI(vocab.HasOneKey)
 		objectOne, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col2)
 		tripleOne := rdf.Triple{
 			Subj: subjectOne,
@@ -468,7 +596,7 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 		}
 		triples = append(triples, tripleOne)
 		subjectMany, _ := rdf.NewIRI(tablePrefix + entity + one2mMiddle + col2 + "/" + col1)
-		predMany, _ := rdf.NewIRI(predPrefix + "hasManyKey")
+		predMany, _ := rdf.NewIRI(vocab.HasManyKey)
 		objectMany, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col1)
 		tripleMany := rdf.Triple{
 			Subj: subjectMany,
@@ -479,8 +607,9 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 
 
 	case i2 == 1 && i1 > 1 :
+		rel = &one2many{entity: entity, one: col1, many: col2}
 		subject, _ := rdf.NewIRI(tablePrefix + entity)
-		pred, _ := rdf.NewIRI(predPrefix + "hasOne2ManyKey")
+		pred, _ := rdf.NewIRI(vocab.HasOne2ManyKey)
 		object, _ := rdf.NewIRI(tablePrefix + entity + one2mMiddle + col1 + "/" + col2)
 		triple := rdf.Triple{
 			Subj: subject,
@@ -489,7 +618,7 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 		}
 		triples = append(triples, triple)
 		subjectOne, _ := rdf.NewIRI(tablePrefix + entity + one2mMiddle + col1 + "/" + col2)
-		predOne, _ := rdf.NewIRI(predPrefix + "hasOneKey")
+		predOne, _ := rdf.NewIRI(vocab.HasOneKey)
 		objectOne, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col1)
 		tripleOne := rdf.Triple{
 			Subj: subjectOne,
@@ -498,7 +627,7 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 		}
 		triples = append(triples, tripleOne)
 		subjectMany, _ := rdf.NewIRI(tablePrefix + entity + one2mMiddle + col1 + "/" + col2)
-		predMany, _ := rdf.NewIRI(predPrefix + "hasManyKey")
+		predMany, _ := rdf.NewIRI(vocab.HasManyKey)
 		objectMany, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col2)
 		tripleMany := rdf.Triple{
 			Subj: subjectMany,
@@ -509,7 +638,7 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 		// many to many key relatioships
 	case i1 > 1 && i2 > 1 :
 		subject, _ := rdf.NewIRI(tablePrefix + entity)
-		pred, _ := rdf.NewIRI(predPrefix + "hasMany2ManyKey")
+		pred, _ := rdf.NewIRI(vocab.HasMany2ManyKey)
 		object, _ := rdf.NewIRI(tablePrefix + entity + m2mMiddle + col1 + "/" + col2)
 		triple := rdf.Triple{
 			Subj: subject,
@@ -519,7 +648,7 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 		triples = append(triples, triple)
 		triples = append(triples, triple)
 		subjectManyOne, _ := rdf.NewIRI(tablePrefix + entity + m2mMiddle + col1 + "/" + col2)
-		predManyOne, _ := rdf.NewIRI(predPrefix + "hasManyKey")
+		predManyOne, _ := rdf.NewIRI(vocab.HasManyKey)
 		objectManyOne, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col1)
 		tripleManyOne := rdf.Triple{
 			Subj: subjectManyOne,
@@ -528,7 +657,7 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 		}
 		triples = append(triples, tripleManyOne)
 		subjectManyTwo, _ := rdf.NewIRI(tablePrefix + entity + m2mMiddle + col1 + "/" + col2)
-		predManyTwo, _ := rdf.NewIRI(predPrefix + "hasManyKey")
+		predManyTwo, _ := rdf.NewIRI(vocab.HasManyKey)
 		objectManyTwo, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col2)
 		tripleManyTwo := rdf.Triple{
 			Subj: subjectManyTwo,
@@ -542,8 +671,7 @@ func writeOneOrManyToManyItem(w io.Writer, entity string, col1 string, col2 stri
 		str := t.Serialize(rdf.NTriples)
 		w.Write([]byte(str))
 	}
-
-
+	return rel
 }
 
 func subsetsForCompound(w io.Writer, entity string, keys []string, f func(io.Writer, string, string, string)) {
@@ -578,33 +706,16 @@ func writeCompoundItem(w io.Writer, entity string, col1 string, col2 string) {
 		log.Printf("entering one to many checker for %s:%s,%s",entity,col1,col2)
 	}
 
-	q1 := fmt.Sprintf("select max(output) from (select %s, count(distinct %s) as output from %s group by %s) as Derived",col1,col2,entity,col1)
-	q2 := fmt.Sprintf("select max(output) from (select %s, count(distinct %s) as output from %s group by %s) as Derived",col2,col1,entity,col2)
-
-	i1,i2 := 0,0
-	rows1, err := db.Query(q1)
-	if err != nil {
-		fmt.Println(err)
+	i1, err := queryMaxDistinctPer(entity, col1, col2)
+	if writeUnavailable(w, err, tablePrefix+entity+compoundMiddle+col1+"/"+col2, vocab.HasStrongKey, vocab.HasWeakKey) {
+		err = nil
 	}
-	rows2, err := db.Query(q2)
 	if err != nil {
-		fmt.Println(err)
-	}
-	defer rows1.Close()
-	defer rows2.Close()
-
-	for rows1.Next() {
-		err := rows1.Scan(&i1)
-		if err != nil && *verbose { // could be null
-			fmt.Println(err)
-		}
+		report(err)
 	}
-
-	for rows2.Next() {
-		err := rows2.Scan(&i2)
-		if err != nil && *verbose { // could be null
-			fmt.Println(err)
-		}
+	i2, err := queryMaxDistinctPer(entity, col2, col1)
+	if err != nil && !errors.Is(err, source.ErrUnavailable) {
+		report(err)
 	}
 	triples := []rdf.Triple{}
 	if *verbose {
@@ -613,7 +724,7 @@ func writeCompoundItem(w io.Writer, entity string, col1 string, col2 string) {
 	}
 
 	subject, _ := rdf.NewIRI(tablePrefix + entity)
-	pred, _ := rdf.NewIRI(predPrefix + "hasCompoundKey")
+	pred, _ := rdf.NewIRI(vocab.HasCompoundKey)
 	object, _ := rdf.NewIRI(tablePrefix + entity + compoundMiddle + col1 + "/" + col2)
 	triple := rdf.Triple{
 		Subj: subject,
@@ -628,7 +739,7 @@ func writeCompoundItem(w io.Writer, entity string, col1 string, col2 string) {
 			log.Printf(" in check :: compound checker for %s:%s->%d,%s->%d", entity, col1, i1, col2, i2)
 		}
 		subjectOne, _ := rdf.NewIRI(tablePrefix + entity + compoundMiddle + col1 + "/" + col2)
-		predOne, _ := rdf.NewIRI(predPrefix + "hasStrongKey")
+		predOne, _ := rdf.NewIRI(vocab.HasStrongKey)
 		objectOne, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col1)
 		tripleOne := rdf.Triple{
 			Subj: subjectOne,
@@ -637,7 +748,7 @@ func writeCompoundItem(w io.Writer, entity string, col1 string, col2 string) {
 		}
 		triples = append(triples, tripleOne)
 		subjectMany, _ := rdf.NewIRI(tablePrefix + entity + compoundMiddle + col1 + "/" + col2)
-		predMany, _ := rdf.NewIRI(predPrefix + "hasWeakKey")
+		predMany, _ := rdf.NewIRI(vocab.HasWeakKey)
 		objectMany, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col2)
 		tripleMany := rdf.Triple{
 			Subj: subjectMany,
@@ -652,7 +763,7 @@ func writeCompoundItem(w io.Writer, entity string, col1 string, col2 string) {
 			log.Printf(" in check :: compound checker for %s:%s->%d,%s->%d", entity, col1, i1, col2, i2)
 		}
 		subjectOne, _ := rdf.NewIRI(tablePrefix + entity + compoundMiddle + col1 + "/" + col2)
-		predOne, _ := rdf.NewIRI(predPrefix + "hasStrongKey")
+		predOne, _ := rdf.NewIRI(vocab.HasStrongKey)
 		objectOne, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col2)
 		tripleOne := rdf.Triple{
 			Subj: subjectOne,
@@ -661,7 +772,7 @@ func writeCompoundItem(w io.Writer, entity string, col1 string, col2 string) {
 		}
 		triples = append(triples, tripleOne)
 		subjectMany, _ := rdf.NewIRI(tablePrefix + entity + compoundMiddle + col1 + "/" + col2)
-		predMany, _ := rdf.NewIRI(predPrefix + "hasWeakKey")
+		predMany, _ := rdf.NewIRI(vocab.HasWeakKey)
 		objectMany, _  := rdf.NewIRI(tablePrefix + entity + colMiddle + col1)
 		tripleMany := rdf.Triple{
 			Subj: subjectMany,
@@ -725,47 +836,80 @@ func isSimilarIsComplete(entity string, key1 string, key2 string) (bool, bool) {
 	return isSimilar, true
 }
 
-func writeColsDataType(w io.Writer) {
-
-	q := `SELECT 
-		  columns.table_name,
-		  columns.column_name,
-		  columns.data_type,
-		  columns.udt_name
-	FROM information_schema.columns
-	LEFT JOIN information_schema.tables ON columns.table_name = tables.table_name
-	WHERE tables.table_schema = 'public' 
-	`
-
-	rows, _ := db.Query(q)
-
-	data := struct {
-		tableName string
-		colName   string
-		dataType  string
-		udtName   string
-	}{}
-
-	defer rows.Close()
+//the returned map holds the udt name of each table/column
+func writeColsDataType(w io.Writer) map[string]string {
 	triples := []rdf.Triple{}
+	types := map[string]string{}
 
-	for rows.Next() {
-		err := rows.Scan(&(data.tableName), &(data.colName), &(data.dataType), &(data.udtName))
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		subject, _ := rdf.NewIRI(tablePrefix + data.tableName + colMiddle + data.colName)
-		pred, _ := rdf.NewIRI(predPrefix + "hasDataType")
-		object, _ := rdf.NewIRI(dataTypePrefix + data.udtName)
+	for _, data := range queryColumns() {
+		subject, _ := rdf.NewIRI(tablePrefix + data.Entity + colMiddle + data.Name)
+		pred, _ := rdf.NewIRI(vocab.HasDataType)
+		object, _ := rdf.NewIRI(dataTypePrefix + data.Native)
 		triple := rdf.Triple{
 			Subj: subject,
 			Pred: pred,
 			Obj:  object,
 		}
 		triples = append(triples, triple)
+		if data.Dimensions > 0 {
+			triples = append(triples, literalTriple(subject.String(), vocab.ArrayDimensions, data.Dimensions))
+		}
+		types[data.Entity+"/"+data.Name] = data.Native
+	}
+
+	for _, t := range triples {
+		str := t.Serialize(rdf.NTriples)
+		w.Write([]byte(str))
+	}
+	return types
+}
+
+func iriTriple(subj string, pred string, obj string) rdf.Triple {
+	subject, _ := rdf.NewIRI(subj)
+	predicate, _ := rdf.NewIRI(pred)
+	object, _ := rdf.NewIRI(obj)
+	return rdf.Triple{
+		Subj: subject,
+		Pred: predicate,
+		Obj:  object,
+	}
+}
+
+func literalTriple(subj string, pred string, v interface{}) rdf.Triple {
+	subject, _ := rdf.NewIRI(subj)
+	predicate, _ := rdf.NewIRI(pred)
+	object, _ := rdf.NewLiteral(v)
+	return rdf.Triple{
+		Subj: subject,
+		Pred: predicate,
+		Obj:  object,
+	}
+}
+
+//unavailableMarked keeps writeUnavailable from repeating itself within an
+//extraction
+var unavailableMarked map[string]bool
+
+//writeUnavailable marks the predicates of subj that need data the source
+//does not have, such as a schema dump, rather than leaving them out as if
+//nothing was found. It reports whether err was that.
+func writeUnavailable(w io.Writer, err error, subj string, preds ...string) bool {
+	if !errors.Is(err, source.ErrUnavailable) {
+		return false
+	}
+	triples := []rdf.Triple{}
+	for _, pred := range preds {
+		t := iriTriple(subj, vocab.Unavailable, pred)
+		if k := t.Serialize(rdf.NTriples); !unavailableMarked[k] {
+			unavailableMarked[k] = true
+			triples = append(triples, t)
+		}
 	}
+	writeTriples(w, triples)
+	return true
+}
 
+func writeTriples(w io.Writer, triples []rdf.Triple) {
 	for _, t := range triples {
 		str := t.Serialize(rdf.NTriples)
 		w.Write([]byte(str))
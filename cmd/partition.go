@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"sort"
+
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// writePartitions writes the strategy and key of each partitioned or
+// inherited entity, and with -partitions its children and their bounds. The
+// children themselves are not entities, their rows are profiled through the
+// parent.
+func writePartitions(w io.Writer) {
+	partitionings, err := src.Partitions()
+	if err != nil {
+		report(err)
+		return
+	}
+	entities := []string{}
+	for entity := range partitionings {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	triples := []rdf.Triple{}
+	for _, entity := range entities {
+		pt := partitionings[entity]
+		triples = append(triples, literalTriple(tablePrefix+entity, vocab.PartitionStrategy, pt.Strategy))
+		for _, col := range pt.Key {
+			triples = append(triples, iriTriple(tablePrefix+entity, vocab.HasPartitionKey, tablePrefix+entity+colMiddle+col))
+		}
+		if !*listPartitions {
+			continue
+		}
+		for _, part := range pt.Partitions {
+			node := tablePrefix + entity + partitionMiddle + part.Name
+			triples = append(triples, iriTriple(tablePrefix+entity, vocab.HasPartition, node))
+			if part.Bound != "" {
+				triples = append(triples, literalTriple(node, vocab.PartitionBound, part.Bound))
+			}
+		}
+	}
+	writeTriples(w, triples)
+}
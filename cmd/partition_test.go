@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/vocab"
+)
+
+// TestPartitions checks a partitioned table is a single entity with its key
+// and, when asked for, its partitions.
+func TestPartitions(t *testing.T) {
+	old := *listPartitions
+	defer func() { *listPartitions = old }()
+	*listPartitions = true
+	g := extractDDL(t, `
+CREATE TABLE public.measurement (city_id integer NOT NULL, logdate date NOT NULL) PARTITION BY RANGE (logdate);
+CREATE TABLE public.measurement_y2019m01 PARTITION OF public.measurement FOR VALUES FROM ('2019-01-01') TO ('2019-02-01');
+CREATE TABLE public.measurement_y2019m02 PARTITION OF public.measurement FOR VALUES FROM ('2019-02-01') TO ('2019-03-01') PARTITION BY LIST (city_id);
+CREATE TABLE public.measurement_y2019m02_c1 PARTITION OF public.measurement_y2019m02 FOR VALUES IN (1);
+`)
+
+	measurement := tablePrefix + "measurement"
+	partition := measurement + partitionMiddle + "measurement_y2019m01"
+	// a sub-partition is listed under the entity at the top
+	subPartition := measurement + partitionMiddle + "measurement_y2019m02_c1"
+	hasTriples(t, g,
+		literalTriple(measurement, vocab.PartitionStrategy, "range"),
+		iriTriple(measurement, vocab.HasPartitionKey, measurement+colMiddle+"logdate"),
+		iriTriple(measurement, vocab.HasPartition, partition),
+		literalTriple(partition, vocab.PartitionBound, "FOR VALUES FROM ('2019-01-01') TO ('2019-02-01')"),
+		iriTriple(measurement, vocab.HasPartition, measurement+partitionMiddle+"measurement_y2019m02"),
+		iriTriple(measurement, vocab.HasPartition, subPartition),
+		literalTriple(subPartition, vocab.PartitionBound, "FOR VALUES IN (1)"),
+	)
+	if n := len(g.Match(nil, graph.IRI(vocab.PartitionStrategy), nil)); n != 1 {
+		t.Errorf("wanted only measurement partitioned got %d strategies", n)
+	}
+	for _, name := range []string{"measurement_y2019m01", "measurement_y2019m02", "measurement_y2019m02_c1"} {
+		if n := len(g.Match(graph.IRI(tablePrefix+name), nil, nil)); n != 0 {
+			t.Errorf("wanted no triples about %s as an entity got %d", name, n)
+		}
+	}
+}
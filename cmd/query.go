@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/sparql"
+)
+
+// runQuery answers a SPARQL query from the extracted graph, the query is read
+// from -q or given as the remaining arguments.
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	in := fs.String("in", "", "N-Triple file to query instead of extracting the database")
+	queryFile := fs.String("q", "", "file holding the query")
+	format := fs.String("format", "table", "result format: table, json or csv")
+	fs.Parse(args)
+
+	text := strings.Join(fs.Args(), " ")
+	if *queryFile != "" {
+		b, err := ioutil.ReadFile(*queryFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		text = string(b)
+	}
+	if strings.TrimSpace(text) == "" {
+		log.Fatal("no query given")
+	}
+	q, err := sparql.Parse(text)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g, err := loadGraph(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := q.Exec(g)
+
+	w, closer := output()
+	defer closer.Close()
+	switch {
+	case r.Form == sparql.Construct:
+		err = r.WriteNTriples(w)
+	case *format == "json":
+		err = r.WriteJSON(w)
+	case *format == "csv":
+		err = r.WriteCSV(w)
+	default:
+		err = r.WriteTable(w)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
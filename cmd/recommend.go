@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/recommend"
+	"github.com/dooodle/vis-extractor/vegalite"
+)
+
+// loadRules reads a YAML rules file, or returns the built in rules when no
+// file is given.
+func loadRules(fileName string) []recommend.Rule {
+	if fileName == "" {
+		return recommend.DefaultRules()
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	rules, err := recommend.LoadRules(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return rules
+}
+
+// runRecommend ranks chart suggestions for the extracted graph.
+func runRecommend(args []string) {
+	fs := flag.NewFlagSet("recommend", flag.ExitOnError)
+	in := fs.String("in", "", "N-Triple file to recommend from instead of extracting the database")
+	asJSON := fs.Bool("json", false, "output recommendations as JSON")
+	limit := fs.Int("n", 0, "maximum number of recommendations, 0 for all")
+	rulesFile := fs.String("rules", "", "YAML rules file to use instead of the built in rules")
+	vlDir := fs.String("vl-dir", "", "directory to write one Vega-Lite specification per recommendation to")
+	vlBundle := fs.String("vl-bundle", "", "file to write every recommendation with its Vega-Lite specification to")
+	vlURL := fs.String("vl-url", "", "data url template for the specifications, {entity} and {columns} are filled in")
+	vlSQL := fs.String("vl-sql", vegalite.DefaultSQL, "data query template for the specifications when there is no url")
+	fs.Parse(args)
+
+	rules := loadRules(*rulesFile)
+	g, err := loadGraph(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	recs := recommend.Recommend(g, rules)
+	if *limit > 0 && len(recs) > *limit {
+		recs = recs[:*limit]
+	}
+	if *vlDir != "" || *vlBundle != "" {
+		data := vegalite.DataSource{URL: *vlURL, SQL: *vlSQL}
+		if err := writeSpecs(g, recs, data, *vlDir, *vlBundle); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	w, closer := output()
+	defer closer.Close()
+	if *asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(recs); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	for _, r := range recs {
+		channels := make([]string, 0, len(r.Bindings))
+		for channel, col := range r.Bindings {
+			channels = append(channels, channel+"="+col)
+		}
+		sort.Strings(channels)
+		fmt.Fprintf(w, "%.2f\t%s\t%s\t%s\n", r.Score, r.Chart, r.Entity, strings.Join(channels, " "))
+	}
+}
+
+// writeSpecs saves a Vega-Lite specification per recommendation in dir and
+// all of them together in bundle, either may be empty.
+func writeSpecs(g *graph.Graph, recs []recommend.Recommendation, data vegalite.DataSource, dir string, bundle string) error {
+	type bundled struct {
+		recommend.Recommendation
+		Spec vegalite.Spec `json:"spec"`
+	}
+	all := []bundled{}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	for i, r := range recs {
+		spec := vegalite.Generate(g, r, data)
+		all = append(all, bundled{r, spec})
+		if dir == "" {
+			continue
+		}
+		name := fmt.Sprintf("%03d-%s-%s.vl.json", i+1, r.Chart, r.Entity)
+		if err := writeJSON(filepath.Join(dir, name), spec); err != nil {
+			return err
+		}
+	}
+	if bundle != "" {
+		return writeJSON(bundle, all)
+	}
+	return nil
+}
+
+func writeJSON(fileName string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append(b, '\n'), 0644)
+}
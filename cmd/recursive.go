@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// selfReference is a reference from cols of an entity to keyCols of the same
+// entity, such as a river flowing into another river.
+type selfReference struct {
+	entity   string
+	cols     []string
+	keyCols  []string
+	declared bool
+}
+
+// writeRecursiveRels finds entities that reference themselves, either through
+// a declared foreign key or through columns whose values all appear in the
+// entity's own key, and measures how deep the recursion goes.
+func writeRecursiveRels(w io.Writer, keys map[string][]string, fks []foreignKey, types map[string]string) {
+	if *verbose {
+		log.Println("extracting recursive relationships")
+	}
+	refs := []selfReference{}
+	declared := map[string]bool{}
+	for _, fk := range fks {
+		if fk.entity == fk.refEntity {
+			refs = append(refs, selfReference{entity: fk.entity, cols: fk.cols, keyCols: fk.refCols, declared: true})
+			declared[fk.entity+"/"+strings.Join(fk.cols, ",")] = true
+		}
+	}
+	if *inferRecursive {
+		refs = append(refs, inferSelfReferences(keys, types, declared)...)
+	}
+
+	triples := []rdf.Triple{}
+	for _, ref := range refs {
+		var res struct {
+			Depth  int
+			Cyclic bool
+		}
+		key := cacheKey("recursiveDepth", strings.Join(ref.cols, ","), strings.Join(ref.keyCols, ","))
+		err := cached(ref.entity, key, &res, func() (err error) {
+			res.Depth, res.Cyclic, err = src.RecursiveDepth(ref.entity, ref.cols, ref.keyCols)
+			return err
+		})
+		node := tablePrefix + ref.entity + recursiveMiddle + strings.Join(ref.cols, ",")
+		unavailable := writeUnavailable(w, err, node, vocab.MaxDepth, vocab.RecursiveStructure)
+		if err != nil && !unavailable {
+			report(err)
+			continue
+		}
+		// a declared reference is known without data, only its shape is not
+		triples = append(triples, iriTriple(tablePrefix+ref.entity, vocab.HasRecursiveRelationship, node))
+		for _, c := range ref.cols {
+			triples = append(triples, iriTriple(node, vocab.HasReferencingColumn, tablePrefix+ref.entity+colMiddle+c))
+		}
+		for _, c := range ref.keyCols {
+			triples = append(triples, iriTriple(node, vocab.HasReferencedColumn, tablePrefix+ref.entity+colMiddle+c))
+		}
+		triples = append(triples, literalTriple(node, vocab.IsDeclared, ref.declared))
+		if unavailable {
+			continue
+		}
+		structure := treeStructure
+		if res.Cyclic {
+			structure = graphStructure
+		}
+		triples = append(triples,
+			literalTriple(node, vocab.MaxDepth, res.Depth),
+			iriTriple(node, vocab.RecursiveStructure, structure),
+		)
+	}
+	writeTriples(w, triples)
+}
+
+// inferSelfReferences checks the columns sharing the types of a key, one
+// for each key column in key order so (name, country) can be referenced by
+// (capital, country), skipping references already declared. A reference
+// takes at least one column from outside the key, rows are not taken to
+// reference themselves through their own key columns.
+func inferSelfReferences(keys map[string][]string, types map[string]string, declared map[string]bool) []selfReference {
+	refs := []selfReference{}
+	entities := make([]string, 0, len(keys))
+	for entity := range keys {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	for _, entity := range entities {
+		key := keys[entity]
+		if len(key) == 0 {
+			continue
+		}
+		cols := []string{}
+		for tc := range types {
+			parts := strings.SplitN(tc, "/", 2)
+			if parts[0] == entity {
+				cols = append(cols, parts[1])
+			}
+		}
+		sort.Strings(cols)
+		for _, ref := range sameTypeTuples(entity, key, cols, types) {
+			if declared[entity+"/"+strings.Join(ref, ",")] {
+				continue
+			}
+			var ok bool
+			err := cached(entity, cacheKey("containedInKey", strings.Join(ref, ","), strings.Join(key, ",")), &ok, func() (err error) {
+				ok, err = src.ContainedInKey(entity, ref, key)
+				return err
+			})
+			if errors.Is(err, source.ErrUnavailable) {
+				// undeclared references can only be found in the data
+				return refs
+			}
+			if err != nil {
+				report(err)
+				continue
+			}
+			if ok {
+				refs = append(refs, selfReference{entity: entity, cols: ref, keyCols: key})
+			}
+		}
+	}
+	return refs
+}
+
+// sameTypeTuples returns every tuple of distinct columns whose types match
+// the key column for column, with at least one column outside the key.
+func sameTypeTuples(entity string, key []string, cols []string, types map[string]string) [][]string {
+	inKey := map[string]bool{}
+	for _, k := range key {
+		inKey[k] = true
+	}
+	tuples := [][]string{}
+	tuple := make([]string, 0, len(key))
+	used := map[string]bool{}
+	var search func(i int, outside bool)
+	search = func(i int, outside bool) {
+		if i == len(key) {
+			if outside {
+				tuples = append(tuples, append([]string{}, tuple...))
+			}
+			return
+		}
+		for _, c := range cols {
+			if used[c] || types[entity+"/"+c] != types[entity+"/"+key[i]] {
+				continue
+			}
+			used[c] = true
+			tuple = append(tuple, c)
+			search(i+1, outside || !inKey[c])
+			tuple = tuple[:len(tuple)-1]
+			used[c] = false
+		}
+	}
+	search(0, false)
+	return tuples
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/vocab"
+)
+
+// TestRecursiveRels checks a declared and an inferred self reference are
+// both measured, the declared one a tree and the inferred one a cycle.
+func TestRecursiveRels(t *testing.T) {
+	g := extractSQLite(t,
+		`create table river (name text primary key, flows_into text references river, joins text, length integer)`,
+		`insert into river values ('Rhine', null, 'Main', 1230), ('Main', 'Rhine', 'Rhine', 525), ('Regnitz', 'Main', null, 162)`,
+		`create table province (name text, country text, part_of text, primary key (name, country))`,
+		`insert into province values ('Bavaria', 'D', null), ('Franconia', 'D', 'Bavaria'), ('Upper Franconia', 'D', 'Franconia'), ('Normandy', 'F', null)`,
+	)
+
+	river := tablePrefix + "river"
+	flows := river + recursiveMiddle + "flows_into"
+	joins := river + recursiveMiddle + "joins"
+	hasTriples(t, g,
+		iriTriple(river, vocab.HasRecursiveRelationship, flows),
+		iriTriple(flows, vocab.HasReferencingColumn, river+colMiddle+"flows_into"),
+		iriTriple(flows, vocab.HasReferencedColumn, river+colMiddle+"name"),
+		literalTriple(flows, vocab.IsDeclared, true),
+		literalTriple(flows, vocab.MaxDepth, 2),
+		iriTriple(flows, vocab.RecursiveStructure, treeStructure),
+		iriTriple(river, vocab.HasRecursiveRelationship, joins),
+		literalTriple(joins, vocab.IsDeclared, false),
+		literalTriple(joins, vocab.MaxDepth, 0),
+		iriTriple(joins, vocab.RecursiveStructure, graphStructure),
+	)
+	if g.Has(iriTriple(river, vocab.HasRecursiveRelationship, river+recursiveMiddle+"length")) {
+		t.Error("wanted no reference from a column of another type")
+	}
+
+	// a compound key is referenced column for column
+	province := tablePrefix + "province"
+	partOf := province + recursiveMiddle + "part_of,country"
+	hasTriples(t, g,
+		iriTriple(province, vocab.HasRecursiveRelationship, partOf),
+		iriTriple(partOf, vocab.HasReferencingColumn, province+colMiddle+"part_of"),
+		iriTriple(partOf, vocab.HasReferencingColumn, province+colMiddle+"country"),
+		iriTriple(partOf, vocab.HasReferencedColumn, province+colMiddle+"name"),
+		literalTriple(partOf, vocab.IsDeclared, false),
+		literalTriple(partOf, vocab.MaxDepth, 2),
+		iriTriple(partOf, vocab.RecursiveStructure, treeStructure),
+	)
+	if n := len(g.Match(graph.IRI(province), graph.IRI(vocab.HasRecursiveRelationship), nil)); n != 1 {
+		t.Errorf("wanted one reference of province got %d", n)
+	}
+}
+
+// TestRecursiveRelsDDL checks a declared self reference in a dump keeps its
+// columns while its depth and structure are marked unavailable.
+func TestRecursiveRelsDDL(t *testing.T) {
+	g := extractDDL(t, `
+CREATE TABLE public.river (name text PRIMARY KEY, flows_into text REFERENCES public.river (name));
+`)
+
+	river := tablePrefix + "river"
+	flows := river + recursiveMiddle + "flows_into"
+	hasTriples(t, g,
+		iriTriple(river, vocab.HasRecursiveRelationship, flows),
+		iriTriple(flows, vocab.HasReferencingColumn, river+colMiddle+"flows_into"),
+		iriTriple(flows, vocab.HasReferencedColumn, river+colMiddle+"name"),
+		literalTriple(flows, vocab.IsDeclared, true),
+		iriTriple(flows, vocab.Unavailable, vocab.MaxDepth),
+		iriTriple(flows, vocab.Unavailable, vocab.RecursiveStructure),
+	)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/dooodle/vis-extractor/api"
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/recommend"
+	"github.com/dooodle/vis-extractor/sparql"
+)
+
+// server holds the graph being served, it is swapped whole on re-extraction
+// so queries never see a half built graph.
+type server struct {
+	mu         sync.RWMutex
+	g          *graph.Graph
+	rules      []recommend.Rule
+	extracting sync.Mutex
+	// in is the N-Triple file served, there is no database to extract then
+	in string
+}
+
+func (s *server) graph() *graph.Graph {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.g
+}
+
+func (s *server) replace(g *graph.Graph) {
+	s.mu.Lock()
+	s.g = g
+	s.mu.Unlock()
+}
+
+// reextract rebuilds the graph from the database, one extraction at a time.
+// A failed extraction leaves the graph being served as it was.
+func (s *server) reextract() (*graph.Graph, error) {
+	s.extracting.Lock()
+	defer s.extracting.Unlock()
+	buf := bytes.Buffer{}
+	if err := extract(&buf); err != nil {
+		return nil, err
+	}
+	g, err := graph.Load(&buf)
+	if err != nil {
+		return nil, err
+	}
+	s.replace(g)
+	return g, nil
+}
+
+// invalidate makes the next extraction profile the given tables again, or
+// every table when tables is nil.
+func (s *server) invalidate(tables []string) {
+	s.extracting.Lock()
+	defer s.extracting.Unlock()
+	if tables == nil && cache != nil {
+		cache.Tables = map[string]*tableCache{}
+	}
+	cache.invalidate(tables)
+	cache.save()
+}
+
+// runServe serves the graph on a SPARQL endpoint and as plain JSON.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	in := fs.String("in", "", "N-Triple file to serve instead of extracting the database")
+	addr := fs.String("addr", "localhost:3030", "address to listen on")
+	rulesFile := fs.String("rules", "", "YAML rules file for /recommendations instead of the built in rules")
+	fs.Parse(args)
+
+	rules := loadRules(*rulesFile)
+
+	g, err := loadGraph(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &server{g: g, rules: rules, in: *in}
+	log.Printf("serving %d triples on http://%s/sparql", g.Len(), *addr)
+	log.Fatal(http.ListenAndServe(*addr, s.routes()))
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/sparql", sparql.Handler(s.graph))
+	mux.HandleFunc("/extract", s.handleExtract)
+	mux.Handle("/", api.Handler(s.graph, s.rules))
+	return mux
+}
+
+// handleExtract refreshes the graph from the database on POST.
+func (s *server) handleExtract(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.in != "" {
+		http.Error(w, "serving "+s.in+", there is no database to extract", http.StatusConflict)
+		return
+	}
+	g, err := s.reextract()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if *verbose {
+		log.Printf("re-extracted %d triples", g.Len())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"triples": g.Len()})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+)
+
+// TestHandleExtract checks a failed extraction keeps the graph being served
+// and a graph read from a file is never re-extracted.
+func TestHandleExtract(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "gone.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	defer func() { src, cache = nil, nil }()
+	src = source.NewSQLite(db)
+
+	g := graph.New()
+	g.Add(iriTriple(tablePrefix+"city", vocab.HasColumn, tablePrefix+"city"+colMiddle+"name"))
+	for _, test := range []struct {
+		in   string
+		want int
+	}{
+		{"", http.StatusInternalServerError},
+		{"graph.nt", http.StatusConflict},
+	} {
+		s := &server{g: g, in: test.in}
+		rec := httptest.NewRecorder()
+		s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/extract", nil))
+		if rec.Code != test.want {
+			t.Errorf("in %q: wanted %d got %d %s", test.in, test.want, rec.Code, rec.Body)
+		}
+		if s.graph() != g {
+			t.Errorf("in %q: wanted the graph kept", test.in)
+		}
+	}
+}
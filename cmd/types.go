@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// writeTypes describes the enums, domains, arrays and composite types behind
+// the dataType/ IRIs of the columns. Enums list their labels in sort order,
+// domains their base type and checks, arrays their element type and
+// composite types their fields. The types are returned by native name so
+// enum columns can be told apart.
+func writeTypes(w io.Writer) map[string]source.Type {
+	types, err := src.Types()
+	if err != nil {
+		report(err)
+		return map[string]source.Type{}
+	}
+	names := []string{}
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	triples := []rdf.Triple{}
+	for _, name := range names {
+		t := types[name]
+		typeIRI := dataTypePrefix + name
+		triples = append(triples, iriTriple(typeIRI, vocab.HasTypeKind, typeKindPrefix+t.Kind))
+		switch t.Kind {
+		case source.EnumType:
+			for i, label := range t.Labels {
+				node := typeIRI + "/label/" + strconv.Itoa(i+1)
+				triples = append(triples,
+					iriTriple(typeIRI, vocab.HasLabel, node),
+					literalTriple(node, vocab.LabelOrder, i+1),
+					literalTriple(node, vocab.Value, label),
+				)
+			}
+		case source.DomainType:
+			triples = append(triples, iriTriple(typeIRI, vocab.HasBaseType, dataTypePrefix+t.Base))
+			for _, check := range t.Checks {
+				triples = append(triples, literalTriple(typeIRI, vocab.HasCheck, check))
+			}
+		case source.ArrayType:
+			triples = append(triples, iriTriple(typeIRI, vocab.HasElementType, dataTypePrefix+t.Base))
+		case source.CompositeType:
+			for i, f := range t.Fields {
+				node := typeIRI + "/field/" + f.Name
+				triples = append(triples,
+					iriTriple(typeIRI, vocab.HasField, node),
+					literalTriple(node, vocab.FieldName, f.Name),
+					literalTriple(node, vocab.FieldIndex, i+1),
+					iriTriple(node, vocab.HasDataType, dataTypePrefix+f.Native),
+				)
+			}
+		}
+	}
+	writeTriples(w, triples)
+	return types
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// skipped holds the views left out of profiling in the current extraction,
+// it is worked out again by each one as views come and go.
+var skipped map[string]bool
+
+// skippedViews resolves -skip-views against the views of the source, * names
+// every view and materialized view.
+func skippedViews() map[string]bool {
+	names := map[string]bool{}
+	if *skipViews == "" {
+		return names
+	}
+	listed := map[string]bool{}
+	for _, name := range strings.Split(*skipViews, ",") {
+		listed[strings.TrimSpace(name)] = true
+	}
+	for _, e := range queryEntities() {
+		if e.Kind != source.TableKind && (listed["*"] || listed[e.Name]) {
+			names[e.Name] = true
+		}
+	}
+	return names
+}
+
+// viewSkipper answers the statistics of skipped views with
+// source.ErrUnavailable, so they keep their columns, kind and lineage and
+// their statistics are marked unavailable.
+type viewSkipper struct {
+	source.Source
+}
+
+func (s viewSkipper) NumDistinct(entity string, col string) (int, error) {
+	if skipped[entity] {
+		return 0, source.ErrUnavailable
+	}
+	return s.Source.NumDistinct(entity, col)
+}
+
+func (s viewSkipper) MaxDistinctPer(entity string, col string, other string) (int, error) {
+	if skipped[entity] {
+		return 0, source.ErrUnavailable
+	}
+	return s.Source.MaxDistinctPer(entity, col, other)
+}
+
+func (s viewSkipper) Histogram(entity string, col string, n int, depth bool) ([]source.Bucket, error) {
+	if skipped[entity] {
+		return nil, source.ErrUnavailable
+	}
+	return s.Source.Histogram(entity, col, n, depth)
+}
+
+func (s viewSkipper) TopValues(entity string, col string, k int) ([]source.Frequency, error) {
+	if skipped[entity] {
+		return nil, source.ErrUnavailable
+	}
+	return s.Source.TopValues(entity, col, k)
+}
+
+func (s viewSkipper) Moments(entity string, col string) (source.Moments, bool, error) {
+	if skipped[entity] {
+		return source.Moments{}, false, source.ErrUnavailable
+	}
+	return s.Source.Moments(entity, col)
+}
+
+func (s viewSkipper) Correlation(entity string, col1 string, col2 string, sample float64) (sql.NullFloat64, sql.NullFloat64, error) {
+	if skipped[entity] {
+		return sql.NullFloat64{}, sql.NullFloat64{}, source.ErrUnavailable
+	}
+	return s.Source.Correlation(entity, col1, col2, sample)
+}
+
+func (s viewSkipper) SampleRows(entity string, cols []string, limit int) ([][]string, error) {
+	if skipped[entity] {
+		return nil, source.ErrUnavailable
+	}
+	return s.Source.SampleRows(entity, cols, limit)
+}
+
+func (s viewSkipper) RandomRows(entity string, cols []string, limit int) ([][]string, error) {
+	if skipped[entity] {
+		return nil, source.ErrUnavailable
+	}
+	return s.Source.RandomRows(entity, cols, limit)
+}
+
+func (s viewSkipper) ContainedInKey(entity string, cols []string, keyCols []string) (bool, error) {
+	if skipped[entity] {
+		return false, source.ErrUnavailable
+	}
+	return s.Source.ContainedInKey(entity, cols, keyCols)
+}
+
+func (s viewSkipper) RecursiveDepth(entity string, cols []string, keyCols []string) (int, bool, error) {
+	if skipped[entity] {
+		return 0, false, source.ErrUnavailable
+	}
+	return s.Source.RecursiveDepth(entity, cols, keyCols)
+}
+
+func (s viewSkipper) IsUnique(entity string, cols []string) (bool, error) {
+	if skipped[entity] {
+		return false, source.ErrUnavailable
+	}
+	return s.Source.IsUnique(entity, cols)
+}
+
+// queryEntities lists the tables and views, errors are printed and leave the
+// list empty
+func queryEntities() []source.Entity {
+	entities, err := src.Entities()
+	if err != nil {
+		report(err)
+	}
+	return entities
+}
+
+// writeEntityKinds writes whether each entity is a table, a view or a
+// materialized view.
+func writeEntityKinds(w io.Writer) {
+	triples := []rdf.Triple{}
+	for _, e := range queryEntities() {
+		triples = append(triples, iriTriple(tablePrefix+e.Name, vocab.HasEntityKind, kindPrefix+e.Kind))
+	}
+	writeTriples(w, triples)
+}
+
+// writeLineage writes the entities each view reads from.
+func writeLineage(w io.Writer) {
+	lineage, err := src.Lineage()
+	if err != nil {
+		report(err)
+		return
+	}
+	triples := []rdf.Triple{}
+	for _, view := range entityNames(lineage) {
+		for _, base := range lineage[view] {
+			triples = append(triples, iriTriple(tablePrefix+view, vocab.DependsOn, tablePrefix+base))
+		}
+	}
+	writeTriples(w, triples)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/vocab"
+)
+
+// TestSkipViews checks a skipped view keeps its columns and kind while its
+// statistics are marked unavailable.
+func TestSkipViews(t *testing.T) {
+	old := *skipViews
+	defer func() { *skipViews = old }()
+	*skipViews = "*"
+	g := extractSQLite(t,
+		`create table city (name text primary key, population integer)`,
+		`insert into city values ('Berlin', 3500000), ('Paris', 2100000), ('Lyon', 500000)`,
+		`create view big_city as select name, population from city where population > 1000000`,
+	)
+
+	view := tablePrefix + "big_city"
+	population := view + colMiddle + "population"
+	for _, want := range [][3]string{
+		{view, vocab.HasEntityKind, vocab.ViewKind},
+		{view, vocab.HasColumn, population},
+		{population, vocab.Unavailable, vocab.NumDistinct},
+	} {
+		if !g.Has(iriTriple(want[0], want[1], want[2])) {
+			t.Errorf("missing %v", want)
+		}
+	}
+	if n := len(g.Match(graph.IRI(tablePrefix+"city"+colMiddle+"population"), graph.IRI(vocab.NumDistinct), nil)); n != 1 {
+		t.Errorf("wanted the table profiled got %d distinct counts", n)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/knakk/rdf"
+	"github.com/lib/pq"
+)
+
+// ddlChannel is the channel the event triggers notify with the identity of
+// every object a DDL command touched.
+const ddlChannel = "vis_extractor_ddl"
+
+// ddlTriggers reports created, altered and dropped objects in public, drops
+// only show up in sql_drop.
+const ddlTriggers = `
+create or replace function vis_extractor_notify_ddl() returns event_trigger language plpgsql as $$
+declare r record;
+begin
+	for r in select * from pg_event_trigger_ddl_commands() loop
+		if r.schema_name = 'public' then
+			perform pg_notify('vis_extractor_ddl', r.object_identity);
+		end if;
+	end loop;
+end $$;
+
+create or replace function vis_extractor_notify_drop() returns event_trigger language plpgsql as $$
+declare r record;
+begin
+	for r in select * from pg_event_trigger_dropped_objects() loop
+		if r.schema_name = 'public' then
+			perform pg_notify('vis_extractor_ddl', r.object_identity);
+		end if;
+	end loop;
+end $$;
+
+drop event trigger if exists vis_extractor_ddl;
+create event trigger vis_extractor_ddl on ddl_command_end execute procedure vis_extractor_notify_ddl();
+drop event trigger if exists vis_extractor_drop;
+create event trigger vis_extractor_drop on sql_drop execute procedure vis_extractor_notify_drop();
+`
+
+// runWatch keeps the graph current, extracting again whenever DDL is run in
+// the database or, with -poll, whenever the table fingerprints change. Only
+// the affected tables are profiled again, the others come from the cache.
+func runWatch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	install := fs.Bool("install", false, "install the event triggers notifying on DDL, needs a superuser")
+	addr := fs.String("addr", "", "also serve the graph as the serve subcommand does on this address")
+	rulesFile := fs.String("rules", "", "YAML rules file for /recommendations instead of the built in rules")
+	debounce := fs.Duration("debounce", 2*time.Second, "quiet period after a notification before extracting, so a migration is picked up once")
+	poll := fs.Duration("poll", 0, "also extract on this interval to pick up data changes, 0 only reacts to DDL")
+	fs.Parse(args)
+
+	if *driver != "postgres" {
+		// event triggers and LISTEN are Postgres only
+		log.Fatalf("watch needs the postgres driver, not %s", *driver)
+	}
+	openSource()
+	keepCache = true
+	if *install {
+		if _, err := db.Exec(ddlTriggers); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("installed event triggers notifying %s", ddlChannel)
+	}
+
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	if err := listener.Listen(ddlChannel); err != nil {
+		log.Fatal(err)
+	}
+	defer listener.Close()
+
+	s := &server{rules: loadRules(*rulesFile)}
+	publish := func() error {
+		g, err := s.reextract()
+		if err != nil {
+			return err
+		}
+		log.Printf("extracted %d triples", g.Len())
+		return saveGraph(g)
+	}
+	if err := publish(); err != nil {
+		log.Fatal(err)
+	}
+	if *addr != "" {
+		go func() {
+			log.Printf("serving on http://%s/sparql", *addr)
+			log.Fatal(http.ListenAndServe(*addr, s.routes()))
+		}()
+	}
+
+	var ticks <-chan time.Time
+	if *poll > 0 {
+		ticker := time.NewTicker(*poll)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	s.watch(listener, *debounce, ticks, publish)
+}
+
+// watch invalidates the tables named by the notifications and publishes once
+// they have been quiet for debounce, or on every tick. It returns when the
+// listener is closed.
+func (s *server) watch(listener *pq.Listener, debounce time.Duration, ticks <-chan time.Time, publish func() error) {
+	var quiet <-chan time.Time
+	changed := map[string]bool{}
+	for {
+		select {
+		case n, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// the connection was lost, anything may have changed since
+				log.Println("listener reconnected, extracting everything")
+				s.invalidate(nil)
+			} else if table := tableOf(n.Extra); table != "" {
+				changed[table] = true
+			}
+			quiet = time.After(debounce)
+		case <-quiet:
+			quiet = nil
+			tables := sortedKeys(changed)
+			if *verbose {
+				log.Printf("DDL on %v", tables)
+			}
+			s.invalidate(tables)
+			changed = map[string]bool{}
+			if err := publish(); err != nil {
+				log.Println(err)
+			}
+		case <-ticks:
+			if err := publish(); err != nil {
+				log.Println(err)
+			}
+		case <-time.After(90 * time.Second):
+			// the listener notices dead connections only when it is used
+			go listener.Ping()
+		}
+	}
+}
+
+// tableOf returns the table of an object identity such as public.city,
+// city_pkey on public.city or public.city.population. Postgres quotes the
+// names that need it, public."a.b" is the table a.b, and unquoted names hold
+// no spaces, so a space outside quotes can only start the on part. Objects
+// that are not tables give names that are simply not in the cache.
+func tableOf(identity string) string {
+	names := []string{}
+	name := strings.Builder{}
+	quoted := false
+	for i := 0; i < len(identity); i++ {
+		c := identity[i]
+		switch {
+		case c == '"' && quoted && strings.HasPrefix(identity[i+1:], `"`):
+			name.WriteByte(c)
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			name.WriteByte(c)
+		case c == '.':
+			names = append(names, name.String())
+			name.Reset()
+		case c == ' ':
+			// the object is on the table that follows
+			names = names[:0]
+			name.Reset()
+			i += len("on ")
+		default:
+			name.WriteByte(c)
+		}
+	}
+	names = append(names, name.String())
+	if len(names) > 1 && names[0] == "public" {
+		names = names[1:]
+	}
+	return names[0]
+}
+
+// saveGraph replaces the -f file with the graph, through a temporary file so
+// readers never see it half written. Without -f the graph is not written.
+func saveGraph(g *graph.Graph) error {
+	if *fileName == "" {
+		return nil
+	}
+	buf := bytes.Buffer{}
+	for _, t := range g.Triples() {
+		buf.WriteString(t.Serialize(rdf.NTriples))
+	}
+	tmp := *fileName + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, *fileName)
+}
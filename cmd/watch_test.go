@@ -0,0 +1,115 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/source"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/lib/pq"
+)
+
+func TestTableOf(t *testing.T) {
+	for identity, want := range map[string]string{
+		"public.city":                "city",
+		"public.city.population":     "city",
+		"city_pkey on public.city":   "city",
+		`public."Event Log"`:         "Event Log",
+		"public.city_population_idx": "city_population_idx",
+		`public."a.b"`:               "a.b",
+		`public."a.b".population`:    "a.b",
+		`"x on y" on public."a.b"`:   "a.b",
+		`public."say ""hi"""`:        `say "hi"`,
+	} {
+		if got := tableOf(identity); got != want {
+			t.Errorf("%s: wanted %s got %s", identity, want, got)
+		}
+	}
+}
+
+// TestWatch installs the event triggers in the database named by
+// VIS_TEST_POSTGRES_DSN, which needs a superuser, and checks DDL on a table
+// drops its cached results and extracts the graph again.
+func TestWatch(t *testing.T) {
+	dsn := os.Getenv("VIS_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("VIS_TEST_POSTGRES_DSN is not set")
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	oldKeep := keepCache
+	defer func() { src, cache, keepCache = nil, nil, oldKeep }()
+	keepCache = true
+	src = source.NewPostgres(conn)
+
+	for _, stmt := range []string{
+		`drop table if exists vis_watch`,
+		`create table vis_watch (name text primary key, population integer)`,
+		`insert into vis_watch values ('Berlin', 3500000), ('Paris', 2100000)`,
+		ddlTriggers,
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer conn.Exec(`drop event trigger if exists vis_extractor_ddl;
+		drop event trigger if exists vis_extractor_drop;
+		drop table if exists vis_watch`)
+
+	s := &server{}
+	type published struct {
+		g      *graph.Graph
+		cached bool
+	}
+	done := make(chan published, 1)
+	publish := func() error {
+		// the watcher invalidates before it publishes
+		_, ok := cache.Tables["vis_watch"]
+		g, err := s.reextract()
+		if err != nil {
+			return err
+		}
+		done <- published{g, ok}
+		return nil
+	}
+	if err := publish(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if len(cache.Tables["vis_watch"].Results) == 0 {
+		t.Fatal("wanted the first extraction cached")
+	}
+
+	listener := pq.NewListener(dsn, time.Second, time.Minute, nil)
+	if err := listener.Listen(ddlChannel); err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan bool)
+	go func() {
+		s.watch(listener, 100*time.Millisecond, nil, publish)
+		close(stopped)
+	}()
+	if _, err := conn.Exec(`alter table vis_watch add column area real`); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case p := <-done:
+		if p.cached {
+			t.Error("wanted the altered table invalidated before extracting")
+		}
+		if !p.g.Has(iriTriple(tablePrefix+"vis_watch", vocab.HasColumn, tablePrefix+"vis_watch"+colMiddle+"area")) {
+			t.Error("wanted the new column extracted")
+		}
+	case <-time.After(10 * time.Second):
+		t.Error("no extraction after DDL")
+	}
+	listener.Close()
+	<-stopped
+}
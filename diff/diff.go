@@ -0,0 +1,253 @@
+// Package diff compares two extractions of the same database and reports the
+// triples that appeared or disappeared, grouped by entity.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// Report lists the differences per entity, in entity order. Triples that do
+// not belong to an entity, such as hierarchy levels, are under "".
+type Report struct {
+	Entities []Entity
+}
+
+// Entity holds the differences of one entity. A property that held one value
+// before and another one after is a Change rather than a removed and an
+// added triple, so data type changes and dimension flips stand out.
+type Entity struct {
+	Name    string
+	Added   []rdf.Triple
+	Removed []rdf.Triple
+	Changed []Change
+}
+
+// Change is a literal or property of a subject whose single value was
+// replaced.
+type Change struct {
+	Subj rdf.Subject
+	Pred rdf.Predicate
+	Old  rdf.Object
+	New  rdf.Object
+}
+
+// Empty reports whether the two graphs were the same.
+func (r Report) Empty() bool {
+	return len(r.Entities) == 0
+}
+
+// Compare returns what changed going from old to new.
+func Compare(old *graph.Graph, new *graph.Graph) Report {
+	entities := map[string]*Entity{}
+	get := func(t rdf.Triple) *Entity {
+		name := entityOf(t)
+		e, ok := entities[name]
+		if !ok {
+			e = &Entity{Name: name}
+			entities[name] = e
+		}
+		return e
+	}
+	for _, t := range old.Triples() {
+		if !new.Has(t) {
+			e := get(t)
+			e.Removed = append(e.Removed, t)
+		}
+	}
+	for _, t := range new.Triples() {
+		if !old.Has(t) {
+			e := get(t)
+			e.Added = append(e.Added, t)
+		}
+	}
+
+	r := Report{}
+	for _, e := range entities {
+		e.pairChanges(old, new)
+		r.Entities = append(r.Entities, *e)
+	}
+	sort.Slice(r.Entities, func(i, j int) bool { return r.Entities[i].Name < r.Entities[j].Name })
+	return r
+}
+
+// entityOf names the entity a triple is about, from its subject or failing
+// that its object.
+func entityOf(t rdf.Triple) string {
+	if name := vocab.EntityName(t.Subj.String()); name != "" && t.Subj.Type() == rdf.TermIRI {
+		return name
+	}
+	if t.Obj.Type() == rdf.TermIRI {
+		return vocab.EntityName(t.Obj.String())
+	}
+	return ""
+}
+
+// properties are the IRI valued predicates that hold a single value, a
+// different value is a change rather than a new fact.
+var properties = map[string]bool{
+	vocab.HasDataType:        true,
+	vocab.HasDimension:       true,
+	vocab.RecursiveStructure: true,
+}
+
+// pairChanges turns a removed and an added triple into a change when they
+// are a literal or a property of the same subject, which has exactly one
+// value for it on both sides.
+func (e *Entity) pairChanges(old *graph.Graph, new *graph.Graph) {
+	key := func(t rdf.Triple) string {
+		return graph.Key(t.Subj) + " " + graph.Key(t.Pred)
+	}
+	single := func(g *graph.Graph, t rdf.Triple) bool {
+		if t.Obj.Type() != rdf.TermLiteral && !properties[t.Pred.String()] {
+			return false
+		}
+		return len(g.Match(t.Subj, t.Pred, nil)) == 1
+	}
+	added := map[string]int{}
+	for i, t := range e.Added {
+		if single(new, t) {
+			added[key(t)] = i
+		}
+	}
+	paired := map[int]bool{}
+	removed := []rdf.Triple{}
+	for _, t := range e.Removed {
+		i, ok := added[key(t)]
+		if !ok || !single(old, t) {
+			removed = append(removed, t)
+			continue
+		}
+		paired[i] = true
+		e.Changed = append(e.Changed, Change{Subj: t.Subj, Pred: t.Pred, Old: t.Obj, New: e.Added[i].Obj})
+	}
+	kept := []rdf.Triple{}
+	for i, t := range e.Added {
+		if !paired[i] {
+			kept = append(kept, t)
+		}
+	}
+	e.Added, e.Removed = kept, removed
+}
+
+// short drops the namespaces of the extractor vocabulary, and the entity
+// itself from the IRIs of its columns and relationships.
+func short(t rdf.Term, entity string) string {
+	if t.Type() != rdf.TermIRI {
+		return t.Serialize(rdf.NTriples)
+	}
+	s := t.String()
+	switch {
+	case entity != "" && strings.HasPrefix(s, vocab.EntityPrefix+entity+"/"):
+		return strings.TrimPrefix(s, vocab.EntityPrefix+entity+"/")
+	case entity != "" && s == vocab.EntityPrefix+entity:
+		return entity
+	case strings.HasPrefix(s, vocab.PredicatePrefix):
+		return strings.TrimPrefix(s, vocab.PredicatePrefix)
+	case strings.HasPrefix(s, vocab.Root):
+		return strings.TrimPrefix(s, vocab.Root)
+	}
+	return "<" + s + ">"
+}
+
+// WriteText writes the report for people, one block per entity with + for
+// added, - for removed and ~ for changed triples.
+func (r Report) WriteText(w io.Writer) error {
+	for _, e := range r.Entities {
+		name := e.Name
+		if name == "" {
+			name = "(no entity)"
+		}
+		lines := []string{name}
+		for _, t := range e.Removed {
+			lines = append(lines, fmt.Sprintf("  - %s %s %s", short(t.Subj, e.Name), short(t.Pred, e.Name), short(t.Obj, e.Name)))
+		}
+		for _, t := range e.Added {
+			lines = append(lines, fmt.Sprintf("  + %s %s %s", short(t.Subj, e.Name), short(t.Pred, e.Name), short(t.Obj, e.Name)))
+		}
+		for _, c := range e.Changed {
+			lines = append(lines, fmt.Sprintf("  ~ %s %s %s -> %s", short(c.Subj, e.Name), short(c.Pred, e.Name), short(c.Old, e.Name), short(c.New, e.Name)))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(lines, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonChange struct {
+	Subject   string `json:"subject"`
+	Predicate string `json:"predicate"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+}
+
+type jsonEntity struct {
+	Entity  string       `json:"entity"`
+	Added   []string     `json:"added"`
+	Removed []string     `json:"removed"`
+	Changed []jsonChange `json:"changed"`
+}
+
+// WriteJSON writes the report as a list of entities, triples and terms are
+// in N-Triples syntax.
+func (r Report) WriteJSON(w io.Writer) error {
+	out := []jsonEntity{}
+	nt := func(ts []rdf.Triple) []string {
+		s := []string{}
+		for _, t := range ts {
+			s = append(s, strings.TrimSuffix(t.Serialize(rdf.NTriples), " .\n"))
+		}
+		return s
+	}
+	for _, e := range r.Entities {
+		j := jsonEntity{Entity: e.Name, Added: nt(e.Added), Removed: nt(e.Removed), Changed: []jsonChange{}}
+		for _, c := range e.Changed {
+			j.Changed = append(j.Changed, jsonChange{
+				Subject:   graph.Key(c.Subj),
+				Predicate: graph.Key(c.Pred),
+				Old:       graph.Key(c.Old),
+				New:       graph.Key(c.New),
+			})
+		}
+		out = append(out, j)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WritePatch writes the report as an RDF Patch transaction that turns the old
+// graph into the new one, deletions first.
+func (r Report) WritePatch(w io.Writer) error {
+	lines := []string{"TX ."}
+	row := func(op string, s rdf.Term, p rdf.Term, o rdf.Term) {
+		lines = append(lines, fmt.Sprintf("%s %s %s %s .", op, graph.Key(s), graph.Key(p), graph.Key(o)))
+	}
+	for _, e := range r.Entities {
+		for _, t := range e.Removed {
+			row("D", t.Subj, t.Pred, t.Obj)
+		}
+		for _, c := range e.Changed {
+			row("D", c.Subj, c.Pred, c.Old)
+		}
+	}
+	for _, e := range r.Entities {
+		for _, t := range e.Added {
+			row("A", t.Subj, t.Pred, t.Obj)
+		}
+		for _, c := range e.Changed {
+			row("A", c.Subj, c.Pred, c.New)
+		}
+	}
+	lines = append(lines, "TC .")
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
@@ -0,0 +1,68 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+)
+
+const before = `<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/name> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/population> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/altitude> .
+<http://dooodle/entity/city/column/population> <http://dooodle/predicate/hasDataType> <http://dooodle/dataType/int4> .
+<http://dooodle/entity/city/column/population> <http://dooodle/predicate/hasDimension> <http://dooodle/dimension/discrete> .
+<http://dooodle/entity/country> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/country/column/code> .
+`
+
+const after = `<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/name> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/population> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/elevation> .
+<http://dooodle/entity/city/column/population> <http://dooodle/predicate/hasDataType> <http://dooodle/dataType/int8> .
+<http://dooodle/entity/city/column/population> <http://dooodle/predicate/hasDimension> <http://dooodle/dimension/scalar> .
+<http://dooodle/entity/country> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/country/column/code> .
+`
+
+func load(t *testing.T, nt string) *graph.Graph {
+	g, err := graph.Load(strings.NewReader(nt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestCompare(t *testing.T) {
+	r := Compare(load(t, before), load(t, after))
+	if len(r.Entities) != 1 || r.Entities[0].Name != "city" {
+		t.Fatalf("wanted only city changed got %+v", r.Entities)
+	}
+	e := r.Entities[0]
+	if len(e.Added) != 1 || len(e.Removed) != 1 || len(e.Changed) != 2 {
+		t.Errorf("wanted 1 added, 1 removed and 2 changed got %+v", e)
+	}
+
+	buf := &bytes.Buffer{}
+	r.WriteText(buf)
+	for _, want := range []string{
+		"  - city hasColumn column/altitude",
+		"  + city hasColumn column/elevation",
+		"  ~ column/population hasDataType dataType/int4 -> dataType/int8",
+		"  ~ column/population hasDimension dimension/discrete -> dimension/scalar",
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("wanted %q in\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	r.WritePatch(buf)
+	patch := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(patch) != 8 || patch[0] != "TX ." || patch[7] != "TC ." || !strings.HasPrefix(patch[1], "D ") || !strings.HasPrefix(patch[6], "A ") {
+		t.Errorf("unexpected patch\n%s", buf.String())
+	}
+
+	if !Compare(load(t, before), load(t, before)).Empty() {
+		t.Error("wanted no differences between identical graphs")
+	}
+}
@@ -0,0 +1,209 @@
+// Package fd discovers minimal functional dependencies in a sample of rows.
+//
+// The search is levelwise over the lattice of column sets in the style of
+// TANE: every column set is turned into a partition of the rows, X -> A
+// holds when refining the partition of X by A does not split any class, and
+// the g3 error (the fraction of rows that would have to be removed for the
+// dependency to hold) measures approximate dependencies.
+package fd
+
+import "sort"
+
+// MaxColumns is the widest table that can be searched, column sets are
+// kept as bit masks.
+const MaxColumns = 64
+
+// Dependency is a minimal functional dependency LHS -> RHS, columns are
+// given by their index in the rows passed to Discover.
+type Dependency struct {
+	LHS   []int
+	RHS   int
+	Error float64
+}
+
+// partition assigns every row the number of its equivalence class.
+type partition struct {
+	ids     []int
+	classes int
+}
+
+type search struct {
+	rows  [][]string
+	cache map[uint64]partition
+}
+
+// Discover returns the minimal dependencies with at most maxLHS columns on
+// the left hand side and a g3 error of at most maxError, exact dependencies
+// are found with maxError 0. Columns past MaxColumns are ignored.
+//
+// Constant columns are trivially determined by anything and left hand sides
+// that are keys of the sample determine everything, neither is reported.
+func Discover(rows [][]string, maxLHS int, maxError float64) []Dependency {
+	if len(rows) == 0 || maxLHS < 1 {
+		return nil
+	}
+	n := len(rows[0])
+	if n > MaxColumns {
+		n = MaxColumns
+	}
+	s := &search{rows: rows, cache: map[uint64]partition{}}
+
+	// found holds the left hand sides already found for each column
+	found := make([][]uint64, n)
+	for a := 0; a < n; a++ {
+		if s.partition(1<<uint(a)).classes == 1 {
+			found[a] = append(found[a], 0)
+		}
+	}
+
+	deps := []Dependency{}
+	level := []uint64{}
+	for a := 0; a < n; a++ {
+		level = append(level, 1<<uint(a))
+	}
+	for size := 1; size <= maxLHS && len(level) > 0; size++ {
+		candidates := map[uint64]bool{}
+		for _, x := range level {
+			px := s.partition(x)
+			if px.classes == len(rows) {
+				// x is a key, supersets are not minimal
+				continue
+			}
+			candidates[x] = true
+			for a := 0; a < n; a++ {
+				bit := uint64(1) << uint(a)
+				if x&bit != 0 || implied(found[a], x) {
+					continue
+				}
+				e := g3(px, s.partition(x|bit), len(rows))
+				if e <= maxError {
+					found[a] = append(found[a], x)
+					deps = append(deps, Dependency{LHS: columns(x), RHS: a, Error: e})
+				}
+			}
+		}
+		level = nextLevel(candidates, n)
+	}
+	sort.SliceStable(deps, func(i, j int) bool {
+		if len(deps[i].LHS) != len(deps[j].LHS) {
+			return len(deps[i].LHS) < len(deps[j].LHS)
+		}
+		return deps[i].RHS < deps[j].RHS
+	})
+	return deps
+}
+
+func (s *search) partition(mask uint64) partition {
+	if p, ok := s.cache[mask]; ok {
+		return p
+	}
+	var p partition
+	if mask&(mask-1) == 0 {
+		// single column
+		col := 0
+		for mask>>uint(col) != 1 {
+			col++
+		}
+		classes := map[string]int{}
+		p.ids = make([]int, len(s.rows))
+		for i, row := range s.rows {
+			id, ok := classes[row[col]]
+			if !ok {
+				id = len(classes)
+				classes[row[col]] = id
+			}
+			p.ids[i] = id
+		}
+		p.classes = len(classes)
+	} else {
+		// product of the partition without the lowest column and the lowest column
+		low := mask & -mask
+		p1, p2 := s.partition(mask&^low), s.partition(low)
+		classes := map[[2]int]int{}
+		p.ids = make([]int, len(s.rows))
+		for i := range s.rows {
+			k := [2]int{p1.ids[i], p2.ids[i]}
+			id, ok := classes[k]
+			if !ok {
+				id = len(classes)
+				classes[k] = id
+			}
+			p.ids[i] = id
+		}
+		p.classes = len(classes)
+	}
+	s.cache[mask] = p
+	return p
+}
+
+// g3 is the fraction of rows to remove so that every class of x falls in a
+// single class of xa.
+func g3(x partition, xa partition, rows int) float64 {
+	sizes := make([]int, xa.classes)
+	parent := make([]int, xa.classes)
+	for i := 0; i < rows; i++ {
+		sizes[xa.ids[i]]++
+		parent[xa.ids[i]] = x.ids[i]
+	}
+	best := make([]int, x.classes)
+	for c, size := range sizes {
+		if size > best[parent[c]] {
+			best[parent[c]] = size
+		}
+	}
+	kept := 0
+	for _, b := range best {
+		kept += b
+	}
+	return 1 - float64(kept)/float64(rows)
+}
+
+// implied reports whether one of the found left hand sides is a subset of x.
+func implied(found []uint64, x uint64) bool {
+	for _, f := range found {
+		if f&x == f {
+			return true
+		}
+	}
+	return false
+}
+
+// nextLevel joins the candidate sets into sets one column wider, keeping
+// only those whose every subset was itself a candidate.
+func nextLevel(candidates map[uint64]bool, n int) []uint64 {
+	next := []uint64{}
+	seen := map[uint64]bool{}
+	for x := range candidates {
+		for a := 0; a < n; a++ {
+			bit := uint64(1) << uint(a)
+			y := x | bit
+			if x&bit != 0 || seen[y] {
+				continue
+			}
+			seen[y] = true
+			ok := true
+			for b := y; b != 0; b &= b - 1 {
+				if !candidates[y&^(b&-b)] {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				next = append(next, y)
+			}
+		}
+	}
+	sort.Slice(next, func(i, j int) bool { return next[i] < next[j] })
+	return next
+}
+
+func columns(mask uint64) []int {
+	cols := []int{}
+	for a := 0; mask != 0; a++ {
+		if mask&1 != 0 {
+			cols = append(cols, a)
+		}
+		mask >>= 1
+	}
+	return cols
+}
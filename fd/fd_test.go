@@ -0,0 +1,64 @@
+package fd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	// city, province, country, population
+	rows := [][]string{
+		{"Leeds", "Yorkshire", "UK", "1"},
+		{"York", "Yorkshire", "UK", "2"},
+		{"Derby", "Derbyshire", "UK", "3"},
+		{"Lyon", "Rhone", "FR", "4"},
+		{"Paris", "IDF", "FR", "5"},
+		{"Paris", "Texas", "US", "6"},
+	}
+	deps := Discover(rows, 2, 0)
+	want := []Dependency{
+		{LHS: []int{1}, RHS: 2},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("wanted %v got %v", want, deps)
+	}
+}
+
+func TestDiscoverCompound(t *testing.T) {
+	// c is a xor b, any two columns determine the third
+	rows := [][]string{
+		{"1", "1", "x"},
+		{"1", "1", "x"},
+		{"1", "2", "y"},
+		{"2", "1", "y"},
+		{"2", "2", "x"},
+		{"2", "2", "x"},
+	}
+	deps := Discover(rows, 2, 0)
+	want := []Dependency{
+		{LHS: []int{1, 2}, RHS: 0},
+		{LHS: []int{0, 2}, RHS: 1},
+		{LHS: []int{0, 1}, RHS: 2},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("wanted %v got %v", want, deps)
+	}
+}
+
+func TestDiscoverApproximate(t *testing.T) {
+	rows := [][]string{
+		{"a", "1"},
+		{"a", "1"},
+		{"a", "1"},
+		{"a", "2"},
+		{"b", "3"},
+	}
+	if deps := Discover(rows, 1, 0); len(deps) != 1 || deps[0].RHS != 0 {
+		t.Errorf("wanted only 1 -> 0 got %v", deps)
+	}
+	deps := Discover(rows, 1, 0.25)
+	if len(deps) != 2 || deps[0].RHS != 0 || deps[1].RHS != 1 || math.Abs(deps[1].Error-0.2) > 1e-9 {
+		t.Errorf("wanted 1 -> 0 and 0 -> 1 with error 0.2 got %v", deps)
+	}
+}
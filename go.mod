@@ -1,8 +1,11 @@
 module github.com/dooodle/vis-extractor
 
-go 1.12
+go 1.16
 
 require (
+	github.com/go-sql-driver/mysql v1.7.1
 	github.com/knakk/rdf v0.0.0-20190304171630-8521bf4c5042
 	github.com/lib/pq v1.2.0
+	github.com/mattn/go-sqlite3 v1.14.17
+	gopkg.in/yaml.v2 v2.4.0
 )
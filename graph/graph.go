@@ -0,0 +1,160 @@
+// Package graph keeps an extraction in memory and answers the lookups the
+// recommender and query packages need without an external triple store.
+package graph
+
+import (
+	"io"
+
+	"github.com/knakk/rdf"
+)
+
+// Graph is an indexed set of triples, adding a triple twice keeps one copy.
+type Graph struct {
+	triples []rdf.Triple
+	index   map[string]int
+	bySubj  map[string][]int
+	byPred  map[string][]int
+	byObj   map[string][]int
+}
+
+// New returns an empty graph.
+func New() *Graph {
+	return &Graph{
+		index:  map[string]int{},
+		bySubj: map[string][]int{},
+		byPred: map[string][]int{},
+		byObj:  map[string][]int{},
+	}
+}
+
+// Load reads an N-Triples document into a new graph.
+func Load(r io.Reader) (*Graph, error) {
+	g := New()
+	dec := rdf.NewTripleDecoder(r, rdf.NTriples)
+	for {
+		t, err := dec.Decode()
+		if err == io.EOF {
+			return g, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		g.Add(t)
+	}
+}
+
+// Key identifies a term across term types, an IRI never equals a literal
+// with the same text.
+func Key(t rdf.Term) string {
+	return t.Serialize(rdf.NTriples)
+}
+
+// Add inserts a triple unless it is already present.
+func (g *Graph) Add(t rdf.Triple) {
+	k := t.Serialize(rdf.NTriples)
+	if _, ok := g.index[k]; ok {
+		return
+	}
+	i := len(g.triples)
+	g.triples = append(g.triples, t)
+	g.index[k] = i
+	g.bySubj[Key(t.Subj)] = append(g.bySubj[Key(t.Subj)], i)
+	g.byPred[Key(t.Pred)] = append(g.byPred[Key(t.Pred)], i)
+	g.byObj[Key(t.Obj)] = append(g.byObj[Key(t.Obj)], i)
+}
+
+// Len returns the number of triples in the graph.
+func (g *Graph) Len() int {
+	return len(g.triples)
+}
+
+// Triples returns every triple in insertion order.
+func (g *Graph) Triples() []rdf.Triple {
+	return append([]rdf.Triple{}, g.triples...)
+}
+
+// Match returns the triples matching the given terms, a nil term matches
+// anything.
+func (g *Graph) Match(subj rdf.Term, pred rdf.Term, obj rdf.Term) []rdf.Triple {
+	// start from the most selective index
+	var candidates []int
+	all := true
+	for _, lookup := range []struct {
+		term  rdf.Term
+		index map[string][]int
+	}{{subj, g.bySubj}, {obj, g.byObj}, {pred, g.byPred}} {
+		if lookup.term == nil {
+			continue
+		}
+		ids := lookup.index[Key(lookup.term)]
+		if all || len(ids) < len(candidates) {
+			candidates = ids
+			all = false
+		}
+	}
+	matches := []rdf.Triple{}
+	if all {
+		return g.Triples()
+	}
+	for _, i := range candidates {
+		t := g.triples[i]
+		if (subj == nil || Key(subj) == Key(t.Subj)) &&
+			(pred == nil || Key(pred) == Key(t.Pred)) &&
+			(obj == nil || Key(obj) == Key(t.Obj)) {
+			matches = append(matches, t)
+		}
+	}
+	return matches
+}
+
+// Objects returns the objects of the triples with the given subject and
+// predicate IRIs.
+func (g *Graph) Objects(subj string, pred string) []rdf.Term {
+	objs := []rdf.Term{}
+	for _, t := range g.Match(IRI(subj), IRI(pred), nil) {
+		objs = append(objs, t.Obj)
+	}
+	return objs
+}
+
+// Subjects returns the subjects of the triples with the given predicate IRI
+// and object.
+func (g *Graph) Subjects(pred string, obj rdf.Term) []rdf.Term {
+	subjs := []rdf.Term{}
+	for _, t := range g.Match(nil, IRI(pred), obj) {
+		subjs = append(subjs, t.Subj)
+	}
+	return subjs
+}
+
+// Has reports whether the triple is in the graph.
+func (g *Graph) Has(t rdf.Triple) bool {
+	_, ok := g.index[t.Serialize(rdf.NTriples)]
+	return ok
+}
+
+// IRI builds an IRI term, invalid IRIs become the empty IRI which matches
+// nothing.
+func IRI(s string) rdf.IRI {
+	iri, _ := rdf.NewIRI(s)
+	return iri
+}
+
+// Number reads an integer or decimal literal, anything else is 0.
+func Number(t rdf.Term) float64 {
+	l, ok := t.(rdf.Literal)
+	if !ok {
+		return 0
+	}
+	v, err := l.Typed()
+	if err != nil {
+		return 0
+	}
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadAndMatch(t *testing.T) {
+	nt := `<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/name> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/country> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/country> .
+<http://dooodle/entity/city/column/name> <http://dooodle/predicate/numDistinct> "3000"^^<http://www.w3.org/2001/XMLSchema#integer> .
+`
+	g, err := Load(strings.NewReader(nt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Len() != 3 {
+		t.Errorf("wanted duplicate triple dropped, got %d triples", g.Len())
+	}
+	cols := g.Objects("http://dooodle/entity/city", "http://dooodle/predicate/hasColumn")
+	if len(cols) != 2 {
+		t.Errorf("wanted 2 columns got %v", cols)
+	}
+	if m := g.Match(nil, nil, IRI("http://dooodle/entity/city/column/country")); len(m) != 1 {
+		t.Errorf("wanted 1 triple with country as object got %v", m)
+	}
+	if m := g.Match(IRI("http://dooodle/entity/river"), nil, nil); len(m) != 0 {
+		t.Errorf("wanted no triples for an unknown subject got %v", m)
+	}
+}
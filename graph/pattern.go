@@ -0,0 +1,105 @@
+package graph
+
+import "github.com/knakk/rdf"
+
+// Node is one position of a triple pattern, either a variable or a term.
+type Node struct {
+	Var  string
+	Term rdf.Term
+}
+
+// Variable returns a node binding the named variable.
+func Variable(name string) Node {
+	return Node{Var: name}
+}
+
+// Constant returns a node matching exactly t.
+func Constant(t rdf.Term) Node {
+	return Node{Term: t}
+}
+
+// IsVar reports whether the node is a variable.
+func (n Node) IsVar() bool {
+	return n.Var != ""
+}
+
+// Pattern is a triple pattern.
+type Pattern struct {
+	Subj Node
+	Pred Node
+	Obj  Node
+}
+
+// Binding maps variable names to the terms they matched.
+type Binding map[string]rdf.Term
+
+// Copy returns a binding that can be extended without changing b.
+func (b Binding) Copy() Binding {
+	c := make(Binding, len(b)+2)
+	for k, v := range b {
+		c[k] = v
+	}
+	return c
+}
+
+// Solve returns every binding satisfying all the patterns, a basic graph
+// pattern in SPARQL terms. Patterns are joined in order so the most
+// selective ones should come first.
+func (g *Graph) Solve(patterns []Pattern) []Binding {
+	return g.Extend([]Binding{{}}, patterns)
+}
+
+// Extend joins the given bindings with the patterns, dropping bindings that
+// cannot be extended.
+func (g *Graph) Extend(bindings []Binding, patterns []Pattern) []Binding {
+	for _, p := range patterns {
+		next := []Binding{}
+		for _, b := range bindings {
+			next = append(next, g.match(b, p)...)
+		}
+		bindings = next
+		if len(bindings) == 0 {
+			break
+		}
+	}
+	return bindings
+}
+
+func (g *Graph) match(b Binding, p Pattern) []Binding {
+	resolve := func(n Node) rdf.Term {
+		if !n.IsVar() {
+			return n.Term
+		}
+		return b[n.Var]
+	}
+	matches := []Binding{}
+	for _, t := range g.Match(resolve(p.Subj), resolve(p.Pred), resolve(p.Obj)) {
+		next := b
+		copied := false
+		ok := true
+		for _, pair := range []struct {
+			node Node
+			term rdf.Term
+		}{{p.Subj, t.Subj}, {p.Pred, t.Pred}, {p.Obj, t.Obj}} {
+			if !pair.node.IsVar() {
+				continue
+			}
+			if bound, isBound := next[pair.node.Var]; isBound {
+				// the same variable twice in one pattern
+				if Key(bound) != Key(pair.term) {
+					ok = false
+				}
+				continue
+			}
+			if !copied {
+				next = b.Copy()
+				copied = true
+			}
+			next[pair.node.Var] = pair.term
+		}
+		if ok {
+			matches = append(matches, next)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,92 @@
+// Package recommend turns an extracted graph into ranked chart suggestions.
+//
+// Recommendations come from declarative rules matched against the graph.
+// The built in rules in default.yaml follow the principal paper: a single
+// key with a scalar column is a bar chart, a compound key split into a
+// strong and a weak key with a scalar column is a line or stacked bar chart
+// and a one to many relationship is a treemap.
+package recommend
+
+import (
+	"sort"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/vocab"
+)
+
+// Recommendation is a chart over an entity, Bindings maps each encoding
+// channel of the chart to the column it shows.
+type Recommendation struct {
+	Chart    string            `json:"chart"`
+	Entity   string            `json:"entity"`
+	Bindings map[string]string `json:"bindings"`
+	Score    float64           `json:"score"`
+	Rule     string            `json:"rule"`
+}
+
+// manyCategories is the number of bars beyond which a bar chart gets crowded.
+const manyCategories = 50
+
+// Recommend evaluates the rules against g and returns the recommendations
+// best first, a chart reached by several bindings keeps its best score.
+func Recommend(g *graph.Graph, rules []Rule) []Recommendation {
+	best := map[string]int{}
+	recs := []Recommendation{}
+	for i := range rules {
+		for _, rec := range rules[i].apply(g) {
+			k := rec.Entity + "|" + rec.Chart + "|" + bindingKey(rec.Bindings)
+			if j, ok := best[k]; ok {
+				if rec.Score > recs[j].Score {
+					recs[j] = rec
+				}
+				continue
+			}
+			best[k] = len(recs)
+			recs = append(recs, rec)
+		}
+	}
+	Rank(recs)
+	return recs
+}
+
+// Rank sorts recommendations by descending score, ties are broken by entity,
+// chart and bindings so the order is stable between runs.
+func Rank(recs []Recommendation) {
+	sort.SliceStable(recs, func(i, j int) bool {
+		a, b := recs[i], recs[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Entity != b.Entity {
+			return a.Entity < b.Entity
+		}
+		if a.Chart != b.Chart {
+			return a.Chart < b.Chart
+		}
+		return bindingKey(a.Bindings) < bindingKey(b.Bindings)
+	})
+}
+
+func bindingKey(b map[string]string) string {
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s := ""
+	for _, k := range keys {
+		s += k + "=" + b[k] + ";"
+	}
+	return s
+}
+
+// crowding scales a score down when a column would put too many marks on an
+// axis or in a legend.
+func crowding(g *graph.Graph, col string) float64 {
+	for _, v := range g.Objects(col, vocab.NumDistinct) {
+		if n := graph.Number(v); n > manyCategories {
+			return manyCategories / n
+		}
+	}
+	return 1
+}
@@ -0,0 +1,86 @@
+package recommend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+)
+
+const extraction = `<http://dooodle/entity/country> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/country/column/code> .
+<http://dooodle/entity/country> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/country/column/area> .
+<http://dooodle/entity/country/column/area> <http://dooodle/predicate/hasDimension> <http://dooodle/dimension/scalar> .
+<http://dooodle/entity/country/column/code> <http://dooodle/predicate/numDistinct> "200"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://dooodle/entity/country> <http://dooodle/predicate/hasSingleKey> <http://dooodle/entity/country/column/code> .
+<http://dooodle/entity/pop> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/pop/column/country> .
+<http://dooodle/entity/pop> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/pop/column/year> .
+<http://dooodle/entity/pop> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/pop/column/population> .
+<http://dooodle/entity/pop/column/population> <http://dooodle/predicate/hasDimension> <http://dooodle/dimension/scalar> .
+<http://dooodle/entity/pop> <http://dooodle/predicate/hasCompoundKey> <http://dooodle/entity/pop/compound/country/year> .
+<http://dooodle/entity/pop/compound/country/year> <http://dooodle/predicate/hasStrongKey> <http://dooodle/entity/pop/column/country> .
+<http://dooodle/entity/pop/compound/country/year> <http://dooodle/predicate/hasWeakKey> <http://dooodle/entity/pop/column/year> .
+`
+
+func TestRecommend(t *testing.T) {
+	g, err := graph.Load(strings.NewReader(extraction))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs := Recommend(g, DefaultRules())
+	if len(recs) != 3 {
+		t.Fatalf("wanted 3 recommendations got %v", recs)
+	}
+	first := recs[0]
+	if first.Chart != "line" || first.Entity != "pop" || first.Bindings["x"] != "year" || first.Bindings["color"] != "country" || first.Bindings["y"] != "population" {
+		t.Errorf("wanted line chart of population by year per country first got %v", first)
+	}
+	last := recs[2]
+	if last.Chart != "bar" || last.Entity != "country" || last.Bindings["x"] != "code" || last.Bindings["y"] != "area" {
+		t.Errorf("wanted crowded bar chart of area by country last got %v", last)
+	}
+	if last.Score != 0.6*50/200 {
+		t.Errorf("wanted bar chart score scaled down for 200 bars got %v", last.Score)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader(`
+rules:
+  - name: unkeyed-measure
+    chart: bar
+    score: 1
+    entity: ?entity
+    where:
+      - ?entity hasColumn ?measure
+      - ?measure hasDimension dimension/scalar
+    optional:
+      - ?measure numDistinct ?n
+    unless:
+      - ?entity hasSingleKey ?key
+    bind:
+      y: ?measure
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := graph.Load(strings.NewReader(extraction))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs := Recommend(g, rules)
+	if len(recs) != 1 || recs[0].Entity != "pop" || recs[0].Bindings["y"] != "population" {
+		t.Errorf("wanted only the measure of the entity without a single key got %v", recs)
+	}
+
+	_, err = LoadRules(strings.NewReader(`
+rules:
+  - name: broken
+    chart: bar
+    entity: ?entity
+    where:
+      - ?e hasColumn ?c
+`))
+	if err == nil {
+		t.Errorf("wanted an error for an entity variable missing from the patterns")
+	}
+}
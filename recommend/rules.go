@@ -0,0 +1,351 @@
+package recommend
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+	"gopkg.in/yaml.v2"
+)
+
+//go:embed default.yaml
+var defaultRules []byte
+
+// Rule is a chart recommendation pattern over the extracted vocabulary, see
+// default.yaml for the file format.
+type Rule struct {
+	Name     string            `yaml:"name"`
+	Chart    string            `yaml:"chart"`
+	Score    float64           `yaml:"score"`
+	Entity   string            `yaml:"entity"`
+	Where    []string          `yaml:"where"`
+	Optional []string          `yaml:"optional"`
+	Unless   []string          `yaml:"unless"`
+	Filter   []string          `yaml:"filter"`
+	Bind     map[string]string `yaml:"bind"`
+	Crowding []string          `yaml:"crowding"`
+	Boost    *Boost            `yaml:"boost"`
+
+	where    []graph.Pattern
+	optional []graph.Pattern
+	unless   []graph.Pattern
+	filters  []filter
+}
+
+// Boost raises the score by Weight times the absolute value bound to By.
+type Boost struct {
+	By     string  `yaml:"by"`
+	Weight float64 `yaml:"weight"`
+}
+
+// filter compares a variable with another variable or a constant.
+type filter struct {
+	left  string
+	op    string
+	right graph.Node
+}
+
+// DefaultRules returns the rules shipped in default.yaml.
+func DefaultRules() []Rule {
+	rules, err := parseRules(defaultRules)
+	if err != nil {
+		panic("recommend: bad default rules: " + err.Error())
+	}
+	return rules
+}
+
+// LoadRules reads a rules file.
+func LoadRules(r io.Reader) ([]Rule, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseRules(b)
+}
+
+func parseRules(b []byte) ([]Rule, error) {
+	doc := struct {
+		Rules []Rule `yaml:"rules"`
+	}{}
+	if err := yaml.UnmarshalStrict(b, &doc); err != nil {
+		return nil, err
+	}
+	for i := range doc.Rules {
+		if err := doc.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("rule %d %q: %v", i+1, doc.Rules[i].Name, err)
+		}
+	}
+	return doc.Rules, nil
+}
+
+func (r *Rule) compile() error {
+	if r.Chart == "" {
+		return fmt.Errorf("no chart")
+	}
+	if len(r.Where) == 0 {
+		return fmt.Errorf("no where patterns")
+	}
+	var err error
+	if r.where, err = parsePatterns(r.Where); err != nil {
+		return err
+	}
+	if r.optional, err = parsePatterns(r.Optional); err != nil {
+		return err
+	}
+	if r.unless, err = parsePatterns(r.Unless); err != nil {
+		return err
+	}
+	vars := map[string]bool{}
+	for _, p := range append(append([]graph.Pattern{}, r.where...), r.optional...) {
+		for _, n := range []graph.Node{p.Subj, p.Pred, p.Obj} {
+			if n.IsVar() {
+				vars[n.Var] = true
+			}
+		}
+	}
+	used := []string{r.Entity}
+	for _, v := range r.Bind {
+		used = append(used, v)
+	}
+	used = append(used, r.Crowding...)
+	if r.Boost != nil {
+		used = append(used, r.Boost.By)
+	}
+	for _, f := range r.Filter {
+		parts := strings.Fields(f)
+		if len(parts) != 3 {
+			return fmt.Errorf("filter %q is not: ?var op term", f)
+		}
+		switch parts[1] {
+		case "=", "!=", "<", "<=", ">", ">=":
+		default:
+			return fmt.Errorf("filter %q has unknown operator %s", f, parts[1])
+		}
+		right, err := parseNode(parts[2], false)
+		if err != nil {
+			return err
+		}
+		used = append(used, parts[0])
+		if right.IsVar() {
+			used = append(used, parts[2])
+		}
+		r.filters = append(r.filters, filter{left: strings.TrimPrefix(parts[0], "?"), op: parts[1], right: right})
+	}
+	for _, v := range used {
+		if !strings.HasPrefix(v, "?") || !vars[strings.TrimPrefix(v, "?")] {
+			return fmt.Errorf("%q is not a variable of the patterns", v)
+		}
+	}
+	return nil
+}
+
+func parsePatterns(lines []string) ([]graph.Pattern, error) {
+	patterns := []graph.Pattern{}
+	for _, line := range lines {
+		terms, err := splitPattern(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(terms) != 3 {
+			return nil, fmt.Errorf("pattern %q is not subject predicate object", line)
+		}
+		p := graph.Pattern{}
+		if p.Subj, err = parseNode(terms[0], false); err != nil {
+			return nil, err
+		}
+		if p.Pred, err = parseNode(terms[1], true); err != nil {
+			return nil, err
+		}
+		if p.Obj, err = parseNode(terms[2], false); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// splitPattern splits on white space outside of quotes.
+func splitPattern(line string) ([]string, error) {
+	terms := []string{}
+	current := strings.Builder{}
+	inQuote := false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+			current.WriteRune(c)
+		case !inQuote && (c == ' ' || c == '\t'):
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated literal in %q", line)
+	}
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+	return terms, nil
+}
+
+func parseNode(s string, predicate bool) (graph.Node, error) {
+	switch {
+	case strings.HasPrefix(s, "?"):
+		if len(s) == 1 {
+			return graph.Node{}, fmt.Errorf("empty variable name")
+		}
+		return graph.Variable(s[1:]), nil
+	case strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">"):
+		iri, err := rdf.NewIRI(s[1 : len(s)-1])
+		return graph.Constant(iri), err
+	case strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) && len(s) > 1:
+		l, err := rdf.NewLiteral(s[1 : len(s)-1])
+		return graph.Constant(l), err
+	case s == "true" || s == "false":
+		l, err := rdf.NewLiteral(s == "true")
+		return graph.Constant(l), err
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		l, err := rdf.NewLiteral(i)
+		return graph.Constant(l), err
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		l, err := rdf.NewLiteral(f)
+		return graph.Constant(l), err
+	}
+	prefix := vocab.Root
+	if predicate {
+		prefix = vocab.PredicatePrefix
+	}
+	iri, err := rdf.NewIRI(prefix + s)
+	return graph.Constant(iri), err
+}
+
+// apply returns a recommendation for every binding of the rule.
+func (r *Rule) apply(g *graph.Graph) []Recommendation {
+	bindings := g.Solve(r.where)
+	for _, p := range r.optional {
+		next := []graph.Binding{}
+		for _, b := range bindings {
+			extended := g.Extend([]graph.Binding{b}, []graph.Pattern{p})
+			if len(extended) == 0 {
+				extended = []graph.Binding{b}
+			}
+			next = append(next, extended...)
+		}
+		bindings = next
+	}
+
+	recs := []Recommendation{}
+	for _, b := range bindings {
+		if !r.keep(b) || (len(r.unless) > 0 && len(g.Extend([]graph.Binding{b}, r.unless)) > 0) {
+			continue
+		}
+		score := r.Score
+		for _, v := range r.Crowding {
+			if t, ok := b[strings.TrimPrefix(v, "?")]; ok {
+				score *= crowding(g, t.String())
+			}
+		}
+		if r.Boost != nil {
+			if v, ok := b[strings.TrimPrefix(r.Boost.By, "?")]; ok {
+				score += r.Boost.Weight * math.Abs(graph.Number(v))
+			}
+		}
+		rec := Recommendation{
+			Chart:    r.Chart,
+			Entity:   vocab.EntityName(b[strings.TrimPrefix(r.Entity, "?")].String()),
+			Bindings: map[string]string{},
+			Score:    score,
+			Rule:     r.Name,
+		}
+		for channel, v := range r.Bind {
+			if t, ok := b[strings.TrimPrefix(v, "?")]; ok {
+				rec.Bindings[channel] = vocab.ColumnName(t.String())
+			}
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// keep evaluates the filters, unbound variables fail every filter.
+func (r *Rule) keep(b graph.Binding) bool {
+	for _, f := range r.filters {
+		left, ok := b[f.left]
+		if !ok {
+			return false
+		}
+		right := f.right.Term
+		if f.right.IsVar() {
+			if right, ok = b[f.right.Var]; !ok {
+				return false
+			}
+		}
+		if !compare(left, f.op, right) {
+			return false
+		}
+	}
+	return true
+}
+
+// compare orders numeric literals by value and every other term by its text.
+func compare(a rdf.Term, op string, b rdf.Term) bool {
+	c := 0
+	x, xNum := numeric(a)
+	y, yNum := numeric(b)
+	switch {
+	case xNum && yNum:
+		if x < y {
+			c = -1
+		} else if x > y {
+			c = 1
+		}
+	case graph.Key(a) == graph.Key(b):
+		c = 0
+	default:
+		c = strings.Compare(a.String(), b.String())
+		if c == 0 {
+			// same text, different term types
+			c = strings.Compare(graph.Key(a), graph.Key(b))
+		}
+	}
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func numeric(t rdf.Term) (float64, bool) {
+	l, ok := t.(rdf.Literal)
+	if !ok {
+		return 0, false
+	}
+	switch v, _ := l.Typed(); v.(type) {
+	case int, float64:
+		return graph.Number(t), true
+	}
+	return 0, false
+}
@@ -0,0 +1,1043 @@
+package source
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DDL answers the catalog queries from a schema dump such as
+// pg_dump --schema-only writes, reading CREATE TABLE, CREATE TYPE, CREATE
+// DOMAIN, COMMENT ON and ALTER TABLE ADD CONSTRAINT, ALTER COLUMN and ATTACH
+// PARTITION. There is no data, every statistic is ErrUnavailable.
+type DDL struct {
+	tables map[string]*ddlTable
+	order  []string
+	types  map[string]Type
+	// domainTypes holds the data_type of the base type of each domain
+	domainTypes map[string]string
+}
+
+type ddlTable struct {
+	columns []Column
+	key     []string
+	unique  [][]string
+	fks     []ForeignKey
+	checks  []Check
+	comment string
+	// a partition or inheritance child names its parent
+	parent string
+	bound  string
+	// a partitioned table names its strategy and key
+	strategy string
+	partKey  []string
+}
+
+// pgTypes maps the type names a dump uses onto the information_schema
+// data_type and udt_name Postgres reports for them.
+var pgTypes = map[string][2]string{
+	"smallint":                    {"smallint", "int2"},
+	"int2":                        {"smallint", "int2"},
+	"integer":                     {"integer", "int4"},
+	"int":                         {"integer", "int4"},
+	"int4":                        {"integer", "int4"},
+	"serial":                      {"integer", "int4"},
+	"bigint":                      {"bigint", "int8"},
+	"int8":                        {"bigint", "int8"},
+	"bigserial":                   {"bigint", "int8"},
+	"numeric":                     {"numeric", "numeric"},
+	"decimal":                     {"numeric", "numeric"},
+	"real":                        {"real", "float4"},
+	"float4":                      {"real", "float4"},
+	"double precision":            {"double precision", "float8"},
+	"float8":                      {"double precision", "float8"},
+	"boolean":                     {"boolean", "bool"},
+	"bool":                        {"boolean", "bool"},
+	"text":                        {"text", "text"},
+	"character varying":           {"character varying", "varchar"},
+	"varchar":                     {"character varying", "varchar"},
+	"character":                   {"character", "bpchar"},
+	"char":                        {"character", "bpchar"},
+	"date":                        {"date", "date"},
+	"timestamp":                   {"timestamp without time zone", "timestamp"},
+	"timestamp without time zone": {"timestamp without time zone", "timestamp"},
+	"timestamp with time zone":    {"timestamp with time zone", "timestamptz"},
+	"timestamptz":                 {"timestamp with time zone", "timestamptz"},
+	"time":                        {"time without time zone", "time"},
+	"time without time zone":      {"time without time zone", "time"},
+	"interval":                    {"interval", "interval"},
+	"bytea":                       {"bytea", "bytea"},
+	"json":                        {"json", "json"},
+	"jsonb":                       {"jsonb", "jsonb"},
+	"uuid":                        {"uuid", "uuid"},
+}
+
+// pgType resolves a type as written in a dump, arrays get the udt name of
+// their element with a leading underscore as Postgres reports them, along
+// with their number of dimensions.
+func pgType(written string) (string, string, int) {
+	t := written
+	dims := 0
+	for strings.HasSuffix(t, "[]") {
+		t = strings.TrimSpace(strings.TrimSuffix(t, "[]"))
+		dims++
+	}
+	var dataType, udt string
+	if strings.HasSuffix(t, `"`) {
+		// a quoted name keeps its case, as CREATE TYPE stored it
+		i := len(t) - 2
+		for ; i > 0; i-- {
+			if t[i] == '"' {
+				if t[i-1] != '"' {
+					break
+				}
+				i--
+			}
+		}
+		dataType, udt = "USER-DEFINED", ident(t[i:])
+	} else {
+		t = strings.ToLower(t)
+		// drop the schema of user defined types and the modifiers
+		if i := strings.LastIndex(t, "."); i >= 0 {
+			t = strings.TrimSpace(t[i+1:])
+		}
+		if i := strings.Index(t, "("); i >= 0 {
+			t = strings.TrimSpace(t[:i] + t[strings.Index(t, ")")+1:])
+		}
+		dataType, udt = "USER-DEFINED", t
+		if known, ok := pgTypes[t]; ok {
+			dataType, udt = known[0], known[1]
+		}
+	}
+	if dims > 0 {
+		return "ARRAY", "_" + udt, dims
+	}
+	return dataType, udt, 0
+}
+
+// typeOf resolves the tokens of a type, columns of a domain take the
+// data_type of its base and arrays are recorded as types.
+func (d *DDL) typeOf(toks []string) (string, string, int) {
+	written := strings.Join(toks, " ")
+	written = strings.NewReplacer(" (", "(", "( ", "(", " )", ")", " ,", ",", " []", "[]", " .", ".", ". ", ".").Replace(written)
+	dataType, udt, dims := pgType(written)
+	if base, ok := d.domainTypes[udt]; ok {
+		dataType = base
+	}
+	if dims > 0 {
+		d.types[udt] = Type{Kind: ArrayType, Base: strings.TrimPrefix(udt, "_")}
+	}
+	return dataType, udt, dims
+}
+
+// ParseDDL reads the tables of the public schema, and unqualified ones, from
+// a dump. Statements other than CREATE TABLE, ALTER TABLE and CREATE UNIQUE
+// INDEX are skipped.
+func ParseDDL(r io.Reader) (*DDL, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	toks, err := ddlTokens(string(b))
+	if err != nil {
+		return nil, err
+	}
+	d := &DDL{tables: map[string]*ddlTable{}, types: map[string]Type{}, domainTypes: map[string]string{}}
+	for _, stmt := range splitTop(toks, ";") {
+		p := &ddlParser{toks: stmt}
+		switch {
+		case p.accept("create"):
+			for p.accept("unlogged") || p.accept("temporary") || p.accept("temp") || p.accept("global") || p.accept("local") {
+			}
+			if p.accept("unique") {
+				if err := d.uniqueIndex(p); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if p.accept("type") {
+				if err := d.createType(p); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if p.accept("domain") {
+				d.createDomain(p)
+				continue
+			}
+			if !p.accept("table") {
+				continue
+			}
+			if err := d.createTable(p); err != nil {
+				return nil, err
+			}
+		case p.accept("comment"):
+			d.commentOn(p)
+		case p.accept("alter"):
+			if !p.accept("table") {
+				continue
+			}
+			if err := d.alterTable(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// ddlTokens splits SQL into words, numbers, quoted identifiers, strings and
+// punctuation, dropping comments. Quoted identifiers keep their quotes so
+// they are not mistaken for keywords. A minus that cannot subtract is the
+// sign of the number after it.
+func ddlTokens(s string) ([]string, error) {
+	toks := []string{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(s[i:], "--"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 2
+		case c == '\'' || c == '"':
+			j := i + 1
+			for {
+				k := strings.IndexByte(s[j:], c)
+				if k < 0 {
+					return nil, fmt.Errorf("unterminated quote at %d", i)
+				}
+				j += k + 1
+				// doubled quotes escape themselves
+				if j < len(s) && s[j] == c {
+					j++
+					continue
+				}
+				break
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		case c == '$':
+			// dollar quoted bodies of functions
+			end := strings.IndexByte(s[i+1:], '$')
+			if end < 0 {
+				toks = append(toks, "$")
+				i++
+				continue
+			}
+			tag := s[i : i+end+2]
+			close := strings.Index(s[i+len(tag):], tag)
+			if close < 0 {
+				return nil, fmt.Errorf("unterminated %s quote", tag)
+			}
+			j := i + len(tag) + close + len(tag)
+			toks = append(toks, s[i:j])
+			i = j
+		case ddlNumberAt(s, i) || c == '-' && ddlNumberAt(s, i+1) && !ddlOperand(toks):
+			j := i + 1
+			for j < len(s) && unicode.IsDigit(rune(s[j])) {
+				j++
+			}
+			if j < len(s) && s[j] == '.' {
+				j++
+				for j < len(s) && unicode.IsDigit(rune(s[j])) {
+					j++
+				}
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				k := j + 1
+				if k < len(s) && (s[k] == '+' || s[k] == '-') {
+					k++
+				}
+				if k < len(s) && unicode.IsDigit(rune(s[k])) {
+					j = k
+					for j < len(s) && unicode.IsDigit(rune(s[j])) {
+						j++
+					}
+				}
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] == '$' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		case strings.HasPrefix(s[i:], "[]") || strings.HasPrefix(s[i:], "::"):
+			toks = append(toks, s[i:i+2])
+			i += 2
+		default:
+			toks = append(toks, string(c))
+			i++
+		}
+	}
+	return toks, nil
+}
+
+// ddlNumberAt reports whether a number starts at s[i], such as 20, 0.5 or .5.
+func ddlNumberAt(s string, i int) bool {
+	if i < len(s) && unicode.IsDigit(rune(s[i])) {
+		return true
+	}
+	return i+1 < len(s) && s[i] == '.' && unicode.IsDigit(rune(s[i+1]))
+}
+
+// ddlOperand reports whether the last token ends a value, so a minus after
+// it subtracts.
+func ddlOperand(toks []string) bool {
+	if len(toks) == 0 {
+		return false
+	}
+	last := toks[len(toks)-1]
+	switch c := rune(last[len(last)-1]); {
+	case c == ')' || c == ']' || c == '\'' || c == '"':
+		return true
+	case c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c):
+		return !ddlKeywords[strings.ToLower(last)] && !strings.EqualFold(last, "default")
+	}
+	return false
+}
+
+// splitTop splits tokens on sep outside parentheses.
+func splitTop(toks []string, sep string) [][]string {
+	parts := [][]string{}
+	depth, start := 0, 0
+	for i, t := range toks {
+		switch t {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, toks[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(toks) {
+		parts = append(parts, toks[start:])
+	}
+	return parts
+}
+
+type ddlParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *ddlParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *ddlParser) next() string {
+	t := p.peek()
+	if p.pos < len(p.toks) {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes a keyword, case insensitively.
+func (p *ddlParser) accept(kw string) bool {
+	if strings.EqualFold(p.peek(), kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// ident unquotes an identifier, unquoted ones fold to lower case.
+func ident(t string) string {
+	if strings.HasPrefix(t, `"`) {
+		return strings.Replace(t[1:len(t)-1], `""`, `"`, -1)
+	}
+	return strings.ToLower(t)
+}
+
+// name reads a possibly schema qualified name, reporting whether it is in
+// public.
+func (p *ddlParser) name() (string, bool) {
+	n := ident(p.next())
+	public := true
+	for p.peek() == "." {
+		p.next()
+		public = n == "public"
+		n = ident(p.next())
+	}
+	return n, public
+}
+
+// parens reads a parenthesised list of identifiers.
+func (p *ddlParser) parens() ([]string, error) {
+	if p.next() != "(" {
+		return nil, fmt.Errorf("expected ( at %s", p.peek())
+	}
+	names := []string{}
+	for {
+		names = append(names, ident(p.next()))
+		switch p.next() {
+		case ",":
+		case ")":
+			return names, nil
+		default:
+			return nil, fmt.Errorf("expected , or ) in column list")
+		}
+	}
+}
+
+// skip moves past a parenthesised group when there is one.
+func (p *ddlParser) skip() {
+	if p.peek() != "(" {
+		return
+	}
+	depth := 0
+	for p.pos < len(p.toks) {
+		switch p.next() {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (d *DDL) table(name string) *ddlTable {
+	t, ok := d.tables[name]
+	if !ok {
+		t = &ddlTable{}
+		d.tables[name] = t
+		d.order = append(d.order, name)
+	}
+	return t
+}
+
+func (d *DDL) createTable(p *ddlParser) error {
+	if p.accept("if") {
+		p.accept("not")
+		p.accept("exists")
+	}
+	name, public := p.name()
+	if !public {
+		return nil
+	}
+	if p.accept("partition") && p.accept("of") {
+		parent, _ := p.name()
+		t := d.table(name)
+		t.parent = parent
+		if p.peek() == "(" {
+			// constraints of the partition
+			p.skip()
+		}
+		t.bound = ddlText(p.toks[p.pos:])
+		if i := strings.Index(strings.ToLower(t.bound), " partition by "); i >= 0 {
+			// a partition partitioned in turn
+			t.bound = t.bound[:i]
+		}
+		return nil
+	}
+	if p.peek() != "(" {
+		// CREATE TABLE ... AS or OF type, no columns to read
+		return nil
+	}
+	start := p.pos
+	p.skip()
+	body := p.toks[start+1 : p.pos-1]
+	t := d.table(name)
+	for _, element := range splitTop(body, ",") {
+		e := &ddlParser{toks: element}
+		if err := d.tableElement(name, t, e); err != nil {
+			return fmt.Errorf("table %s: %v", name, err)
+		}
+	}
+	if p.accept("inherits") {
+		parents := splitTop(p.toks[p.pos+1:], ",")
+		if len(parents) > 0 {
+			// only the first parent holds the rows of a child
+			t.parent, _ = (&ddlParser{toks: parents[0]}).name()
+		}
+		p.skip()
+	}
+	if p.accept("partition") && p.accept("by") {
+		t.strategy = strings.ToLower(p.next())
+		if key, err := p.parens(); err == nil {
+			t.partKey = key
+		}
+	}
+	return nil
+}
+
+// ddlText joins tokens back into SQL text the way Postgres prints it.
+func ddlText(toks []string) string {
+	b := strings.Builder{}
+	for i, t := range toks {
+		if i > 0 && ddlSpace(toks[i-1], t) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+// ddlKeywords are followed by a space before a parenthesis, unlike the name
+// of a function.
+var ddlKeywords = map[string]bool{
+	"check": true, "from": true, "to": true, "in": true, "values": true, "with": true,
+	"and": true, "or": true, "not": true, "any": true, "all": true, "as": true, "is": true,
+}
+
+// ddlSpace reports whether a space goes between two tokens.
+func ddlSpace(prev string, t string) bool {
+	switch {
+	case prev == "(" || prev == "::" || t == ")" || t == "," || t == "::" || t == "[]":
+		return false
+	case (prev == "<" || prev == ">" || prev == "!") && (t == "=" || t == ">"):
+		return false
+	case t == "(":
+		first := rune(prev[0])
+		word := first == '_' || first == '"' || unicode.IsLetter(first)
+		return !word || ddlKeywords[strings.ToLower(prev)]
+	}
+	return true
+}
+
+// createType reads CREATE TYPE ... AS ENUM and composite types, other kinds
+// of type are skipped.
+func (d *DDL) createType(p *ddlParser) error {
+	name, _ := p.name()
+	if !p.accept("as") {
+		return nil
+	}
+	if p.accept("enum") {
+		t := Type{Kind: EnumType, Labels: []string{}}
+		for _, label := range splitTop(p.toks[p.pos+1:], ",") {
+			if len(label) > 0 && strings.HasPrefix(label[0], "'") {
+				t.Labels = append(t.Labels, strings.Replace(label[0][1:len(label[0])-1], "''", "'", -1))
+			}
+		}
+		d.types[name] = t
+		return nil
+	}
+	if p.peek() != "(" {
+		return nil
+	}
+	start := p.pos
+	p.skip()
+	t := Type{Kind: CompositeType}
+	for _, field := range splitTop(p.toks[start+1:p.pos-1], ",") {
+		if len(field) < 2 {
+			return fmt.Errorf("type %s: expected a field name and type", name)
+		}
+		typ := field[1:]
+		for i, tok := range typ {
+			if strings.EqualFold(tok, "collate") {
+				typ = typ[:i]
+				break
+			}
+		}
+		_, udt, _ := d.typeOf(typ)
+		t.Fields = append(t.Fields, Field{Name: ident(field[0]), Native: udt})
+	}
+	d.types[name] = t
+	return nil
+}
+
+// createDomain reads CREATE DOMAIN with its base type and CHECK constraints.
+func (d *DDL) createDomain(p *ddlParser) {
+	name, _ := p.name()
+	p.accept("as")
+	typ := []string{}
+	for p.pos < len(p.toks) && !constraintWords[strings.ToLower(p.peek())] {
+		typ = append(typ, p.next())
+	}
+	dataType, udt, _ := d.typeOf(typ)
+	t := Type{Kind: DomainType, Base: udt}
+	for p.pos < len(p.toks) {
+		if !p.accept("check") {
+			p.next()
+			continue
+		}
+		start := p.pos
+		p.skip()
+		t.Checks = append(t.Checks, "CHECK "+ddlText(p.toks[start:p.pos]))
+	}
+	d.types[name] = t
+	d.domainTypes[name] = dataType
+}
+
+// constraintWords end the type of a column definition.
+var constraintWords = map[string]bool{
+	"constraint": true, "not": true, "null": true, "default": true, "primary": true, "references": true,
+	"unique": true, "check": true, "collate": true, "generated": true,
+}
+
+func (d *DDL) tableElement(entity string, t *ddlTable, e *ddlParser) error {
+	switch first := strings.ToLower(e.peek()); first {
+	case "constraint", "primary", "foreign", "unique", "check", "exclude":
+		return d.constraint(entity, t, e)
+	case "like":
+		return nil
+	}
+	col := ident(e.next())
+	typ := []string{}
+	for e.pos < len(e.toks) && !constraintWords[strings.ToLower(e.peek())] {
+		typ = append(typ, e.next())
+	}
+	dataType, udt, dims := d.typeOf(typ)
+	t.columns = append(t.columns, Column{Entity: entity, Name: col, DataType: dataType, Native: udt, Dimensions: dims})
+
+	// inline constraints
+	c := &t.columns[len(t.columns)-1]
+	name := ""
+	for e.pos < len(e.toks) {
+		switch {
+		case e.accept("constraint"):
+			name = ident(e.next())
+		case e.accept("not"):
+			c.NotNull = e.accept("null")
+		case e.accept("default"):
+			c.Default = ddlExpr(e)
+		case e.accept("check"):
+			if name == "" {
+				name = entity + "_" + col + "_check"
+			}
+			t.checks = append(t.checks, Check{Name: name, Entity: entity, Columns: []string{col}, Definition: "CHECK " + ddlExpr(e)})
+		case e.accept("primary"):
+			e.accept("key")
+			t.key = []string{col}
+		case e.accept("unique"):
+			t.unique = append(t.unique, []string{col})
+		case e.accept("references"):
+			ref, public := e.name()
+			fk := ForeignKey{Name: entity + "_" + col + "_fkey", Entity: entity, Columns: []string{col}, RefEntity: ref}
+			if e.peek() == "(" {
+				refCols, err := e.parens()
+				if err != nil {
+					return err
+				}
+				fk.RefColumns = refCols
+			}
+			// references to other schemas lead out of the extraction
+			if public {
+				t.fks = append(t.fks, fk)
+			}
+		default:
+			e.next()
+			e.skip()
+		}
+	}
+	return nil
+}
+
+// constraint reads a table constraint, from CREATE TABLE or ALTER TABLE.
+func (d *DDL) constraint(entity string, t *ddlTable, e *ddlParser) error {
+	name := ""
+	if e.accept("constraint") {
+		name = ident(e.next())
+	}
+	switch {
+	case e.accept("primary"):
+		e.accept("key")
+		cols, err := e.parens()
+		if err != nil {
+			return err
+		}
+		t.key = cols
+	case e.accept("unique"):
+		if e.accept("nulls") {
+			e.accept("not")
+			e.accept("distinct")
+		}
+		cols, err := e.parens()
+		if err != nil {
+			// UNIQUE USING INDEX names an index instead of columns
+			return nil
+		}
+		t.unique = append(t.unique, cols)
+	case e.accept("check"):
+		if name == "" {
+			name = entity + "_check"
+		}
+		start := e.pos
+		def := "CHECK " + ddlExpr(e)
+		t.checks = append(t.checks, Check{Name: name, Entity: entity, Columns: checkColumns(t, e.toks[start:e.pos]), Definition: def})
+	case e.accept("foreign"):
+		e.accept("key")
+		cols, err := e.parens()
+		if err != nil {
+			return err
+		}
+		if !e.accept("references") {
+			return fmt.Errorf("expected REFERENCES in foreign key %s", name)
+		}
+		ref, public := e.name()
+		if name == "" {
+			name = entity + "_" + strings.Join(cols, "_") + "_fkey"
+		}
+		fk := ForeignKey{Name: name, Entity: entity, Columns: cols, RefEntity: ref}
+		if e.peek() == "(" {
+			if fk.RefColumns, err = e.parens(); err != nil {
+				return err
+			}
+		}
+		if public {
+			t.fks = append(t.fks, fk)
+		}
+	}
+	return nil
+}
+
+func (d *DDL) alterTable(p *ddlParser) error {
+	if p.accept("if") {
+		p.accept("exists")
+	}
+	p.accept("only")
+	name, public := p.name()
+	if !public {
+		return nil
+	}
+	for _, action := range splitTop(p.toks[p.pos:], ",") {
+		a := &ddlParser{toks: action}
+		if a.accept("attach") && a.accept("partition") {
+			child, _ := a.name()
+			c := d.table(child)
+			c.parent = name
+			c.bound = ddlText(a.toks[a.pos:])
+			continue
+		}
+		if a.accept("inherit") {
+			d.table(name).parent, _ = a.name()
+			continue
+		}
+		if a.accept("alter") {
+			d.alterColumn(d.table(name), a)
+			continue
+		}
+		if !a.accept("add") {
+			continue
+		}
+		switch strings.ToLower(a.peek()) {
+		case "constraint", "primary", "foreign", "unique", "check":
+			if err := d.constraint(name, d.table(name), a); err != nil {
+				return fmt.Errorf("table %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// alterColumn reads ALTER COLUMN SET DEFAULT and SET NOT NULL, which is how
+// pg_dump gives serial columns their default.
+func (d *DDL) alterColumn(t *ddlTable, a *ddlParser) {
+	a.accept("column")
+	col := ident(a.next())
+	for i := range t.columns {
+		if t.columns[i].Name != col {
+			continue
+		}
+		switch {
+		case a.accept("set") && a.accept("default"):
+			t.columns[i].Default = ddlExpr(a)
+		case a.accept("not") && a.accept("null"):
+			t.columns[i].NotNull = true
+		}
+	}
+}
+
+// commentOn reads COMMENT ON TABLE and COMMENT ON COLUMN.
+func (d *DDL) commentOn(p *ddlParser) {
+	if !p.accept("on") {
+		return
+	}
+	column := p.accept("column")
+	if !column && !p.accept("table") {
+		return
+	}
+	names := []string{ident(p.next())}
+	for p.peek() == "." {
+		p.next()
+		names = append(names, ident(p.next()))
+	}
+	if !p.accept("is") || !strings.HasPrefix(p.peek(), "'") {
+		// IS NULL drops a comment
+		return
+	}
+	lit := p.next()
+	text := strings.Replace(lit[1:len(lit)-1], "''", "'", -1)
+	if !column {
+		if len(names) == 1 || names[len(names)-2] == "public" {
+			d.table(names[len(names)-1]).comment = text
+		}
+		return
+	}
+	if len(names) < 2 || len(names) > 2 && names[len(names)-3] != "public" {
+		return
+	}
+	t, ok := d.tables[names[len(names)-2]]
+	if !ok {
+		return
+	}
+	for i := range t.columns {
+		if t.columns[i].Name == names[len(names)-1] {
+			t.columns[i].Comment = text
+		}
+	}
+}
+
+// ddlExpr reads an expression up to the next constraint of a column, as
+// Postgres prints it.
+func ddlExpr(e *ddlParser) string {
+	toks := []string{}
+	for e.pos < len(e.toks) && !constraintWords[strings.ToLower(e.peek())] {
+		start := e.pos
+		if e.peek() == "(" {
+			e.skip()
+		} else {
+			e.next()
+		}
+		toks = append(toks, e.toks[start:e.pos]...)
+	}
+	return ddlText(toks)
+}
+
+// checkColumns picks the columns of a table named in a CHECK constraint.
+func checkColumns(t *ddlTable, toks []string) []string {
+	named := map[string]bool{}
+	for _, tok := range toks {
+		named[ident(tok)] = true
+	}
+	cols := []string{}
+	for _, c := range t.columns {
+		if named[c.Name] {
+			cols = append(cols, c.Name)
+		}
+	}
+	return cols
+}
+
+// uniqueIndex reads CREATE UNIQUE INDEX, indexes on expressions or with a
+// WHERE clause do not make a key.
+func (d *DDL) uniqueIndex(p *ddlParser) error {
+	if !p.accept("index") {
+		return nil
+	}
+	p.accept("concurrently")
+	if p.accept("if") {
+		p.accept("not")
+		p.accept("exists")
+	}
+	if !strings.EqualFold(p.peek(), "on") {
+		p.next()
+	}
+	if !p.accept("on") {
+		return nil
+	}
+	p.accept("only")
+	name, public := p.name()
+	if !public {
+		return nil
+	}
+	if p.accept("using") {
+		p.next()
+	}
+	cols, err := p.parens()
+	if err != nil || p.accept("where") {
+		// an expression rather than a column list
+		return nil
+	}
+	t := d.table(name)
+	t.unique = append(t.unique, cols)
+	return nil
+}
+
+// names returns the tables in name order, leaving out partitions and
+// inheritance children which are read through their parent.
+func (d *DDL) names() []string {
+	names := []string{}
+	for _, name := range d.order {
+		if d.tables[name].parent == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *DDL) Columns() ([]Column, error) {
+	names := d.names()
+	cols := []Column{}
+	for _, name := range names {
+		cols = append(cols, d.tables[name].columns...)
+	}
+	return cols, nil
+}
+
+// Entities are the tables of the dump, views are skipped when parsing as
+// their columns are not written out.
+func (d *DDL) Entities() ([]Entity, error) {
+	entities := []Entity{}
+	for _, name := range d.names() {
+		entities = append(entities, Entity{Name: name, Kind: TableKind, Comment: d.tables[name].comment})
+	}
+	return entities, nil
+}
+
+func (d *DDL) Types() (map[string]Type, error) {
+	return d.types, nil
+}
+
+func (d *DDL) Lineage() (map[string][]string, error) {
+	return map[string][]string{}, nil
+}
+
+// Partitions gathers the partitions and children attached to each parent.
+func (d *DDL) Partitions() (map[string]Partitioning, error) {
+	partitionings := map[string]Partitioning{}
+	for _, name := range d.names() {
+		if t := d.tables[name]; t.strategy != "" {
+			partitionings[name] = Partitioning{Strategy: t.strategy, Key: t.partKey}
+		}
+	}
+	children := append([]string{}, d.order...)
+	sort.Strings(children)
+	for _, name := range children {
+		t := d.tables[name]
+		if t.parent == "" {
+			continue
+		}
+		// sub-partitions are listed under the entity at the top
+		root := t.parent
+		for parent, ok := d.tables[root]; ok && parent.parent != ""; parent, ok = d.tables[root] {
+			root = parent.parent
+		}
+		pt := partitionings[root]
+		if pt.Strategy == "" {
+			pt.Strategy = "inheritance"
+		}
+		pt.Partitions = append(pt.Partitions, Partition{Name: name, Bound: t.bound})
+		partitionings[root] = pt
+	}
+	return partitionings, nil
+}
+
+func (d *DDL) PrimaryKeys() (map[string][]string, error) {
+	keys := map[string][]string{}
+	for _, name := range d.names() {
+		if t := d.tables[name]; len(t.key) > 0 {
+			keys[name] = t.key
+		}
+	}
+	return keys, nil
+}
+
+// ForeignKeys fills in the referenced primary key of references that name
+// no columns.
+func (d *DDL) ForeignKeys() ([]ForeignKey, error) {
+	fks := []ForeignKey{}
+	for _, name := range d.names() {
+		for _, fk := range d.tables[name].fks {
+			if len(fk.RefColumns) == 0 {
+				if ref, ok := d.tables[fk.RefEntity]; ok {
+					fk.RefColumns = ref.key
+				}
+			}
+			if len(fk.RefColumns) != len(fk.Columns) {
+				continue
+			}
+			fks = append(fks, fk)
+		}
+	}
+	return fks, nil
+}
+
+func (d *DDL) Checks() ([]Check, error) {
+	checks := []Check{}
+	for _, name := range d.names() {
+		checks = append(checks, d.tables[name].checks...)
+	}
+	return checks, nil
+}
+
+func (d *DDL) UniqueKeys() (map[string][][]string, error) {
+	keys := map[string][][]string{}
+	for _, name := range d.names() {
+		if t := d.tables[name]; len(t.unique) > 0 {
+			keys[name] = t.unique
+		}
+	}
+	return keys, nil
+}
+
+func (d *DDL) IsUnique(entity string, cols []string) (bool, error) {
+	return false, ErrUnavailable
+}
+
+func (d *DDL) NumDistinct(entity string, col string) (int, error) {
+	return 0, ErrUnavailable
+}
+
+func (d *DDL) MaxDistinctPer(entity string, col string, other string) (int, error) {
+	return 0, ErrUnavailable
+}
+
+func (d *DDL) Histogram(entity string, col string, n int, depth bool) ([]Bucket, error) {
+	return nil, ErrUnavailable
+}
+
+func (d *DDL) TopValues(entity string, col string, k int) ([]Frequency, error) {
+	return nil, ErrUnavailable
+}
+
+func (d *DDL) Moments(entity string, col string) (Moments, bool, error) {
+	return Moments{}, false, ErrUnavailable
+}
+
+func (d *DDL) Correlation(entity string, col1 string, col2 string, sample float64) (sql.NullFloat64, sql.NullFloat64, error) {
+	return sql.NullFloat64{}, sql.NullFloat64{}, ErrUnavailable
+}
+
+func (d *DDL) SampleRows(entity string, cols []string, limit int) ([][]string, error) {
+	return nil, ErrUnavailable
+}
+
+func (d *DDL) RandomRows(entity string, cols []string, limit int) ([][]string, error) {
+	return nil, ErrUnavailable
+}
+
+func (d *DDL) ContainedInKey(entity string, cols []string, keyCols []string) (bool, error) {
+	return false, ErrUnavailable
+}
+
+func (d *DDL) RecursiveDepth(entity string, cols []string, keyCols []string) (int, bool, error) {
+	return 0, false, ErrUnavailable
+}
+
+// Fingerprints hash the definition of each table, checksum makes no
+// difference without data.
+func (d *DDL) Fingerprints(checksum bool) (map[string]string, error) {
+	fingerprints := map[string]string{}
+	for _, name := range d.names() {
+		t := d.tables[name]
+		fingerprints[name] = fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprint(t.columns, t.key, t.unique, t.fks))))
+	}
+	return fingerprints, nil
+}
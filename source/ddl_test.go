@@ -0,0 +1,226 @@
+package source
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const dump = `--
+-- PostgreSQL database dump
+--
+SET statement_timeout = 0;
+
+CREATE FUNCTION public.touch() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$ begin new.updated = now(); return new; end; $$;
+
+CREATE TABLE public.country (
+    name character varying(50) NOT NULL,
+    code character varying(4) NOT NULL,
+    area numeric,
+    "Founded" date,
+    CONSTRAINT countryarea CHECK ((area >= (0)::numeric))
+);
+
+CREATE TABLE public.city (
+    name character varying(50) NOT NULL,
+    country character varying(4) NOT NULL REFERENCES public.country,
+    population numeric DEFAULT 0,
+    tags text[],
+    seen timestamp(3) with time zone,
+    PRIMARY KEY (name, country)
+);
+
+CREATE TABLE public.river (
+    name text CONSTRAINT riverkey PRIMARY KEY,
+    river text,
+    length double precision,
+    spring text REFERENCES geo.spring (name)
+);
+
+CREATE TABLE audit.log (id integer);
+
+ALTER TABLE ONLY public.country
+    ADD CONSTRAINT countrykey PRIMARY KEY (code);
+
+ALTER TABLE ONLY public.river
+    ADD CONSTRAINT riverfk FOREIGN KEY (river) REFERENCES public.river(name);
+
+ALTER TABLE ONLY public.city
+    ADD CONSTRAINT cityaudit FOREIGN KEY (country) REFERENCES audit.country(code);
+
+ALTER TABLE public.country OWNER TO mondial;
+
+ALTER TABLE ONLY public.country
+    ADD CONSTRAINT countryname UNIQUE (name);
+
+CREATE UNIQUE INDEX riverlength ON public.river USING btree (length, name);
+
+CREATE UNIQUE INDEX riverlower ON public.river USING btree (lower(name));
+`
+
+func TestParseDDL(t *testing.T) {
+	d, err := ParseDDL(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, err := d.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, c := range cols {
+		got = append(got, c.Entity+"."+c.Name+" "+c.DataType+" "+c.Native)
+	}
+	want := []string{
+		"city.name character varying varchar", "city.country character varying varchar", "city.population numeric numeric",
+		"city.tags ARRAY _text", "city.seen timestamp with time zone timestamptz",
+		"country.name character varying varchar", "country.code character varying varchar", "country.area numeric numeric",
+		"country.Founded date date",
+		"river.name text text", "river.river text text", "river.length double precision float8",
+		"river.spring text text",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns:\nwanted %v\ngot    %v", want, got)
+	}
+
+	keys, _ := d.PrimaryKeys()
+	wantKeys := map[string][]string{"city": {"name", "country"}, "country": {"code"}, "river": {"name"}}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("keys: wanted %v got %v", wantKeys, keys)
+	}
+
+	fks, _ := d.ForeignKeys()
+	wantFKs := []ForeignKey{
+		{Name: "city_country_fkey", Entity: "city", Columns: []string{"country"}, RefEntity: "country", RefColumns: []string{"code"}},
+		{Name: "riverfk", Entity: "river", Columns: []string{"river"}, RefEntity: "river", RefColumns: []string{"name"}},
+	}
+	if !reflect.DeepEqual(fks, wantFKs) {
+		t.Errorf("foreign keys: wanted %v got %v", wantFKs, fks)
+	}
+
+	unique, _ := d.UniqueKeys()
+	wantUnique := map[string][][]string{"country": {{"name"}}, "river": {{"length", "name"}}}
+	if !reflect.DeepEqual(unique, wantUnique) {
+		t.Errorf("unique keys: wanted %v got %v", wantUnique, unique)
+	}
+
+	if _, err := d.NumDistinct("city", "name"); err != ErrUnavailable {
+		t.Errorf("wanted statistics unavailable got %v", err)
+	}
+}
+
+func TestParseDDLPartitions(t *testing.T) {
+	d, err := ParseDDL(strings.NewReader(`
+CREATE TABLE public.measurement (city_id integer NOT NULL, logdate date NOT NULL, peaktemp integer) PARTITION BY RANGE (logdate);
+CREATE TABLE public.measurement_y2019m01 (city_id integer NOT NULL, logdate date NOT NULL, peaktemp integer);
+ALTER TABLE ONLY public.measurement ATTACH PARTITION public.measurement_y2019m01 FOR VALUES FROM ('2019-01-01') TO ('2019-02-01');
+CREATE TABLE public.measurement_y2019m02 PARTITION OF public.measurement FOR VALUES FROM ('2019-02-01') TO ('2019-03-01');
+CREATE TABLE public.cities (name text, population integer);
+CREATE TABLE public.capitals (state character(2)) INHERITS (public.cities);
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities, _ := d.Entities()
+	wantEntities := []Entity{{Name: "cities", Kind: TableKind}, {Name: "measurement", Kind: TableKind}}
+	if !reflect.DeepEqual(entities, wantEntities) {
+		t.Errorf("entities: wanted %v got %v", wantEntities, entities)
+	}
+
+	partitions, _ := d.Partitions()
+	want := map[string]Partitioning{
+		"measurement": {Strategy: "range", Key: []string{"logdate"}, Partitions: []Partition{
+			{Name: "measurement_y2019m01", Bound: "FOR VALUES FROM ('2019-01-01') TO ('2019-02-01')"},
+			{Name: "measurement_y2019m02", Bound: "FOR VALUES FROM ('2019-02-01') TO ('2019-03-01')"},
+		}},
+		"cities": {Strategy: "inheritance", Partitions: []Partition{{Name: "capitals"}}},
+	}
+	if !reflect.DeepEqual(partitions, want) {
+		t.Errorf("partitions:\nwanted %v\ngot    %v", want, partitions)
+	}
+}
+
+func TestParseDDLTypes(t *testing.T) {
+	d, err := ParseDDL(strings.NewReader(`
+CREATE TYPE public.mood AS ENUM ('sad', 'ok', 'happy', 'it''s fine');
+CREATE TYPE public.address AS (street text, zip character varying(10));
+CREATE DOMAIN public.posint AS integer CONSTRAINT posint_check CHECK ((VALUE > 0));
+CREATE TYPE public."Level" AS ENUM ('low', 'high');
+CREATE TABLE public.person (name text, mood public.mood, home public.address, age public.posint, scores integer[][],
+    level public."Level", levels public."Level"[]);
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, _ := d.Columns()
+	got := []string{}
+	for _, c := range cols {
+		got = append(got, fmt.Sprintf("%s %s %s %d", c.Name, c.DataType, c.Native, c.Dimensions))
+	}
+	want := []string{"name text text 0", "mood USER-DEFINED mood 0", "home USER-DEFINED address 0", "age integer posint 0", "scores ARRAY _int4 2",
+		"level USER-DEFINED Level 0", "levels ARRAY _Level 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns:\nwanted %v\ngot    %v", want, got)
+	}
+
+	types, _ := d.Types()
+	wantTypes := map[string]Type{
+		"mood":    {Kind: EnumType, Labels: []string{"sad", "ok", "happy", "it's fine"}},
+		"address": {Kind: CompositeType, Fields: []Field{{Name: "street", Native: "text"}, {Name: "zip", Native: "varchar"}}},
+		"posint":  {Kind: DomainType, Base: "int4", Checks: []string{"CHECK ((VALUE > 0))"}},
+		"_int4":   {Kind: ArrayType, Base: "int4"},
+		"Level":   {Kind: EnumType, Labels: []string{"low", "high"}},
+		"_Level":  {Kind: ArrayType, Base: "Level"},
+	}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Errorf("types:\nwanted %v\ngot    %v", wantTypes, types)
+	}
+}
+
+func TestParseDDLConstraints(t *testing.T) {
+	d, err := ParseDDL(strings.NewReader(`
+CREATE TABLE public.country (
+    code character varying(4) NOT NULL,
+    area numeric DEFAULT 0 CHECK (area >= 0),
+    density numeric DEFAULT 0.5 CHECK ((density > (-20.5)::numeric)),
+    growth double precision DEFAULT -1.5e-3,
+    founded date,
+    CONSTRAINT countryfounded CHECK ((founded > '1000-01-01'::date))
+);
+ALTER TABLE ONLY public.country ALTER COLUMN founded SET DEFAULT now();
+ALTER TABLE public.country ADD CONSTRAINT countrycode CHECK ((length((code)::text) >= 1));
+COMMENT ON TABLE public.country IS 'Countries of the world';
+COMMENT ON COLUMN public.country.area IS 'Area in km''2';
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, _ := d.Columns()
+	got := []string{}
+	for _, c := range cols {
+		got = append(got, fmt.Sprintf("%s %v %q %q", c.Name, c.NotNull, c.Default, c.Comment))
+	}
+	want := []string{`code true "" ""`, `area false "0" "Area in km'2"`, `density false "0.5" ""`, `growth false "-1.5e-3" ""`, `founded false "now()" ""`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns:\nwanted %v\ngot    %v", want, got)
+	}
+
+	entities, _ := d.Entities()
+	if len(entities) != 1 || entities[0].Comment != "Countries of the world" {
+		t.Errorf("wanted the table comment got %v", entities)
+	}
+
+	checks, _ := d.Checks()
+	wantChecks := []Check{
+		{Name: "country_area_check", Entity: "country", Columns: []string{"area"}, Definition: "CHECK (area >= 0)"},
+		{Name: "country_density_check", Entity: "country", Columns: []string{"density"}, Definition: "CHECK ((density > (-20.5)::numeric))"},
+		{Name: "countryfounded", Entity: "country", Columns: []string{"founded"}, Definition: "CHECK ((founded > '1000-01-01'::date))"},
+		{Name: "countrycode", Entity: "country", Columns: []string{"code"}, Definition: "CHECK ((length((code)::text) >= 1))"},
+	}
+	if !reflect.DeepEqual(checks, wantChecks) {
+		t.Errorf("checks:\nwanted %v\ngot    %v", wantChecks, checks)
+	}
+}
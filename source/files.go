@@ -0,0 +1,765 @@
+package source
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Files profiles CSV files, each file is an entity named after it without
+// its extension. Empty fields are NULL. There is no catalog, so column types
+// are inferred from the values and keys are guessed from uniqueness.
+type Files struct {
+	// MemoryRows is how many rows of a file are held in memory, larger files
+	// are read again for every statistic and spill their groupings to
+	// temporary files once they pass this many pairs.
+	MemoryRows int
+	files      map[string]*file
+}
+
+type file struct {
+	path   string
+	header []string
+	types  []string
+	rows   [][]string
+	count  int
+}
+
+// partitions is how many temporary files a spilled grouping is hashed into.
+const partitions = 64
+
+// fileTypes are the inferred types from the most to the least specific, a
+// column takes the first type all its values parse as. They are not a chain,
+// true is no integer and 1999 is no date, so each type is checked on its own.
+var fileTypes = []struct {
+	native   string
+	dataType string
+	parses   func(string) bool
+}{
+	{"boolean", "boolean", func(v string) bool {
+		_, err := strconv.ParseBool(v)
+		return err == nil && !isDigits(v)
+	}},
+	{"integer", "integer", func(v string) bool {
+		_, err := strconv.ParseInt(v, 10, 64)
+		return err == nil
+	}},
+	{"numeric", "numeric", func(v string) bool {
+		_, err := strconv.ParseFloat(v, 64)
+		return err == nil
+	}},
+	{"date", "date", func(v string) bool {
+		_, err := time.Parse("2006-01-02", v)
+		return err == nil
+	}},
+	// a date is midnight of that day
+	{"timestamp", "timestamp without time zone", func(v string) bool {
+		_, ok := parseTimestamp(v)
+		return ok
+	}},
+	{"text", "text", func(string) bool { return true }},
+}
+
+func isDigits(v string) bool {
+	return strings.Trim(v, "0123456789") == ""
+}
+
+func parseTimestamp(v string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NewFiles reads the header of every CSV or TSV file in paths, directories
+// contribute the files directly inside them, and infers the column types.
+func NewFiles(paths []string, memoryRows int) (*Files, error) {
+	f := &Files{MemoryRows: memoryRows, files: map[string]*file{}}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		found := []string{p}
+		if info.IsDir() {
+			found = nil
+			for _, pattern := range []string{"*.csv", "*.tsv"} {
+				matches, err := filepath.Glob(filepath.Join(p, pattern))
+				if err != nil {
+					return nil, err
+				}
+				found = append(found, matches...)
+			}
+		}
+		for _, path := range found {
+			entity := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			if _, ok := f.files[entity]; ok {
+				return nil, fmt.Errorf("%s and %s are both entity %s", f.files[entity].path, path, entity)
+			}
+			fl := &file{path: path}
+			if err := f.load(fl); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			f.files[entity] = fl
+		}
+	}
+	return f, nil
+}
+
+// load infers the types in one pass, keeping the rows when there are few
+// enough.
+func (f *Files) load(fl *file) error {
+	fl.rows = [][]string{}
+	// possible holds the types every value of a column so far parses as
+	possible := [][]bool{}
+	err := f.each(fl, func(row []string) error {
+		if fl.header == nil {
+			fl.header = append([]string{}, row...)
+			possible = make([][]bool, len(row))
+			for i := range possible {
+				possible[i] = make([]bool, len(fileTypes))
+				for t := range fileTypes {
+					possible[i][t] = true
+				}
+			}
+			return nil
+		}
+		for i, v := range row {
+			if v == "" {
+				continue
+			}
+			for t, ok := range possible[i] {
+				if ok && !fileTypes[t].parses(v) {
+					possible[i][t] = false
+				}
+			}
+		}
+		fl.count++
+		if fl.rows != nil {
+			fl.rows = append(fl.rows, append([]string{}, row...))
+			if len(fl.rows) > f.MemoryRows {
+				fl.rows = nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if fl.header == nil {
+		return fmt.Errorf("no header")
+	}
+	fl.types = make([]string, len(possible))
+	for i, types := range possible {
+		t := 0
+		for !types[t] {
+			t++
+		}
+		fl.types[i] = fileTypes[t].native
+	}
+	return nil
+}
+
+// each calls fn with every record of the file, the header first.
+func (f *Files) each(fl *file, fn func(row []string) error) error {
+	in, err := os.Open(fl.path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r := csv.NewReader(in)
+	if strings.EqualFold(filepath.Ext(fl.path), ".tsv") {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+	r.ReuseRecord = true
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// scan calls fn with every data row of an entity, from memory when it is
+// held there. The row must not be kept.
+func (f *Files) scan(entity string, fn func(row []string) error) error {
+	fl, ok := f.files[entity]
+	if !ok {
+		return fmt.Errorf("no file for entity %s", entity)
+	}
+	if fl.rows != nil {
+		for _, row := range fl.rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	header := true
+	return f.each(fl, func(row []string) error {
+		if header {
+			header = false
+			return nil
+		}
+		return fn(row)
+	})
+}
+
+// index finds the positions of columns in the header of an entity.
+func (f *Files) index(entity string, cols ...string) ([]int, error) {
+	fl, ok := f.files[entity]
+	if !ok {
+		return nil, fmt.Errorf("no file for entity %s", entity)
+	}
+	idx := make([]int, len(cols))
+	for i, c := range cols {
+		idx[i] = -1
+		for j, h := range fl.header {
+			if h == c {
+				idx[i] = j
+				break
+			}
+		}
+		if idx[i] < 0 {
+			return nil, fmt.Errorf("no column %s in %s", c, fl.path)
+		}
+	}
+	return idx, nil
+}
+
+// group collects the distinct values paired with each key and calls reduce
+// once per key. Past MemoryRows pairs everything is hashed by key into
+// temporary partition files, and each partition is grouped on its own.
+func (f *Files) group(entity string, pair func(row []string) (string, string, bool), reduce func(key string, values map[string]bool)) error {
+	groups := map[string]map[string]bool{}
+	pairs := 0
+	var spill []*csv.Writer
+	var spillFiles []*os.File
+	defer func() {
+		for _, sf := range spillFiles {
+			sf.Close()
+			os.Remove(sf.Name())
+		}
+	}()
+	write := func(key string, value string) error {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return spill[h.Sum32()%partitions].Write([]string{key, value})
+	}
+
+	err := f.scan(entity, func(row []string) error {
+		key, value, ok := pair(row)
+		if !ok {
+			return nil
+		}
+		if spill != nil {
+			return write(key, value)
+		}
+		if groups[key] == nil {
+			groups[key] = map[string]bool{}
+		}
+		if !groups[key][value] {
+			groups[key][value] = true
+			pairs++
+		}
+		if pairs <= f.MemoryRows {
+			return nil
+		}
+		for i := 0; i < partitions; i++ {
+			sf, err := ioutil.TempFile("", "vis-extractor-spill")
+			if err != nil {
+				return err
+			}
+			spillFiles = append(spillFiles, sf)
+			spill = append(spill, csv.NewWriter(sf))
+		}
+		for k, values := range groups {
+			for v := range values {
+				if err := write(k, v); err != nil {
+					return err
+				}
+			}
+		}
+		groups = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if spill == nil {
+		for k, values := range groups {
+			reduce(k, values)
+		}
+		return nil
+	}
+
+	for i, w := range spill {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+		if _, err := spillFiles[i].Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		groups := map[string]map[string]bool{}
+		r := csv.NewReader(spillFiles[i])
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if groups[rec[0]] == nil {
+				groups[rec[0]] = map[string]bool{}
+			}
+			groups[rec[0]][rec[1]] = true
+		}
+		for k, values := range groups {
+			reduce(k, values)
+		}
+	}
+	return nil
+}
+
+func (f *Files) Columns() ([]Column, error) {
+	entities := []string{}
+	for entity := range f.files {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	cols := []Column{}
+	for _, entity := range entities {
+		fl := f.files[entity]
+		for i, name := range fl.header {
+			dataType := ""
+			for _, t := range fileTypes {
+				if t.native == fl.types[i] {
+					dataType = t.dataType
+				}
+			}
+			cols = append(cols, Column{Entity: entity, Name: name, DataType: dataType, Native: fl.types[i]})
+		}
+	}
+	return cols, nil
+}
+
+// Entities are all tables, one for each file.
+func (f *Files) Entities() ([]Entity, error) {
+	entities := []Entity{}
+	for name := range f.files {
+		entities = append(entities, Entity{Name: name, Kind: TableKind})
+	}
+	sort.Slice(entities, func(i, j int) bool { return entities[i].Name < entities[j].Name })
+	return entities, nil
+}
+
+func (f *Files) Lineage() (map[string][]string, error) {
+	return map[string][]string{}, nil
+}
+
+func (f *Files) Checks() ([]Check, error) {
+	return []Check{}, nil
+}
+
+func (f *Files) Partitions() (map[string]Partitioning, error) {
+	return map[string]Partitioning{}, nil
+}
+
+func (f *Files) Types() (map[string]Type, error) {
+	return map[string]Type{}, nil
+}
+
+// PrimaryKeys is always empty, files declare no keys and the candidate key
+// phase discovers them in the data instead.
+func (f *Files) PrimaryKeys() (map[string][]string, error) {
+	return map[string][]string{}, nil
+}
+
+// UniqueKeys is always empty, files declare no constraints.
+func (f *Files) UniqueKeys() (map[string][][]string, error) {
+	return map[string][][]string{}, nil
+}
+
+// IsUnique groups the values of cols, spilling as other groupings do.
+func (f *Files) IsUnique(entity string, cols []string) (bool, error) {
+	idx, err := f.index(entity, cols...)
+	if err != nil {
+		return false, err
+	}
+	nulls := false
+	distinct := 0
+	err = f.group(entity, func(row []string) (string, string, bool) {
+		parts := make([]string, len(idx))
+		for i, j := range idx {
+			if row[j] == "" {
+				nulls = true
+			}
+			parts[i] = row[j]
+		}
+		return strings.Join(parts, "\x1f"), "", true
+	}, func(string, map[string]bool) {
+		distinct++
+	})
+	return err == nil && !nulls && distinct == f.files[entity].count, err
+}
+
+// ForeignKeys is always empty, files declare no references.
+func (f *Files) ForeignKeys() ([]ForeignKey, error) {
+	return []ForeignKey{}, nil
+}
+
+func (f *Files) NumDistinct(entity string, col string) (int, error) {
+	idx, err := f.index(entity, col)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = f.group(entity, func(row []string) (string, string, bool) {
+		return row[idx[0]], "", row[idx[0]] != ""
+	}, func(string, map[string]bool) {
+		count++
+	})
+	return count, err
+}
+
+// MaxDistinctPer groups NULLs of col together as GROUP BY does and ignores
+// NULLs of other as count(distinct) does.
+func (f *Files) MaxDistinctPer(entity string, col string, other string) (int, error) {
+	idx, err := f.index(entity, col, other)
+	if err != nil {
+		return 0, err
+	}
+	max := 0
+	err = f.group(entity, func(row []string) (string, string, bool) {
+		return row[idx[0]], row[idx[1]], true
+	}, func(_ string, values map[string]bool) {
+		n := len(values)
+		if values[""] {
+			n--
+		}
+		if n > max {
+			max = n
+		}
+	})
+	return max, err
+}
+
+// floats reads the non NULL values of the columns that parse as numbers,
+// keeping a sample percentage of the rows, all of them for 0.
+func (f *Files) floats(entity string, cols []string, sample float64) ([][]float64, error) {
+	idx, err := f.index(entity, cols...)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]float64, len(cols))
+	err = f.scan(entity, func(row []string) error {
+		if sample > 0 && sample < 100 && rand.Float64()*100 >= sample {
+			return nil
+		}
+		parsed := make([]float64, len(idx))
+		for i, j := range idx {
+			v, err := strconv.ParseFloat(row[j], 64)
+			if err != nil {
+				return nil
+			}
+			parsed[i] = v
+		}
+		for i, v := range parsed {
+			values[i] = append(values[i], v)
+		}
+		return nil
+	})
+	return values, err
+}
+
+func (f *Files) Histogram(entity string, col string, n int, depth bool) ([]Bucket, error) {
+	values, err := f.floats(entity, []string{col}, 0)
+	if err != nil || len(values[0]) == 0 {
+		return nil, err
+	}
+	sorted := values[0]
+	sort.Float64s(sorted)
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	if depth {
+		// ntile puts the extra rows in the first buckets
+		buckets := []Bucket{}
+		size, extra := len(sorted)/n, len(sorted)%n
+		for start := 0; start < len(sorted); {
+			end := start + size
+			if len(buckets) < extra {
+				end++
+			}
+			buckets = append(buckets, Bucket{Lower: sorted[start], Upper: sorted[end-1], Count: end - start})
+			start = end
+		}
+		return buckets, nil
+	}
+	if lo == hi {
+		return WidthBuckets(lo, hi, 1, map[int]int{1: len(sorted)}), nil
+	}
+	counts := map[int]int{}
+	for _, v := range sorted {
+		b := int((v-lo)/((hi-lo)/float64(n))) + 1
+		if b > n {
+			b = n
+		}
+		counts[b]++
+	}
+	return WidthBuckets(lo, hi, n, counts), nil
+}
+
+func (f *Files) TopValues(entity string, col string, k int) ([]Frequency, error) {
+	idx, err := f.index(entity, col)
+	if err != nil {
+		return nil, err
+	}
+	// pairing each value with its row number counts the rows holding it
+	rowNum := 0
+	freqs := []Frequency{}
+	err = f.group(entity, func(row []string) (string, string, bool) {
+		rowNum++
+		return row[idx[0]], strconv.Itoa(rowNum), row[idx[0]] != ""
+	}, func(value string, rows map[string]bool) {
+		freqs = append(freqs, Frequency{Value: value, Count: len(rows)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(freqs, func(i, j int) bool {
+		if freqs[i].Count != freqs[j].Count {
+			return freqs[i].Count > freqs[j].Count
+		}
+		return freqs[i].Value < freqs[j].Value
+	})
+	if len(freqs) > k {
+		freqs = freqs[:k]
+	}
+	return freqs, nil
+}
+
+func (f *Files) Moments(entity string, col string) (Moments, bool, error) {
+	values, err := f.floats(entity, []string{col}, 0)
+	if err != nil {
+		return Moments{}, false, err
+	}
+	m, ok := momentsOf(values[0])
+	return m, ok, nil
+}
+
+func (f *Files) Correlation(entity string, col1 string, col2 string, sample float64) (sql.NullFloat64, sql.NullFloat64, error) {
+	values, err := f.floats(entity, []string{col1, col2}, sample)
+	if err != nil {
+		return sql.NullFloat64{}, sql.NullFloat64{}, err
+	}
+	xs, ys := values[0], values[1]
+	return pearson(xs, ys), pearson(ranks(xs), ranks(ys)), nil
+}
+
+func (f *Files) SampleRows(entity string, cols []string, limit int) ([][]string, error) {
+	idx, err := f.index(entity, cols...)
+	if err != nil {
+		return nil, err
+	}
+	sample := [][]string{}
+	errLimit := fmt.Errorf("limit reached")
+	err = f.scan(entity, func(row []string) error {
+		if limit > 0 && len(sample) == limit {
+			return errLimit
+		}
+		sample = append(sample, pickFields(row, idx))
+		return nil
+	})
+	if err == errLimit {
+		err = nil
+	}
+	return sample, err
+}
+
+// RandomRows keeps a reservoir, so every row read so far has the same
+// chance of being in it.
+func (f *Files) RandomRows(entity string, cols []string, limit int) ([][]string, error) {
+	idx, err := f.index(entity, cols...)
+	if err != nil {
+		return nil, err
+	}
+	sample := [][]string{}
+	seen := 0
+	err = f.scan(entity, func(row []string) error {
+		seen++
+		if limit <= 0 || len(sample) < limit {
+			sample = append(sample, pickFields(row, idx))
+		} else if at := rand.Intn(seen); at < limit {
+			sample[at] = pickFields(row, idx)
+		}
+		return nil
+	})
+	return sample, err
+}
+
+// pickFields copies the fields at idx of a row with empty fields as NullValue.
+func pickFields(row []string, idx []int) []string {
+	s := make([]string, len(idx))
+	for i, j := range idx {
+		s[i] = row[j]
+		if s[i] == "" {
+			s[i] = NullValue
+		}
+	}
+	return s
+}
+
+func (f *Files) ContainedInKey(entity string, cols []string, keyCols []string) (bool, error) {
+	idx, err := f.index(entity, cols...)
+	if err != nil {
+		return false, err
+	}
+	keyIdx, err := f.index(entity, keyCols...)
+	if err != nil {
+		return false, err
+	}
+	tuple := func(row []string, idx []int) (string, bool) {
+		parts := make([]string, len(idx))
+		for i, j := range idx {
+			if row[j] == "" {
+				return "", false
+			}
+			parts[i] = row[j]
+		}
+		return strings.Join(parts, "\x1f"), true
+	}
+	keys := map[string]bool{}
+	err = f.scan(entity, func(row []string) error {
+		if k, ok := tuple(row, keyIdx); ok {
+			keys[k] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	total, differ, missing := 0, 0, 0
+	err = f.scan(entity, func(row []string) error {
+		v, ok := tuple(row, idx)
+		if !ok {
+			return nil
+		}
+		total++
+		if k, _ := tuple(row, keyIdx); v != k {
+			differ++
+		}
+		if !keys[v] {
+			missing++
+		}
+		return nil
+	})
+	return total > 0 && differ > 0 && missing == 0, err
+}
+
+// RecursiveDepth walks the references in memory, a row whose reference
+// matches no key is a root.
+func (f *Files) RecursiveDepth(entity string, cols []string, keyCols []string) (int, bool, error) {
+	colIdx, err := f.index(entity, cols...)
+	if err != nil {
+		return 0, false, err
+	}
+	keyIdx, err := f.index(entity, keyCols...)
+	if err != nil {
+		return 0, false, err
+	}
+	join := func(row []string, idx []int) (string, bool) {
+		parts := make([]string, len(idx))
+		for i, j := range idx {
+			if row[j] == "" {
+				return "", false
+			}
+			parts[i] = row[j]
+		}
+		return strings.Join(parts, "\x1f"), true
+	}
+	parents := map[string]string{}
+	order := []string{}
+	err = f.scan(entity, func(row []string) error {
+		k, _ := join(row, keyIdx)
+		ref, ok := join(row, colIdx)
+		if !ok {
+			ref = "\x00"
+		}
+		parents[k] = ref
+		order = append(order, k)
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	children := map[string][]string{}
+	level := []string{}
+	for _, k := range order {
+		if _, ok := parents[parents[k]]; ok {
+			children[parents[k]] = append(children[parents[k]], k)
+		} else {
+			level = append(level, k)
+		}
+	}
+	depth, reached := -1, map[string]bool{}
+	for len(level) > 0 {
+		depth++
+		next := []string{}
+		for _, k := range level {
+			if reached[k] {
+				continue
+			}
+			reached[k] = true
+			next = append(next, children[k]...)
+		}
+		level = next
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	return depth, len(reached) < len(order), nil
+}
+
+// Fingerprints hash the header and inferred types with the size and
+// modification time of each file, or with checksum its contents.
+func (f *Files) Fingerprints(checksum bool) (map[string]string, error) {
+	fingerprints := map[string]string{}
+	for entity, fl := range f.files {
+		cols := fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(fl.header, ",")+"/"+strings.Join(fl.types, ","))))
+		if checksum {
+			b, err := ioutil.ReadFile(fl.path)
+			if err != nil {
+				return nil, err
+			}
+			fingerprints[entity] = fmt.Sprintf("%s/%x", cols, md5.Sum(b))
+			continue
+		}
+		info, err := os.Stat(fl.path)
+		if err != nil {
+			return nil, err
+		}
+		fingerprints[entity] = fmt.Sprintf("%s/%d/%d", cols, info.Size(), info.ModTime().UnixNano())
+	}
+	return fingerprints, nil
+}
@@ -0,0 +1,147 @@
+package source
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const cityCSV = `name,country,population,founded,capital
+Berlin,D,3500000,1237-01-01,true
+Hamburg,D,1800000,1189-05-07,false
+Munich,D,1500000,1158-06-14,false
+Paris,F,2100000,,true
+Lyon,F,500000,,false
+Tokyo,J,,1457-01-01,true
+`
+
+// mixedCSV has values of no single type in flag and year, and values that
+// widen to a common type in amount and seen.
+const mixedCSV = `flag,year,amount,seen
+true,1999,5,2020-01-01
+5,2020-01-01,1.5,2020-01-02 10:00:00
+`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFilesColumns(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"city.csv": cityCSV, "mixed.csv": mixedCSV, "notes.txt": "ignored"})
+	f, err := NewFiles([]string{dir}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, err := f.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Column{
+		{Entity: "city", Name: "name", DataType: "text", Native: "text"},
+		{Entity: "city", Name: "country", DataType: "text", Native: "text"},
+		{Entity: "city", Name: "population", DataType: "integer", Native: "integer"},
+		{Entity: "city", Name: "founded", DataType: "date", Native: "date"},
+		{Entity: "city", Name: "capital", DataType: "boolean", Native: "boolean"},
+		{Entity: "mixed", Name: "flag", DataType: "text", Native: "text"},
+		{Entity: "mixed", Name: "year", DataType: "text", Native: "text"},
+		{Entity: "mixed", Name: "amount", DataType: "numeric", Native: "numeric"},
+		{Entity: "mixed", Name: "seen", DataType: "timestamp without time zone", Native: "timestamp"},
+	}
+	if !reflect.DeepEqual(cols, want) {
+		t.Errorf("wanted %v got %v", want, cols)
+	}
+	keys, err := f.PrimaryKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("wanted no declared keys got %v", keys)
+	}
+}
+
+// TestFilesSpill checks the groupings give the same answers held in memory
+// and spilled to partition files.
+func TestFilesSpill(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"city.csv": cityCSV})
+	for _, memoryRows := range []int{100, 1} {
+		f, err := NewFiles([]string{filepath.Join(dir, "city.csv")}, memoryRows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := f.NumDistinct("city", "country"); err != nil || n != 3 {
+			t.Errorf("memory %d: distinct countries wanted 3 got %d (%v)", memoryRows, n, err)
+		}
+		if n, err := f.NumDistinct("city", "founded"); err != nil || n != 4 {
+			t.Errorf("memory %d: distinct founding dates wanted 4 got %d (%v)", memoryRows, n, err)
+		}
+		if n, err := f.MaxDistinctPer("city", "country", "name"); err != nil || n != 3 {
+			t.Errorf("memory %d: cities per country wanted 3 got %d (%v)", memoryRows, n, err)
+		}
+		if n, err := f.MaxDistinctPer("city", "name", "country"); err != nil || n != 1 {
+			t.Errorf("memory %d: countries per city wanted 1 got %d (%v)", memoryRows, n, err)
+		}
+		top, err := f.TopValues("city", "country", 2)
+		want := []Frequency{{Value: "D", Count: 3}, {Value: "F", Count: 2}}
+		if err != nil || !reflect.DeepEqual(top, want) {
+			t.Errorf("memory %d: top values wanted %v got %v (%v)", memoryRows, want, top, err)
+		}
+		buckets, err := f.Histogram("city", "population", 2, true)
+		wantBuckets := []Bucket{{500000, 1800000, 3}, {2100000, 3500000, 2}}
+		if err != nil || !reflect.DeepEqual(buckets, wantBuckets) {
+			t.Errorf("memory %d: depth histogram wanted %v got %v (%v)", memoryRows, wantBuckets, buckets, err)
+		}
+	}
+}
+
+func TestFilesRecursiveDepth(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"river.csv": "name,river\nRhein,\nMain,Rhein\nRegnitz,Main\nPegnitz,Regnitz\n",
+		"loop.csv":  "name,next\na,b\nb,a\nc,\n",
+	})
+	f, err := NewFiles([]string{dir}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := f.ContainedInKey("river", []string{"river"}, []string{"name"}); err != nil || !ok {
+		t.Errorf("river.river should be contained in the key (%v)", err)
+	}
+	if depth, cyclic, err := f.RecursiveDepth("river", []string{"river"}, []string{"name"}); err != nil || depth != 3 || cyclic {
+		t.Errorf("river: wanted depth 3 acyclic got %d %v (%v)", depth, cyclic, err)
+	}
+	if depth, cyclic, err := f.RecursiveDepth("loop", []string{"next"}, []string{"name"}); err != nil || depth != 0 || !cyclic {
+		t.Errorf("loop: wanted depth 0 cyclic got %d %v (%v)", depth, cyclic, err)
+	}
+}
+
+// TestFilesRandomRows checks a limited sample is drawn from the whole file,
+// not only its first rows.
+func TestFilesRandomRows(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"city.csv": cityCSV})
+	f, err := NewFiles([]string{filepath.Join(dir, "city.csv")}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		rows, err := f.RandomRows("city", []string{"name", "population"}, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 3 {
+			t.Fatalf("wanted 3 rows got %v", rows)
+		}
+		for _, row := range rows {
+			seen[row[0]] = true
+		}
+	}
+	if !seen["Tokyo"] {
+		t.Errorf("wanted the last row sampled too got %v", seen)
+	}
+}
@@ -0,0 +1,477 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// MySQL profiles the current database of a MySQL 8 or MariaDB 10.2+ server,
+// older servers lack the window functions and recursive CTEs used here.
+type MySQL struct {
+	DB *sql.DB
+}
+
+// NewMySQL returns a source reading db.
+func NewMySQL(db *sql.DB) *MySQL {
+	return &MySQL{DB: db}
+}
+
+func (m *MySQL) quote(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// mysqlTypes maps MySQL data types onto the information_schema names of
+// Postgres that columns are classified by.
+var mysqlTypes = map[string]string{
+	"tinyint":    "integer",
+	"smallint":   "integer",
+	"mediumint":  "integer",
+	"int":        "integer",
+	"integer":    "integer",
+	"bigint":     "integer",
+	"year":       "integer",
+	"decimal":    "numeric",
+	"numeric":    "numeric",
+	"float":      "real",
+	"double":     "double precision",
+	"date":       "date",
+	"datetime":   "timestamp without time zone",
+	"timestamp":  "timestamp with time zone",
+	"time":       "time without time zone",
+	"char":       "character",
+	"varchar":    "character varying",
+	"tinytext":   "text",
+	"text":       "text",
+	"mediumtext": "text",
+	"longtext":   "text",
+	"enum":       "USER-DEFINED",
+	"set":        "USER-DEFINED",
+	"json":       "json",
+	"binary":     "bytea",
+	"varbinary":  "bytea",
+	"blob":       "bytea",
+	"tinyblob":   "bytea",
+	"mediumblob": "bytea",
+	"longblob":   "bytea",
+	"bit":        "bit",
+}
+
+func mysqlType(native string) string {
+	if t, ok := mysqlTypes[native]; ok {
+		return t
+	}
+	return native
+}
+
+// Columns lists the columns of the tables and views in the current database.
+// MySQL prints string defaults without quotes and MariaDB with them.
+func (m *MySQL) Columns() ([]Column, error) {
+	q := `select c.table_name, c.column_name, c.data_type, c.is_nullable = 'NO', coalesce(c.column_default, ''), c.column_comment
+	from information_schema.columns c
+	join information_schema.tables t on t.table_schema = c.table_schema and t.table_name = c.table_name
+	where c.table_schema = database()
+	order by c.table_name, c.ordinal_position`
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := []Column{}
+	for rows.Next() {
+		c := Column{}
+		if err := rows.Scan(&c.Entity, &c.Name, &c.Native, &c.NotNull, &c.Default, &c.Comment); err != nil {
+			return nil, err
+		}
+		c.Native = strings.ToLower(c.Native)
+		c.DataType = mysqlType(c.Native)
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+func (m *MySQL) Entities() ([]Entity, error) {
+	q := `select table_name, case table_type when 'VIEW' then 'view' else 'table' end, table_comment
+	from information_schema.tables
+	where table_schema = database()
+	order by table_name`
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanEntities(rows)
+}
+
+// Checks reads information_schema.check_constraints, which MySQL has from
+// 8.0.16 and MariaDB from 10.2. Neither says which columns a check reads.
+func (m *MySQL) Checks() ([]Check, error) {
+	q := `select cc.constraint_name, tc.table_name, concat('CHECK (', cc.check_clause, ')'), ''
+	from information_schema.check_constraints cc
+	join information_schema.table_constraints tc
+	on tc.constraint_schema = cc.constraint_schema and tc.constraint_name = cc.constraint_name and tc.constraint_type = 'CHECK'
+	where cc.constraint_schema = database()
+	order by tc.table_name, cc.constraint_name`
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanChecks(rows)
+}
+
+// Lineage is empty, MariaDB keeps no record of the tables a view reads and
+// MySQL only does from 8.0.13.
+func (m *MySQL) Lineage() (map[string][]string, error) {
+	return map[string][]string{}, nil
+}
+
+// Partitions reads information_schema.partitions. A partitioned MySQL table
+// is already a single table, the partitions only add the key and bounds.
+// Keys on expressions such as year(logdate) leave Key empty.
+//
+// example sql
+// select table_name, partition_method, partition_expression, partition_name, partition_description
+// from information_schema.partitions where table_schema = database() and partition_name is not null;
+func (m *MySQL) Partitions() (map[string]Partitioning, error) {
+	q := `select table_name, partition_method, coalesce(partition_expression, ''), partition_name, coalesce(partition_description, '')
+	from information_schema.partitions
+	where table_schema = database() and partition_name is not null
+	order by table_name, partition_ordinal_position`
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	partitionings := map[string]Partitioning{}
+	for rows.Next() {
+		var entity, method, expr, name, desc string
+		if err := rows.Scan(&entity, &method, &expr, &name, &desc); err != nil {
+			return nil, err
+		}
+		pt := partitionings[entity]
+		if pt.Strategy == "" {
+			// RANGE COLUMNS, LINEAR HASH and so on
+			fields := strings.Fields(strings.ToLower(method))
+			pt.Strategy = fields[len(fields)-1]
+			if pt.Strategy == "columns" {
+				pt.Strategy = fields[0]
+			}
+			pt.Key = mysqlKeyColumns(expr)
+		}
+		if n := len(pt.Partitions); n > 0 && pt.Partitions[n-1].Name == name {
+			// one row per subpartition
+			partitionings[entity] = pt
+			continue
+		}
+		bound := ""
+		switch {
+		case desc == "":
+		case pt.Strategy == "range":
+			bound = "VALUES LESS THAN (" + desc + ")"
+		default:
+			bound = "VALUES IN (" + desc + ")"
+		}
+		pt.Partitions = append(pt.Partitions, Partition{Name: name, Bound: bound})
+		partitionings[entity] = pt
+	}
+	return partitionings, rows.Err()
+}
+
+// mysqlKeyColumns returns the columns of a partition expression made only
+// of quoted column names, such as `region`,`logdate`.
+func mysqlKeyColumns(expr string) []string {
+	cols := []string{}
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) < 2 || !strings.HasPrefix(part, "`") || !strings.HasSuffix(part, "`") || strings.Count(part, "`") != 2 {
+			return nil
+		}
+		cols = append(cols, part[1:len(part)-1])
+	}
+	return cols
+}
+
+// Types is empty, a MySQL enum or set is declared on its column and has no
+// name to describe it by.
+func (m *MySQL) Types() (map[string]Type, error) {
+	return map[string]Type{}, nil
+}
+
+func (m *MySQL) PrimaryKeys() (map[string][]string, error) {
+	q := `select table_name, column_name from information_schema.key_column_usage
+	where table_schema = database() and constraint_name = 'PRIMARY'
+	order by table_name, ordinal_position`
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanKeys(rows)
+}
+
+func (m *MySQL) ForeignKeys() ([]ForeignKey, error) {
+	q := `select constraint_name, table_name, column_name, referenced_table_name, referenced_column_name
+	from information_schema.key_column_usage
+	where table_schema = database() and referenced_table_name is not null
+	order by table_name, constraint_name, ordinal_position`
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanForeignKeys(rows)
+}
+
+// UniqueKeys reads the unique indexes, UNIQUE constraints are kept as
+// those too.
+func (m *MySQL) UniqueKeys() (map[string][][]string, error) {
+	q := `select table_name, index_name, column_name from information_schema.statistics
+	where table_schema = database() and non_unique = 0 and index_name <> 'PRIMARY' and column_name is not null
+	order by table_name, index_name, seq_in_index`
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanUniqueKeys(rows)
+}
+
+func (m *MySQL) IsUnique(entity string, cols []string) (bool, error) {
+	return isUnique(m.DB, m.quote, entity, cols)
+}
+
+func (m *MySQL) NumDistinct(entity string, col string) (int, error) {
+	var count int
+	q := fmt.Sprintf("select count(distinct %s) from %s", m.quote(col), m.quote(entity))
+	err := m.DB.QueryRow(q).Scan(&count)
+	return count, err
+}
+
+func (m *MySQL) MaxDistinctPer(entity string, col string, other string) (int, error) {
+	q := fmt.Sprintf("select max(output) from (select %s, count(distinct %s) as output from %s group by %s) as Derived",
+		m.quote(col), m.quote(other), m.quote(entity), m.quote(col))
+	var n sql.NullInt64
+	err := m.DB.QueryRow(q).Scan(&n)
+	return int(n.Int64), err
+}
+
+// Histogram computes the width buckets with floor as MySQL has no
+// width_bucket, adding 0e0 turns integers and decimals into doubles.
+//
+// example sql
+// select least(floor((population - 0) / 1e8) + 1, 10) as b, count(*) from city where population is not null group by b order by b;
+func (m *MySQL) Histogram(entity string, col string, n int, depth bool) ([]Bucket, error) {
+	col, entity = m.quote(col), m.quote(entity)
+	if depth {
+		q := fmt.Sprintf("select min(%s) + 0e0, max(%s) + 0e0, count(*) from (select %s, ntile(%d) over (order by %s) as b from %s where %s is not null) as Derived group by b order by b",
+			col, col, col, n, col, entity, col)
+		rows, err := m.DB.Query(q)
+		if err != nil {
+			return nil, err
+		}
+		return scanBuckets(rows)
+	}
+
+	var lo, hi *float64
+	q := fmt.Sprintf("select min(%s) + 0e0, max(%s) + 0e0 from %s", col, col, entity)
+	if err := m.DB.QueryRow(q).Scan(&lo, &hi); err != nil {
+		return nil, err
+	}
+	if lo == nil || hi == nil {
+		return nil, nil
+	}
+	q = fmt.Sprintf("select least(floor((%s - %v) / %v) + 1, %d) as b, count(*) from %s where %s is not null group by b order by b",
+		col, *lo, (*hi-*lo)/float64(n), n, entity, col)
+	if *lo == *hi {
+		n = 1
+		q = fmt.Sprintf("select 1, count(%s) from %s", col, entity)
+	}
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := scanCounts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return WidthBuckets(*lo, *hi, n, counts), nil
+}
+
+func (m *MySQL) TopValues(entity string, col string, k int) ([]Frequency, error) {
+	col, entity = m.quote(col), m.quote(entity)
+	q := fmt.Sprintf("select cast(%s as char), count(*) from %s where %s is not null group by %s order by 2 desc, 1 limit %d",
+		col, entity, col, col, k)
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanFrequencies(rows)
+}
+
+// percentile interpolates between the ranked values i of r as
+// percentile_cont does, n is the number of values.
+func percentile(p float64) string {
+	pos := fmt.Sprintf("%v * (n - 1)", p)
+	return fmt.Sprintf("sum(case when i = floor(%[1]s) then x * (1 - (%[1]s - floor(%[1]s))) when i = floor(%[1]s) + 1 then x * (%[1]s - floor(%[1]s)) else 0 end)", pos)
+}
+
+// Moments ranks the values to find the quartiles, MySQL has no
+// percentile_cont.
+func (m *MySQL) Moments(entity string, col string) (Moments, bool, error) {
+	q := fmt.Sprintf(`with v as (select %[1]s + 0e0 as x from %[2]s where %[1]s is not null),
+	s as (select avg(x) as m, stddev_pop(x) as sd, min(x) as lo, max(x) as hi, count(*) as n from v),
+	r as (select x, row_number() over (order by x) - 1 as i from v),
+	q as (select %[3]s as q1, %[4]s as q3 from r, s)
+	select s.m, s.sd, q.q1, q.q3, s.lo, s.hi,
+		avg(power(v.x - s.m, 3)),
+		avg(power(v.x - s.m, 4)),
+		sum(case when v.x < q.q1 - 1.5 * (q.q3 - q.q1) or v.x > q.q3 + 1.5 * (q.q3 - q.q1) then 1 else 0 end)
+	from v, s, q
+	group by s.m, s.sd, q.q1, q.q3, s.lo, s.hi`, m.quote(col), m.quote(entity), percentile(0.25), percentile(0.75))
+
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return Moments{}, false, err
+	}
+	defer rows.Close()
+	mo := Moments{}
+	if !rows.Next() {
+		return mo, false, rows.Err()
+	}
+	err = rows.Scan(&mo.Mean, &mo.StdDev, &mo.Q1, &mo.Q3, &mo.Min, &mo.Max, &mo.M3, &mo.M4, &mo.Outliers)
+	return mo, err == nil, err
+}
+
+// Correlation computes Pearson from its definition as MySQL has no corr,
+// a zero standard deviation divides by zero and gives NULL.
+func (m *MySQL) Correlation(entity string, col1 string, col2 string, sample float64) (sql.NullFloat64, sql.NullFloat64, error) {
+	where := ""
+	if sample > 0 && sample < 100 {
+		where = fmt.Sprintf(" and rand() < %v", sample/100)
+	}
+	q := fmt.Sprintf(`select (avg(x * y) - avg(x) * avg(y)) / (stddev_pop(x) * stddev_pop(y)),
+		(avg(r1 * r2) - avg(r1) * avg(r2)) / (stddev_pop(r1) * stddev_pop(r2))
+	from (select %[1]s + 0e0 as x, %[2]s + 0e0 as y,
+		rank() over (order by %[1]s) + (count(*) over (partition by %[1]s) - 1) / 2.0 as r1,
+		rank() over (order by %[2]s) + (count(*) over (partition by %[2]s) - 1) / 2.0 as r2
+	from (select %[1]s, %[2]s from %[3]s where %[1]s is not null and %[2]s is not null%[4]s) as s) as Derived`,
+		m.quote(col1), m.quote(col2), m.quote(entity), where)
+
+	var pearson, spearman sql.NullFloat64
+	err := m.DB.QueryRow(q).Scan(&pearson, &spearman)
+	return pearson, spearman, err
+}
+
+func (m *MySQL) SampleRows(entity string, cols []string, limit int) ([][]string, error) {
+	return m.readRows(entity, cols, limit, "")
+}
+
+func (m *MySQL) RandomRows(entity string, cols []string, limit int) ([][]string, error) {
+	return m.readRows(entity, cols, limit, "rand()")
+}
+
+// readRows reads up to limit rows of the columns as text in the given order.
+func (m *MySQL) readRows(entity string, cols []string, limit int, order string) ([][]string, error) {
+	exprs := make([]string, len(cols))
+	for i, c := range cols {
+		exprs[i] = "cast(" + m.quote(c) + " as char)"
+	}
+	q := fmt.Sprintf("select %s from %s", strings.Join(exprs, ", "), m.quote(entity))
+	if order != "" {
+		q += " order by " + order
+	}
+	if limit > 0 {
+		q += fmt.Sprintf(" limit %d", limit)
+	}
+	rows, err := m.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(rows, len(cols))
+}
+
+func (m *MySQL) ContainedInKey(entity string, cols []string, keyCols []string) (bool, error) {
+	return containedInKey(m.DB, m.quote, entity, cols, keyCols)
+}
+
+// RecursiveDepth keeps the path as a delimited string since MySQL has no
+// arrays. Chains longer than cte_max_recursion_depth, 1000 by default, fail.
+func (m *MySQL) RecursiveDepth(entity string, cols []string, keyCols []string) (int, bool, error) {
+	qualify := func(alias string, cols []string) string {
+		qualified := make([]string, len(cols))
+		for i, c := range cols {
+			qualified[i] = alias + "." + m.quote(c)
+		}
+		return "concat_ws(char(31 using utf8mb4), " + strings.Join(qualified, ", ") + ")"
+	}
+	q := fmt.Sprintf(`with recursive walk(k, depth, path) as (
+		select %[2]s, 0, cast(concat(char(30 using utf8mb4), %[2]s, char(30 using utf8mb4)) as char(10000)) from %[1]s as c
+		where not exists (select 1 from %[1]s as p where %[3]s = %[4]s)
+		union all
+		select %[2]s, w.depth + 1, concat(w.path, %[2]s, char(30 using utf8mb4)) from %[1]s as c
+		join walk as w on %[4]s = w.k
+		where locate(concat(char(30 using utf8mb4), %[2]s, char(30 using utf8mb4)), w.path) = 0)
+	select coalesce(max(depth), 0), count(distinct k), (select count(*) from %[1]s) from walk`,
+		m.quote(entity), qualify("c", keyCols), qualify("p", keyCols), qualify("c", cols))
+
+	var depth, reached, total int
+	if err := m.DB.QueryRow(q).Scan(&depth, &reached, &total); err != nil {
+		return 0, false, err
+	}
+	return depth, reached < total, nil
+}
+
+// Fingerprints are made of a hash of the columns of a table and either its
+// update time and estimated row count or CHECKSUM TABLE. The update time is
+// only kept in memory by InnoDB, so the estimate is a weak signal and
+// checksums are the safer choice on MySQL.
+func (m *MySQL) Fingerprints(checksum bool) (map[string]string, error) {
+	ctx := context.Background()
+	// session settings need a single connection
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	for _, set := range []string{"set session group_concat_max_len = 1000000", "set session information_schema_stats_expiry = 0"} {
+		if _, err := conn.ExecContext(ctx, set); err != nil && !strings.Contains(set, "expiry") {
+			// MariaDB has no stats expiry, it never caches them
+			return nil, err
+		}
+	}
+	q := `select t.table_name,
+		md5(group_concat(c.column_name, ' ', c.column_type order by c.ordinal_position separator ',')),
+		coalesce(cast(t.update_time as char), ''), coalesce(t.table_rows, 0)
+	from information_schema.tables t
+	join information_schema.columns c on c.table_schema = t.table_schema and c.table_name = t.table_name
+	where t.table_schema = database() and t.table_type = 'BASE TABLE'
+	group by t.table_name, t.update_time, t.table_rows`
+	rows, err := conn.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	fingerprints := map[string]string{}
+	columns := map[string]string{}
+	for rows.Next() {
+		var table, cols, updated string
+		var count int64
+		if err := rows.Scan(&table, &cols, &updated, &count); err != nil {
+			return nil, err
+		}
+		columns[table] = cols
+		fingerprints[table] = fmt.Sprintf("%s/%s/%d", cols, updated, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !checksum {
+		return fingerprints, nil
+	}
+	for table, cols := range columns {
+		var name string
+		var sum sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "checksum table "+m.quote(table)).Scan(&name, &sum); err != nil {
+			return nil, err
+		}
+		fingerprints[table] = fmt.Sprintf("%s/%d", cols, sum.Int64)
+	}
+	return fingerprints, nil
+}
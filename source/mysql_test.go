@@ -0,0 +1,157 @@
+package source
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// openMySQL builds the river network of openSQLite in the database named by
+// VIS_TEST_MYSQL_DSN, such as user:password@tcp(localhost:3306)/scratch,
+// and skips the test when it is not set.
+func openMySQL(t *testing.T) *MySQL {
+	dsn := os.Getenv("VIS_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("VIS_TEST_MYSQL_DSN is not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop := func() {
+		for _, table := range []string{"vis_city", "vis_river", "vis_country"} {
+			if _, err := db.Exec("drop table if exists " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	drop()
+	t.Cleanup(func() {
+		drop()
+		db.Close()
+	})
+	stmts := []string{
+		`create table vis_country (code varchar(4) primary key, name text, area double)`,
+		`create table vis_city (name varchar(40), country varchar(4), population int, primary key (country, name),
+			constraint vis_city_country foreign key (country) references vis_country (code))`,
+		`create table vis_river (name varchar(40) primary key, river varchar(40), length double,
+			constraint vis_river_river foreign key (river) references vis_river (name))`,
+		`insert into vis_country values ('D', 'Germany', 357000), ('F', 'France', 547000), ('NL', 'Netherlands', 41500)`,
+		`insert into vis_city values ('Berlin', 'D', 3500000), ('Hamburg', 'D', 1800000), ('Paris', 'F', 2100000), ('Lyon', 'F', 500000), ('Amsterdam', 'NL', null)`,
+		`insert into vis_river values ('Rhein', null, 1233), ('Main', 'Rhein', 524), ('Regnitz', 'Main', 162), ('Pegnitz', 'Regnitz', 115)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewMySQL(db)
+}
+
+func TestMySQLType(t *testing.T) {
+	for native, want := range map[string]string{
+		"bigint":   "integer",
+		"decimal":  "numeric",
+		"datetime": "timestamp without time zone",
+		"varchar":  "character varying",
+		"geometry": "geometry",
+	} {
+		if got := mysqlType(native); got != want {
+			t.Errorf("%s: wanted %s got %s", native, want, got)
+		}
+	}
+}
+
+func TestMySQLQuote(t *testing.T) {
+	m := &MySQL{}
+	if got := m.quote("odd`name"); got != "`odd``name`" {
+		t.Errorf("wanted `odd``name` got %s", got)
+	}
+}
+
+func TestMySQLCatalog(t *testing.T) {
+	m := openMySQL(t)
+	cols, err := m.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, c := range cols {
+		if strings.HasPrefix(c.Entity, "vis_") {
+			got = append(got, fmt.Sprintf("%s.%s %s %s", c.Entity, c.Name, c.Native, c.DataType))
+		}
+	}
+	want := []string{
+		"vis_city.name varchar character varying", "vis_city.country varchar character varying", "vis_city.population int integer",
+		"vis_country.code varchar character varying", "vis_country.name text text", "vis_country.area double double precision",
+		"vis_river.name varchar character varying", "vis_river.river varchar character varying", "vis_river.length double double precision",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns: wanted %v got %v", want, got)
+	}
+
+	keys, err := m.PrimaryKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, want := range map[string][]string{"vis_city": {"country", "name"}, "vis_country": {"code"}, "vis_river": {"name"}} {
+		if !reflect.DeepEqual(keys[table], want) {
+			t.Errorf("%s key: wanted %v got %v", table, want, keys[table])
+		}
+	}
+
+	fks, err := m.ForeignKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotFKs := []ForeignKey{}
+	for _, fk := range fks {
+		if strings.HasPrefix(fk.Entity, "vis_") {
+			gotFKs = append(gotFKs, fk)
+		}
+	}
+	wantFKs := []ForeignKey{
+		{Name: "vis_city_country", Entity: "vis_city", Columns: []string{"country"}, RefEntity: "vis_country", RefColumns: []string{"code"}},
+		{Name: "vis_river_river", Entity: "vis_river", Columns: []string{"river"}, RefEntity: "vis_river", RefColumns: []string{"name"}},
+	}
+	if !reflect.DeepEqual(gotFKs, wantFKs) {
+		t.Errorf("foreign keys: wanted %v got %v", wantFKs, gotFKs)
+	}
+}
+
+func TestMySQLStatistics(t *testing.T) {
+	m := openMySQL(t)
+	if n, err := m.NumDistinct("vis_city", "country"); err != nil || n != 3 {
+		t.Errorf("distinct countries: wanted 3 got %d (%v)", n, err)
+	}
+	if n, err := m.MaxDistinctPer("vis_city", "country", "name"); err != nil || n != 2 {
+		t.Errorf("cities per country: wanted 2 got %d (%v)", n, err)
+	}
+
+	buckets, err := m.Histogram("vis_river", "length", 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bucket{{115, 674, 3}, {674, 1233, 1}}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("histogram: wanted %v got %v", want, buckets)
+	}
+	buckets, err = m.Histogram("vis_river", "length", 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Bucket{{115, 162, 2}, {524, 1233, 2}}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("equi-depth histogram: wanted %v got %v", want, buckets)
+	}
+
+	depth, cyclic, err := m.RecursiveDepth("vis_river", []string{"river"}, []string{"name"})
+	if err != nil || depth != 3 || cyclic {
+		t.Errorf("recursive depth: wanted 3 acyclic got %d %v (%v)", depth, cyclic, err)
+	}
+}
@@ -0,0 +1,678 @@
+package source
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Postgres profiles the public schema of a Postgres database.
+type Postgres struct {
+	DB *sql.DB
+}
+
+// NewPostgres returns a source reading db.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{DB: db}
+}
+
+// children lists the partitions and inheritance children in public, they
+// are read through their parent rather than profiled as entities.
+const children = `select c.relname from pg_inherits i
+	join pg_class c on c.oid = i.inhrelid
+	join pg_namespace n on n.oid = c.relnamespace
+	where n.nspname = 'public' and c.relkind in ('r', 'p', 'f')`
+
+func (p *Postgres) quote(name string) string {
+	return pq.QuoteIdentifier(name)
+}
+
+// Columns lists the columns of the tables and views in public. Materialized
+// views are missing from information_schema, their columns come from
+// pg_attribute with format_type standing in for data_type. Columns of a
+// domain are of the domain rather than its base type, and the declared
+// array dimensions only live in pg_attribute. Comments are the
+// COMMENT ON COLUMN descriptions in pg_description.
+//
+// example sql
+// select v.relname, a.attname, format_type(a.atttypid, null), t.typname from pg_attribute a
+// join pg_class v on v.oid = a.attrelid join pg_type t on t.oid = a.atttypid where v.relkind = 'm' and a.attnum > 0;
+func (p *Postgres) Columns() ([]Column, error) {
+	q := `select columns.table_name::text,
+		  columns.column_name::text,
+		  columns.data_type::text,
+		  coalesce(columns.domain_name, columns.udt_name)::text,
+		  columns.ordinal_position::int,
+		  coalesce((select a.attndims from pg_attribute a
+		  where a.attrelid = (quote_ident(columns.table_schema) || '.' || quote_ident(columns.table_name))::regclass
+		  and a.attname = columns.column_name), 0)::int,
+		  columns.is_nullable = 'NO',
+		  coalesce(columns.column_default::text, ''),
+		  coalesce(col_description((quote_ident(columns.table_schema) || '.' || quote_ident(columns.table_name))::regclass,
+		  columns.ordinal_position::int), '')
+	from information_schema.columns
+	join information_schema.tables on columns.table_name = tables.table_name and columns.table_schema = tables.table_schema
+	where tables.table_schema = 'public' and tables.table_type in ('BASE TABLE', 'VIEW', 'FOREIGN')
+	and columns.table_name not in (` + children + `)
+	union all
+	select v.relname::text, a.attname::text,
+		format_type(case t.typtype when 'd' then t.typbasetype else a.atttypid end, null),
+		t.typname::text, a.attnum::int, a.attndims::int,
+		a.attnotnull, '', coalesce(col_description(v.oid, a.attnum), '')
+	from pg_attribute a
+	join pg_class v on v.oid = a.attrelid
+	join pg_namespace n on n.oid = v.relnamespace
+	join pg_type t on t.oid = a.atttypid
+	where n.nspname = 'public' and v.relkind = 'm' and a.attnum > 0 and not a.attisdropped
+	order by 1, 5`
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := []Column{}
+	for rows.Next() {
+		c := Column{}
+		var pos int
+		if err := rows.Scan(&c.Entity, &c.Name, &c.DataType, &c.Native, &pos, &c.Dimensions, &c.NotNull, &c.Default, &c.Comment); err != nil {
+			return nil, err
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+// Types reads the enums, domains and composite types outside the system
+// schemas and the array types of the columns in public. An enum sorts by
+// its labels' enumsortorder, not alphabetically.
+//
+// example sql
+// select t.typname, e.enumlabel from pg_enum e join pg_type t on t.oid = e.enumtypid order by 1, e.enumsortorder;
+// select t.typname, b.typname, pg_get_constraintdef(c.oid) from pg_type t join pg_type b on b.oid = t.typbasetype
+// left join pg_constraint c on c.contypid = t.oid where t.typtype = 'd';
+func (p *Postgres) Types() (map[string]Type, error) {
+	const user = `n.nspname not in ('pg_catalog', 'information_schema') and n.nspname not like 'pg_toast%'`
+	types := map[string]Type{}
+	read := func(q string, fn func(t *Type, name string, a string, b string)) error {
+		rows, err := p.DB.Query(q)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name, a, b string
+			if err := rows.Scan(&name, &a, &b); err != nil {
+				return err
+			}
+			t := types[name]
+			fn(&t, name, a, b)
+			types[name] = t
+		}
+		return rows.Err()
+	}
+
+	err := read(`select t.typname::text, e.enumlabel::text, ''
+	from pg_enum e
+	join pg_type t on t.oid = e.enumtypid
+	join pg_namespace n on n.oid = t.typnamespace
+	where `+user+`
+	order by 1, e.enumsortorder`, func(t *Type, name string, label string, _ string) {
+		t.Kind = EnumType
+		t.Labels = append(t.Labels, label)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = read(`select t.typname::text, b.typname::text, coalesce(pg_get_constraintdef(c.oid), '')
+	from pg_type t
+	join pg_type b on b.oid = t.typbasetype
+	join pg_namespace n on n.oid = t.typnamespace
+	left join pg_constraint c on c.contypid = t.oid and c.contype = 'c'
+	where t.typtype = 'd' and `+user+`
+	order by 1, c.conname`, func(t *Type, name string, base string, check string) {
+		t.Kind = DomainType
+		t.Base = base
+		if check != "" {
+			t.Checks = append(t.Checks, check)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = read(`select t.typname::text, a.attname::text, f.typname::text
+	from pg_type t
+	join pg_class r on r.oid = t.typrelid
+	join pg_attribute a on a.attrelid = r.oid
+	join pg_type f on f.oid = a.atttypid
+	join pg_namespace n on n.oid = t.typnamespace
+	where t.typtype = 'c' and r.relkind = 'c' and a.attnum > 0 and not a.attisdropped and `+user+`
+	order by 1, a.attnum`, func(t *Type, name string, field string, native string) {
+		t.Kind = CompositeType
+		t.Fields = append(t.Fields, Field{Name: field, Native: native})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// arrays of the columns, domains over arrays and composite fields
+	err = read(`select distinct t.typname::text, e.typname::text, ''
+	from pg_type t
+	join pg_type e on e.oid = t.typelem
+	where t.typcategory = 'A' and t.oid in (
+		select a.atttypid from pg_attribute a
+		join pg_class c on c.oid = a.attrelid
+		join pg_namespace n on n.oid = c.relnamespace
+		where a.attnum > 0 and not a.attisdropped and (n.nspname = 'public' or c.relkind = 'c')
+		union select typbasetype from pg_type where typtype = 'd')
+	order by 1`, func(t *Type, name string, elem string, _ string) {
+		t.Kind = ArrayType
+		t.Base = elem
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+func (p *Postgres) Entities() ([]Entity, error) {
+	q := `select c.relname::text,
+		case c.relkind when 'v' then 'view' when 'm' then 'materializedView' else 'table' end,
+		coalesce(obj_description(c.oid, 'pg_class'), '')
+	from pg_class c
+	join pg_namespace n on n.oid = c.relnamespace
+	where n.nspname = 'public' and c.relkind in ('r', 'p', 'f', 'v', 'm')
+	and c.relname not in (` + children + `)
+	order by 1`
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanEntities(rows)
+}
+
+// Lineage follows the pg_depend entries of the rewrite rule behind each
+// view and materialized view to the relations it reads.
+//
+// example sql
+// select distinct v.relname, t.relname from pg_depend d join pg_rewrite r on r.oid = d.objid
+// join pg_class v on v.oid = r.ev_class join pg_class t on t.oid = d.refobjid
+// where d.classid = 'pg_rewrite'::regclass and d.refclassid = 'pg_class'::regclass and v.oid <> t.oid;
+func (p *Postgres) Lineage() (map[string][]string, error) {
+	q := `select distinct v.relname::text, t.relname::text
+	from pg_depend d
+	join pg_rewrite r on r.oid = d.objid
+	join pg_class v on v.oid = r.ev_class
+	join pg_class t on t.oid = d.refobjid
+	join pg_namespace n on n.oid = v.relnamespace
+	join pg_namespace tn on tn.oid = t.relnamespace
+	where d.classid = 'pg_rewrite'::regclass and d.refclassid = 'pg_class'::regclass
+	and v.oid <> t.oid and v.relkind in ('v', 'm')
+	and n.nspname = 'public' and tn.nspname = 'public'
+	order by 1, 2`
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanKeys(rows)
+}
+
+// Partitions reads the strategy and key of declaratively partitioned tables
+// from pg_partitioned_table and the children of every parent, with their
+// bounds, from pg_inherits. Only tables that are not children themselves
+// are entities, sub-partitions are listed under the entity at the top with
+// the bound they have within their own parent.
+//
+// example sql
+// select p.relname, c.relname, pg_get_expr(c.relpartbound, c.oid) from pg_inherits i
+// join pg_class p on p.oid = i.inhparent join pg_class c on c.oid = i.inhrelid;
+func (p *Postgres) Partitions() (map[string]Partitioning, error) {
+	q := `select p.relname::text,
+		case pt.partstrat when 'r' then 'range' when 'l' then 'list' when 'h' then 'hash' end,
+		a.attname::text
+	from pg_partitioned_table pt
+	join pg_class p on p.oid = pt.partrelid
+	join pg_namespace n on n.oid = p.relnamespace
+	cross join lateral unnest(pt.partattrs) with ordinality as k(attnum, pos)
+	left join pg_attribute a on a.attrelid = p.oid and a.attnum = k.attnum
+	where n.nspname = 'public' and not exists (select 1 from pg_inherits i where i.inhrelid = p.oid)
+	order by 1, k.pos`
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	partitionings := map[string]Partitioning{}
+	for rows.Next() {
+		var entity, strategy string
+		var col sql.NullString
+		if err := rows.Scan(&entity, &strategy, &col); err != nil {
+			return nil, err
+		}
+		pt := partitionings[entity]
+		pt.Strategy = strategy
+		if col.Valid {
+			// expressions have no attribute
+			pt.Key = append(pt.Key, col.String)
+		}
+		partitionings[entity] = pt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	q = `with recursive child(root, relid) as (
+		select i.inhparent, i.inhrelid from pg_inherits i
+		where not exists (select 1 from pg_inherits g where g.inhrelid = i.inhparent)
+		union all
+		select child.root, i.inhrelid from child join pg_inherits i on i.inhparent = child.relid)
+	select p.relname::text, c.relname::text, coalesce(pg_get_expr(c.relpartbound, c.oid), '')
+	from child
+	join pg_class p on p.oid = child.root
+	join pg_class c on c.oid = child.relid
+	join pg_namespace n on n.oid = p.relnamespace
+	where n.nspname = 'public' and p.relkind in ('r', 'p') and c.relkind in ('r', 'p', 'f')
+	order by 1, 2`
+	rows, err = p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entity string
+		part := Partition{}
+		if err := rows.Scan(&entity, &part.Name, &part.Bound); err != nil {
+			return nil, err
+		}
+		pt := partitionings[entity]
+		if pt.Strategy == "" {
+			pt.Strategy = "inheritance"
+		}
+		pt.Partitions = append(pt.Partitions, part)
+		partitionings[entity] = pt
+	}
+	return partitionings, rows.Err()
+}
+
+func (p *Postgres) PrimaryKeys() (map[string][]string, error) {
+	q := `select tc.table_name, kc.column_name
+	from information_schema.table_constraints tc
+	join information_schema.key_column_usage kc
+	on kc.table_name = tc.table_name and kc.table_schema = tc.table_schema and kc.constraint_name = tc.constraint_name
+	where tc.constraint_type = 'PRIMARY KEY'
+	and tc.table_schema = 'public'
+	and kc.ordinal_position is not null
+	and tc.table_name not in (` + children + `)
+	order by tc.table_name, kc.ordinal_position`
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanKeys(rows)
+}
+
+func (p *Postgres) ForeignKeys() ([]ForeignKey, error) {
+	q := `select rc.constraint_name, kcu.table_name, kcu.column_name, ccu.table_name, ccu.column_name
+	from information_schema.referential_constraints rc
+	join information_schema.key_column_usage kcu
+	on kcu.constraint_schema = rc.constraint_schema and kcu.constraint_name = rc.constraint_name
+	join information_schema.key_column_usage ccu
+	on ccu.constraint_schema = rc.unique_constraint_schema and ccu.constraint_name = rc.unique_constraint_name
+	and ccu.ordinal_position = kcu.position_in_unique_constraint
+	where kcu.table_schema = 'public'
+	and kcu.table_name not in (` + children + `)
+	order by kcu.table_name, rc.constraint_name, kcu.ordinal_position`
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanForeignKeys(rows)
+}
+
+// Checks reads the CHECK constraints in pg_constraint, conkey lists the
+// columns a constraint reads.
+//
+// example sql
+// select c.conname, t.relname, pg_get_constraintdef(c.oid), a.attname from pg_constraint c
+// join pg_class t on t.oid = c.conrelid left join pg_attribute a on a.attrelid = t.oid and a.attnum = any(c.conkey)
+// where c.contype = 'c';
+func (p *Postgres) Checks() ([]Check, error) {
+	q := `select c.conname::text, t.relname::text, pg_get_constraintdef(c.oid), coalesce(a.attname::text, '')
+	from pg_constraint c
+	join pg_class t on t.oid = c.conrelid
+	join pg_namespace n on n.oid = t.relnamespace
+	left join lateral unnest(c.conkey) with ordinality as k(attnum, pos) on true
+	left join pg_attribute a on a.attrelid = t.oid and a.attnum = k.attnum
+	where c.contype = 'c' and n.nspname = 'public'
+	and t.relname not in (` + children + `)
+	order by 2, 1, k.pos`
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanChecks(rows)
+}
+
+// UniqueKeys reads the unique indexes, which back UNIQUE constraints too.
+// Partial and expression indexes do not make a key and are left out.
+func (p *Postgres) UniqueKeys() (map[string][][]string, error) {
+	q := `select t.relname, i.relname, a.attname
+	from pg_index x
+	join pg_class t on t.oid = x.indrelid
+	join pg_class i on i.oid = x.indexrelid
+	join pg_namespace n on n.oid = t.relnamespace
+	cross join lateral unnest(x.indkey) with ordinality as k(attnum, pos)
+	join pg_attribute a on a.attrelid = t.oid and a.attnum = k.attnum
+	where n.nspname = 'public' and x.indisunique and not x.indisprimary
+	and x.indpred is null and x.indexprs is null
+	and t.relname not in (` + children + `)
+	order by t.relname, i.relname, k.pos`
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanUniqueKeys(rows)
+}
+
+func (p *Postgres) IsUnique(entity string, cols []string) (bool, error) {
+	return isUnique(p.DB, p.quote, entity, cols)
+}
+
+// example sql
+// select count(distinct population) from city;
+func (p *Postgres) NumDistinct(entity string, col string) (int, error) {
+	var count int
+	q := fmt.Sprintf("SELECT COUNT (DISTINCT %s) FROM %s", p.quote(col), p.quote(entity))
+	err := p.DB.QueryRow(q).Scan(&count)
+	return count, err
+}
+
+// example sql
+// select max(output) from (select iata_code, count(distinct city) as output from airport group by iata_code) as Derived;
+func (p *Postgres) MaxDistinctPer(entity string, col string, other string) (int, error) {
+	q := fmt.Sprintf("select max(output) from (select %s, count(distinct %s) as output from %s group by %s) as Derived",
+		p.quote(col), p.quote(other), p.quote(entity), p.quote(col))
+	var n sql.NullInt64
+	err := p.DB.QueryRow(q).Scan(&n)
+	return int(n.Int64), err
+}
+
+// example sql
+// select least(width_bucket(population::float8, 0, 1e9, 10), 10) as b, count(*) from city where population is not null group by b order by b;
+// select min(population), max(population), count(*) from (select population, ntile(10) over (order by population) as b from city where population is not null) as Derived group by b order by b;
+func (p *Postgres) Histogram(entity string, col string, n int, depth bool) ([]Bucket, error) {
+	col, entity = p.quote(col), p.quote(entity)
+	if depth {
+		q := fmt.Sprintf("select min(%s)::float8, max(%s)::float8, count(*) from (select %s, ntile(%d) over (order by %s) as b from %s where %s is not null) as Derived group by b order by b",
+			col, col, col, n, col, entity, col)
+		rows, err := p.DB.Query(q)
+		if err != nil {
+			return nil, err
+		}
+		return scanBuckets(rows)
+	}
+
+	var lo, hi *float64
+	q := fmt.Sprintf("select min(%s)::float8, max(%s)::float8 from %s", col, col, entity)
+	if err := p.DB.QueryRow(q).Scan(&lo, &hi); err != nil {
+		return nil, err
+	}
+	if lo == nil || hi == nil {
+		// no values at all
+		return nil, nil
+	}
+	if *lo == *hi {
+		n = 1
+	}
+	// width_bucket puts the maximum value in bucket n+1 so fold it back into the last one
+	q = fmt.Sprintf("select least(width_bucket(%s::float8, %v, %v, %d), %d) as b, count(*) from %s where %s is not null group by b order by b",
+		col, *lo, *hi, n, n, entity, col)
+	if *lo == *hi {
+		q = fmt.Sprintf("select 1, count(%s) from %s", col, entity)
+	}
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := scanCounts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return WidthBuckets(*lo, *hi, n, counts), nil
+}
+
+// example sql
+// select country::text, count(*) from city where country is not null group by country order by 2 desc, 1 limit 5;
+func (p *Postgres) TopValues(entity string, col string, k int) ([]Frequency, error) {
+	col, entity = p.quote(col), p.quote(entity)
+	q := fmt.Sprintf("select %s::text, count(*) from %s where %s is not null group by %s order by 2 desc, 1 limit %d",
+		col, entity, col, col, k)
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanFrequencies(rows)
+}
+
+// example sql
+// with s as (select avg(population::float8) as m, stddev_pop(population::float8) as sd, ... from city)
+// select s.m, s.sd, ..., avg(power(v.population::float8 - s.m, 3)), ... from city as v, s where v.population is not null group by ...;
+func (p *Postgres) Moments(entity string, col string) (Moments, bool, error) {
+	q := fmt.Sprintf(`with s as (select avg(%[1]s::float8) as m,
+		stddev_pop(%[1]s::float8) as sd,
+		percentile_cont(0.25) within group (order by %[1]s::float8) as q1,
+		percentile_cont(0.75) within group (order by %[1]s::float8) as q3,
+		min(%[1]s::float8) as lo,
+		max(%[1]s::float8) as hi
+	from %[2]s)
+	select s.m, s.sd, s.q1, s.q3, s.lo, s.hi,
+		avg(power(v.%[1]s::float8 - s.m, 3)),
+		avg(power(v.%[1]s::float8 - s.m, 4)),
+		count(*) filter (where v.%[1]s < s.q1 - 1.5 * (s.q3 - s.q1) or v.%[1]s > s.q3 + 1.5 * (s.q3 - s.q1))
+	from %[2]s as v, s
+	where v.%[1]s is not null
+	group by s.m, s.sd, s.q1, s.q3, s.lo, s.hi`, p.quote(col), p.quote(entity))
+
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return Moments{}, false, err
+	}
+	defer rows.Close()
+	m := Moments{}
+	if !rows.Next() {
+		// empty column
+		return m, false, rows.Err()
+	}
+	err = rows.Scan(&m.Mean, &m.StdDev, &m.Q1, &m.Q3, &m.Min, &m.Max, &m.M3, &m.M4, &m.Outliers)
+	return m, err == nil, err
+}
+
+// Spearman is Pearson over the ranks, ties get the average of their ranks.
+//
+// example sql
+// select corr(area::float8, population::float8), corr(r1::float8, r2::float8) from (select area, population,
+// rank() over (order by area) + (count(*) over (partition by area) - 1) / 2.0 as r1, ...
+// from country where area is not null and population is not null) as Derived;
+func (p *Postgres) Correlation(entity string, col1 string, col2 string, sample float64) (sql.NullFloat64, sql.NullFloat64, error) {
+	from, where := p.quote(entity), ""
+	if sample > 0 && sample < 100 {
+		// tablesample is only allowed on tables and materialized views
+		var samplable bool
+		err := p.DB.QueryRow(`select c.relkind in ('r', 'p', 'm') from pg_class c
+		join pg_namespace n on n.oid = c.relnamespace
+		where n.nspname = 'public' and c.relname = $1`, entity).Scan(&samplable)
+		if err != nil {
+			return sql.NullFloat64{}, sql.NullFloat64{}, err
+		}
+		if samplable {
+			from = fmt.Sprintf("%s tablesample bernoulli (%v)", from, sample)
+		} else {
+			where = fmt.Sprintf(" and random() < %v", sample/100)
+		}
+	}
+	q := fmt.Sprintf(`select corr(%[1]s::float8, %[2]s::float8), corr(r1::float8, r2::float8) from (select %[1]s, %[2]s,
+		rank() over (order by %[1]s) + (count(*) over (partition by %[1]s) - 1) / 2.0 as r1,
+		rank() over (order by %[2]s) + (count(*) over (partition by %[2]s) - 1) / 2.0 as r2
+	from %[3]s where %[1]s is not null and %[2]s is not null%[4]s) as Derived`, p.quote(col1), p.quote(col2), from, where)
+
+	var pearson, spearman sql.NullFloat64
+	err := p.DB.QueryRow(q).Scan(&pearson, &spearman)
+	return pearson, spearman, err
+}
+
+func (p *Postgres) SampleRows(entity string, cols []string, limit int) ([][]string, error) {
+	return p.readRows(p.quote(entity), cols, limit, "")
+}
+
+// RandomRows samples tables and materialized views with tablesample at about
+// twice the share of rows wanted by the planner's estimate, so it reads a
+// fraction of a large table instead of sorting all of it, and sorts the rows
+// of anything else at random.
+func (p *Postgres) RandomRows(entity string, cols []string, limit int) ([][]string, error) {
+	if limit <= 0 {
+		return p.readRows(p.quote(entity), cols, limit, "")
+	}
+	var samplable bool
+	var estimate float64
+	err := p.DB.QueryRow(`select c.relkind in ('r', 'p', 'm'), c.reltuples from pg_class c
+	join pg_namespace n on n.oid = c.relnamespace
+	where n.nspname = 'public' and c.relname = $1`, entity).Scan(&samplable, &estimate)
+	if err != nil {
+		return nil, err
+	}
+	if samplable && estimate > float64(2*limit) {
+		from := fmt.Sprintf("%s tablesample bernoulli (%v)", p.quote(entity), 200*float64(limit)/estimate)
+		return p.readRows(from, cols, limit, "")
+	}
+	return p.readRows(p.quote(entity), cols, limit, "random()")
+}
+
+// readRows reads up to limit rows of the columns as text in the given order.
+func (p *Postgres) readRows(from string, cols []string, limit int, order string) ([][]string, error) {
+	exprs := make([]string, len(cols))
+	for i, c := range cols {
+		exprs[i] = p.quote(c) + "::text"
+	}
+	q := fmt.Sprintf("select %s from %s", strings.Join(exprs, ", "), from)
+	if order != "" {
+		q += " order by " + order
+	}
+	if limit > 0 {
+		q += fmt.Sprintf(" limit %d", limit)
+	}
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(rows, len(cols))
+}
+
+func (p *Postgres) ContainedInKey(entity string, cols []string, keyCols []string) (bool, error) {
+	return containedInKey(p.DB, p.quote, entity, cols, keyCols)
+}
+
+// RecursiveDepth walks down from the rows without a parent with a recursive
+// CTE. Depth counts references followed, so a flat table has depth 0. Each
+// row has at most one parent, so rows never reached from a root sit on a
+// cycle and make the structure a graph rather than a tree.
+//
+// example sql
+// with recursive walk(k, depth, path) as (
+// select row(name)::text, 0, array[row(name)::text] from river as c where not exists (select 1 from river as p where row(p.name)::text = row(c.river)::text)
+// union all
+// select row(c.name)::text, w.depth + 1, w.path || row(c.name)::text from river as c join walk as w on row(c.river)::text = w.k where not row(c.name)::text = any(w.path))
+// select coalesce(max(depth), 0), count(distinct k), (select count(*) from river) from walk;
+func (p *Postgres) RecursiveDepth(entity string, cols []string, keyCols []string) (int, bool, error) {
+	qualify := func(alias string, cols []string) string {
+		qualified := make([]string, len(cols))
+		for i, c := range cols {
+			qualified[i] = alias + "." + p.quote(c)
+		}
+		return "row(" + strings.Join(qualified, ", ") + ")::text"
+	}
+	q := fmt.Sprintf(`with recursive walk(k, depth, path) as (
+		select %[2]s, 0, array[%[2]s] from %[1]s as c
+		where not exists (select 1 from %[1]s as p where %[3]s = %[4]s)
+		union all
+		select %[2]s, w.depth + 1, w.path || %[2]s from %[1]s as c
+		join walk as w on %[4]s = w.k
+		where not %[2]s = any(w.path))
+	select coalesce(max(depth), 0), count(distinct k), (select count(*) from %[1]s) from walk`,
+		p.quote(entity), qualify("c", keyCols), qualify("p", keyCols), qualify("c", cols))
+
+	var depth, reached, total int
+	if err := p.DB.QueryRow(q).Scan(&depth, &reached, &total); err != nil {
+		return 0, false, err
+	}
+	return depth, reached < total, nil
+}
+
+// Fingerprints are made of a hash of the columns of a table and either its
+// pg_stat_user_tables write counters or a checksum of every row. The
+// counters are cheap but only approximate, they lag the writes a little and
+// a reset of the statistics changes them all. The counters of a parent add
+// up those of its partitions and children, where its rows are written.
+// Views have no fingerprint.
+//
+// example sql
+// with recursive tree(root, relid) as (select oid, oid from pg_class union all
+// select t.root, i.inhrelid from tree t join pg_inherits i on i.inhparent = t.relid)
+// select r.relname, md5(string_agg(c.column_name || ' ' || c.udt_name, ',' order by c.ordinal_position)),
+// w.ins, w.upd, w.del from (select t.root, sum(s.n_tup_ins) as ins, ... from tree t join pg_stat_user_tables s
+// on s.relid = t.relid group by t.root) as w join pg_class r on r.oid = w.root join information_schema.columns c
+// on c.table_schema = 'public' and c.table_name = r.relname group by 1, 3, 4, 5;
+// select md5(coalesce(string_agg(md5(t::text), ',' order by md5(t::text)), 'empty')) from city as t;
+func (p *Postgres) Fingerprints(checksum bool) (map[string]string, error) {
+	q := `with recursive tree(root, relid) as (
+		select c.oid, c.oid from pg_class c
+		join pg_namespace n on n.oid = c.relnamespace
+		where n.nspname = 'public' and c.relkind in ('r', 'p')
+		union all
+		select t.root, i.inhrelid from tree t join pg_inherits i on i.inhparent = t.relid),
+	writes as (select t.root, sum(s.n_tup_ins)::bigint as ins, sum(s.n_tup_upd)::bigint as upd, sum(s.n_tup_del)::bigint as del
+		from tree t join pg_stat_user_tables s on s.relid = t.relid
+		group by t.root)
+	select r.relname::text,
+		md5(string_agg(c.column_name || ' ' || c.udt_name, ',' order by c.ordinal_position)),
+		w.ins, w.upd, w.del,
+		coalesce((select stats_reset::text from pg_stat_database where datname = current_database()), '')
+	from writes w
+	join pg_class r on r.oid = w.root
+	join information_schema.columns c on c.table_schema = 'public' and c.table_name = r.relname
+	where r.relname not in (` + children + `)
+	group by r.relname, w.ins, w.upd, w.del`
+	rows, err := p.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	fingerprints := map[string]string{}
+	columns := map[string]string{}
+	for rows.Next() {
+		var table, cols, reset string
+		var ins, upd, del int64
+		if err := rows.Scan(&table, &cols, &ins, &upd, &del, &reset); err != nil {
+			return nil, err
+		}
+		columns[table] = cols
+		fingerprints[table] = fmt.Sprintf("%s/%d/%d/%d/%s", cols, ins, upd, del, reset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !checksum {
+		return fingerprints, nil
+	}
+	for table, cols := range columns {
+		q := fmt.Sprintf("select md5(coalesce(string_agg(md5(t::text), ',' order by md5(t::text)), 'empty')) from %s as t", p.quote(table))
+		var sum string
+		if err := p.DB.QueryRow(q).Scan(&sum); err != nil {
+			return nil, err
+		}
+		fingerprints[table] = cols + "/" + sum
+	}
+	return fingerprints, nil
+}
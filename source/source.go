@@ -0,0 +1,534 @@
+// Package source hides the database being profiled behind the catalog and
+// statistics queries the extractor runs, so the same phases work on
+// Postgres, MySQL and the other supported sources.
+package source
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ErrUnavailable is returned by sources that cannot answer a query at all,
+// such as statistics from a schema without data or of a view left out of
+// profiling.
+var ErrUnavailable = errors.New("not available from this source")
+
+// NullValue stands in for NULL in sampled rows, NULLs compare equal to each
+// other when partitioning.
+const NullValue = "\x00null"
+
+// Column is a column of an entity.
+type Column struct {
+	Entity string
+	Name   string
+	// DataType is the SQL standard name information_schema uses, such as
+	// integer, numeric, date or timestamp without time zone. Sources map
+	// their own names onto these so columns are classified the same way.
+	DataType string
+	// Native is the source's own name for the type, it becomes the
+	// dataType/ IRI of the column. Columns of a domain carry the domain.
+	Native string
+	// Dimensions is the declared number of dimensions of an array column.
+	Dimensions int
+	NotNull    bool
+	// Default is the default expression as the source prints it, empty for
+	// none.
+	Default string
+	Comment string
+}
+
+// Type kinds of the structured types Types describes.
+const (
+	EnumType      = "enum"
+	DomainType    = "domain"
+	ArrayType     = "array"
+	CompositeType = "composite"
+)
+
+// Type is a user defined or structured type, only the fields of its kind
+// are set.
+type Type struct {
+	Kind string
+	// Labels are the values of an enum in their sort order.
+	Labels []string
+	// Base is the native name of the base type of a domain or the element
+	// type of an array.
+	Base string
+	// Checks are the CHECK constraints of a domain.
+	Checks []string
+	// Fields are the fields of a composite type in order.
+	Fields []Field
+}
+
+// Field is a field of a composite type.
+type Field struct {
+	Name   string
+	Native string
+}
+
+// Entity kinds, views are profiled like tables but hold no data of their own.
+const (
+	TableKind            = "table"
+	ViewKind             = "view"
+	MaterializedViewKind = "materializedView"
+)
+
+// Entity is a table or view and its kind.
+type Entity struct {
+	Name    string
+	Kind    string
+	Comment string
+}
+
+// Check is a CHECK constraint, Columns are the columns it reads when the
+// source tells them.
+type Check struct {
+	Name       string
+	Entity     string
+	Columns    []string
+	Definition string
+}
+
+// Partitioning is how a parent entity is split into child tables, either
+// declaratively or by inheritance. The children are not entities of their
+// own, their rows are read through the parent.
+type Partitioning struct {
+	// Strategy is range, list, hash or inheritance.
+	Strategy string
+	// Key holds the partition key columns, it is empty for inheritance and
+	// for keys on expressions.
+	Key []string
+	// Partitions are the direct children, in name order.
+	Partitions []Partition
+}
+
+// Partition is a child of a partitioned or inherited table, Bound is its
+// partition bound as Postgres writes it, such as
+// FOR VALUES FROM ('2019-01-01') TO ('2019-02-01'), and empty for inheritance.
+type Partition struct {
+	Name  string
+	Bound string
+}
+
+// ForeignKey is a declared foreign key, RefColumns lines up with Columns.
+type ForeignKey struct {
+	Name       string
+	Entity     string
+	Columns    []string
+	RefEntity  string
+	RefColumns []string
+}
+
+// Bucket is a single histogram bin of a scalar column. Bounds are inclusive
+// for equi-depth histograms, for equi-width ones only the last bucket
+// includes its upper bound.
+type Bucket struct {
+	Lower float64
+	Upper float64
+	Count int
+}
+
+// Frequency is one of the most common values of a discrete column.
+type Frequency struct {
+	Value string
+	Count int
+}
+
+// Moments holds the moments and quartiles of a scalar column, M3 and M4 are
+// the third and fourth central moments and Outliers counts the values past
+// the Tukey fences.
+type Moments struct {
+	Mean     float64
+	StdDev   float64
+	Q1       float64
+	Q3       float64
+	Min      float64
+	Max      float64
+	M3       float64
+	M4       float64
+	Outliers int
+}
+
+// Source answers the catalog and statistics queries of an extraction.
+// Entity and column names are passed as the catalog returned them, sources
+// quote them as their SQL dialect requires.
+type Source interface {
+	// Columns lists every column of every entity.
+	Columns() ([]Column, error)
+	// Entities lists every entity with its kind in name order.
+	Entities() ([]Entity, error)
+	// Lineage returns the entities each view reads from, for the sources
+	// that record it.
+	Lineage() (map[string][]string, error)
+	// Partitions returns the partitioning of each partitioned or inherited
+	// entity.
+	Partitions() (map[string]Partitioning, error)
+	// Types describes the structured types of the columns by their native
+	// name, along with the types these refer to in turn.
+	Types() (map[string]Type, error)
+	// PrimaryKeys returns the primary key columns of each entity in key order.
+	PrimaryKeys() (map[string][]string, error)
+	ForeignKeys() ([]ForeignKey, error)
+	// Checks lists the CHECK constraints of every entity.
+	Checks() ([]Check, error)
+	// UniqueKeys returns the columns of the UNIQUE constraints and unique
+	// indexes of each entity, leaving out the primary key.
+	UniqueKeys() (map[string][][]string, error)
+
+	NumDistinct(entity string, col string) (int, error)
+	// MaxDistinctPer returns the largest number of distinct values of other
+	// found with a single value of col, 1 when col determines other and 0
+	// for an empty entity.
+	MaxDistinctPer(entity string, col string, other string) (int, error)
+	// Histogram returns n equally wide buckets, or with depth n buckets
+	// holding about as many rows each.
+	Histogram(entity string, col string, n int, depth bool) ([]Bucket, error)
+	// TopValues returns the k most frequent values, most frequent first.
+	TopValues(entity string, col string, k int) ([]Frequency, error)
+	// Moments returns false when the column holds no values.
+	Moments(entity string, col string) (Moments, bool, error)
+	// Correlation returns the Pearson and Spearman coefficients of two
+	// scalar columns over a sample percentage of the rows, every row for 0.
+	Correlation(entity string, col1 string, col2 string, sample float64) (sql.NullFloat64, sql.NullFloat64, error)
+	// SampleRows reads up to limit rows of the columns as text, all of them
+	// for 0. NULLs are NullValue.
+	SampleRows(entity string, cols []string, limit int) ([][]string, error)
+	// RandomRows is SampleRows with the rows picked at random, so the oldest
+	// rows do not stand in for the table.
+	RandomRows(entity string, cols []string, limit int) ([][]string, error)
+	// ContainedInKey reports whether the values of cols, in every row where
+	// none is NULL, are the values of keyCols in another row, which makes
+	// cols an undeclared self reference.
+	ContainedInKey(entity string, cols []string, keyCols []string) (bool, error)
+	// RecursiveDepth follows the references of cols to keyCols from the rows
+	// without a parent and returns the longest chain and whether some rows
+	// are only reachable through a cycle.
+	RecursiveDepth(entity string, cols []string, keyCols []string) (int, bool, error)
+	// IsUnique reports whether no row has a NULL in cols and no two rows
+	// share their values, which makes cols a candidate key.
+	IsUnique(entity string, cols []string) (bool, error)
+	// Fingerprints returns a value per entity that changes with its columns
+	// or data, approximately from statistics or exactly with checksum.
+	Fingerprints(checksum bool) (map[string]string, error)
+}
+
+// WidthBuckets lays out n equally wide buckets between lo and hi, counts is
+// keyed by the 1 based bucket number.
+func WidthBuckets(lo float64, hi float64, n int, counts map[int]int) []Bucket {
+	buckets := make([]Bucket, n)
+	width := (hi - lo) / float64(n)
+	for i := range buckets {
+		buckets[i].Lower = lo + float64(i)*width
+		buckets[i].Upper = lo + float64(i+1)*width
+		buckets[i].Count = counts[i+1]
+	}
+	// avoid rounding drift on the last bound
+	buckets[n-1].Upper = hi
+	return buckets
+}
+
+// scanRows reads rows of nullable text into a sample.
+func scanRows(rows *sql.Rows, n int) ([][]string, error) {
+	defer rows.Close()
+	sample := [][]string{}
+	vals := make([]sql.NullString, n)
+	dest := make([]interface{}, n)
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make([]string, n)
+		for i, v := range vals {
+			row[i] = NullValue
+			if v.Valid {
+				row[i] = v.String
+			}
+		}
+		sample = append(sample, row)
+	}
+	return sample, rows.Err()
+}
+
+// scanBuckets reads rows of lower bound, upper bound and count.
+func scanBuckets(rows *sql.Rows) ([]Bucket, error) {
+	defer rows.Close()
+	buckets := []Bucket{}
+	for rows.Next() {
+		b := Bucket{}
+		if err := rows.Scan(&b.Lower, &b.Upper, &b.Count); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// scanCounts reads rows of 1 based bucket number and count.
+func scanCounts(rows *sql.Rows) (map[int]int, error) {
+	defer rows.Close()
+	counts := map[int]int{}
+	for rows.Next() {
+		var b, count int
+		if err := rows.Scan(&b, &count); err != nil {
+			return nil, err
+		}
+		counts[b] = count
+	}
+	return counts, rows.Err()
+}
+
+// scanFrequencies reads rows of value and count.
+func scanFrequencies(rows *sql.Rows) ([]Frequency, error) {
+	defer rows.Close()
+	freqs := []Frequency{}
+	for rows.Next() {
+		f := Frequency{}
+		if err := rows.Scan(&f.Value, &f.Count); err != nil {
+			return nil, err
+		}
+		freqs = append(freqs, f)
+	}
+	return freqs, rows.Err()
+}
+
+// scanForeignKeys groups rows of constraint name, entity, column, referenced
+// entity and referenced column, ordered by entity, constraint and position.
+func scanForeignKeys(rows *sql.Rows) ([]ForeignKey, error) {
+	defer rows.Close()
+	fks := []ForeignKey{}
+	for rows.Next() {
+		var name, entity, col, refEntity, refCol string
+		if err := rows.Scan(&name, &entity, &col, &refEntity, &refCol); err != nil {
+			return nil, err
+		}
+		last := len(fks) - 1
+		if last < 0 || fks[last].Name != name || fks[last].Entity != entity {
+			fks = append(fks, ForeignKey{Name: name, Entity: entity, RefEntity: refEntity})
+			last++
+		}
+		fks[last].Columns = append(fks[last].Columns, col)
+		fks[last].RefColumns = append(fks[last].RefColumns, refCol)
+	}
+	return fks, rows.Err()
+}
+
+// scanUniqueKeys groups rows of entity, constraint or index name and column,
+// ordered by entity, name and position.
+func scanUniqueKeys(rows *sql.Rows) (map[string][][]string, error) {
+	defer rows.Close()
+	keys := map[string][][]string{}
+	lastEntity, lastName := "", ""
+	for rows.Next() {
+		var entity, name, col string
+		if err := rows.Scan(&entity, &name, &col); err != nil {
+			return nil, err
+		}
+		if entity != lastEntity || name != lastName {
+			keys[entity] = append(keys[entity], []string{})
+			lastEntity, lastName = entity, name
+		}
+		last := len(keys[entity]) - 1
+		keys[entity][last] = append(keys[entity][last], col)
+	}
+	return keys, rows.Err()
+}
+
+// uniqueQuery counts the rows with a NULL in cols and the groups of cols
+// shared by several rows, neither may exist in a candidate key. It works in
+// every SQL source.
+//
+// example sql
+// select (select count(*) from city where name is null or country is null)
+// + (select count(*) from (select 1 from city group by name, country having count(*) > 1) as Derived);
+func uniqueQuery(quote func(string) string, entity string, cols []string) string {
+	quoted := make([]string, len(cols))
+	nulls := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = quote(c)
+		nulls[i] = quoted[i] + " is null"
+	}
+	return fmt.Sprintf("select (select count(*) from %[1]s where %[2]s) + (select count(*) from (select 1 from %[1]s group by %[3]s having count(*) > 1) as Derived)",
+		quote(entity), strings.Join(nulls, " or "), strings.Join(quoted, ", "))
+}
+
+// isUnique runs uniqueQuery.
+func isUnique(db *sql.DB, quote func(string) string, entity string, cols []string) (bool, error) {
+	var violations int
+	err := db.QueryRow(uniqueQuery(quote, entity, cols)).Scan(&violations)
+	return violations == 0, err
+}
+
+// containedQuery counts the rows with no NULL in cols, those of them whose
+// cols differ from their own keyCols and those whose cols match keyCols in
+// no row. It works in every SQL source.
+//
+// example sql
+// select count(*), coalesce(sum(case when c.river <> c.name then 1 else 0 end), 0),
+// coalesce(sum(case when not exists (select 1 from river as p where p.name = c.river) then 1 else 0 end), 0)
+// from river as c where c.river is not null;
+func containedQuery(quote func(string) string, entity string, cols []string, keyCols []string) string {
+	notNull := make([]string, len(cols))
+	differ := make([]string, len(cols))
+	match := make([]string, len(cols))
+	for i, c := range cols {
+		notNull[i] = "c." + quote(c) + " is not null"
+		differ[i] = "c." + quote(c) + " <> c." + quote(keyCols[i])
+		match[i] = "p." + quote(keyCols[i]) + " = c." + quote(c)
+	}
+	return fmt.Sprintf(`select count(*),
+		coalesce(sum(case when %[2]s then 1 else 0 end), 0),
+		coalesce(sum(case when not exists (select 1 from %[1]s as p where %[3]s) then 1 else 0 end), 0)
+	from %[1]s as c where %[4]s`, quote(entity), strings.Join(differ, " or "), strings.Join(match, " and "), strings.Join(notNull, " and "))
+}
+
+// containedInKey runs containedQuery, the reference must lead somewhere
+// other than the row itself at least once.
+func containedInKey(db *sql.DB, quote func(string) string, entity string, cols []string, keyCols []string) (bool, error) {
+	var total, differ, missing int
+	if err := db.QueryRow(containedQuery(quote, entity, cols, keyCols)).Scan(&total, &differ, &missing); err != nil {
+		return false, err
+	}
+	return total > 0 && differ > 0 && missing == 0, nil
+}
+
+// scanChecks groups rows of constraint name, entity, definition and column,
+// ordered by entity, constraint and position. The column is empty when the
+// constraint reads none or the source does not say.
+func scanChecks(rows *sql.Rows) ([]Check, error) {
+	defer rows.Close()
+	checks := []Check{}
+	for rows.Next() {
+		var name, entity, def, col string
+		if err := rows.Scan(&name, &entity, &def, &col); err != nil {
+			return nil, err
+		}
+		last := len(checks) - 1
+		if last < 0 || checks[last].Name != name || checks[last].Entity != entity {
+			checks = append(checks, Check{Name: name, Entity: entity, Definition: def})
+			last++
+		}
+		if col != "" {
+			checks[last].Columns = append(checks[last].Columns, col)
+		}
+	}
+	return checks, rows.Err()
+}
+
+// scanEntities reads rows of entity, kind and comment.
+func scanEntities(rows *sql.Rows) ([]Entity, error) {
+	defer rows.Close()
+	entities := []Entity{}
+	for rows.Next() {
+		e := Entity{}
+		if err := rows.Scan(&e.Name, &e.Kind, &e.Comment); err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+// scanKeys groups rows of entity and column into keys.
+func scanKeys(rows *sql.Rows) (map[string][]string, error) {
+	defer rows.Close()
+	keys := map[string][]string{}
+	for rows.Next() {
+		var entity, col string
+		if err := rows.Scan(&entity, &col); err != nil {
+			return nil, err
+		}
+		keys[entity] = append(keys[entity], col)
+	}
+	return keys, rows.Err()
+}
+
+// momentsOf computes Moments in memory for sources without the aggregates,
+// quartiles interpolate as percentile_cont does.
+func momentsOf(values []float64) (Moments, bool) {
+	n := float64(len(values))
+	if n == 0 {
+		return Moments{}, false
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	percentile := func(p float64) float64 {
+		pos := p * (n - 1)
+		i := int(pos)
+		if i+1 >= len(sorted) {
+			return sorted[i]
+		}
+		return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+	}
+	m := Moments{Q1: percentile(0.25), Q3: percentile(0.75), Min: sorted[0], Max: sorted[len(sorted)-1]}
+	for _, v := range sorted {
+		m.Mean += v / n
+	}
+	var m2 float64
+	for _, v := range sorted {
+		d := v - m.Mean
+		m2 += d * d / n
+		m.M3 += d * d * d / n
+		m.M4 += d * d * d * d / n
+	}
+	m.StdDev = math.Sqrt(m2)
+	iqr := m.Q3 - m.Q1
+	for _, v := range sorted {
+		if v < m.Q1-1.5*iqr || v > m.Q3+1.5*iqr {
+			m.Outliers++
+		}
+	}
+	return m, true
+}
+
+// pearson is NULL when either side is constant or there are no pairs, as
+// corr is.
+func pearson(xs []float64, ys []float64) sql.NullFloat64 {
+	n := float64(len(xs))
+	if n == 0 {
+		return sql.NullFloat64{}
+	}
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i] / n
+		my += ys[i] / n
+	}
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: cov / math.Sqrt(vx*vy), Valid: true}
+}
+
+// ranks gives ties the average of their ranks, Spearman is Pearson over these.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+	r := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		for k := i; k <= j; k++ {
+			r[order[k]] = float64(i+j)/2 + 1
+		}
+		i = j + 1
+	}
+	return r
+}
@@ -0,0 +1,16 @@
+package source
+
+import "testing"
+
+func TestWidthBuckets(t *testing.T) {
+	buckets := WidthBuckets(0, 10, 4, map[int]int{1: 3, 4: 7})
+	if len(buckets) != 4 {
+		t.Fatalf("wanted 4 buckets got %d", len(buckets))
+	}
+	want := []Bucket{{0, 2.5, 3}, {2.5, 5, 0}, {5, 7.5, 0}, {7.5, 10, 7}}
+	for i, b := range buckets {
+		if b != want[i] {
+			t.Errorf("bucket %d: wanted %v got %v", i, want[i], b)
+		}
+	}
+}
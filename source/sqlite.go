@@ -0,0 +1,491 @@
+package source
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SQLite profiles a SQLite database file. SQLite has no statistical
+// aggregates, so moments and correlations are computed in memory.
+type SQLite struct {
+	DB *sql.DB
+}
+
+// NewSQLite returns a source reading db.
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{DB: db}
+}
+
+func (s *SQLite) quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// sqliteAffinity applies the rules SQLite uses to give a declared type its
+// affinity, https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+func sqliteAffinity(declared string) string {
+	t := strings.ToUpper(declared)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "integer"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "text"
+	case strings.Contains(t, "BLOB"), t == "":
+		return "blob"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "real"
+	}
+	return "numeric"
+}
+
+// sqliteType maps an affinity onto the information_schema names columns are
+// classified by. Dates and JSON have numeric affinity, so the declared type
+// decides whether a column is temporal or holds documents.
+func sqliteType(declared string) string {
+	t := strings.ToUpper(declared)
+	switch affinity := sqliteAffinity(declared); {
+	case affinity == "numeric" && strings.HasPrefix(t, "JSON"):
+		return "json"
+	case affinity == "numeric" && strings.Contains(t, "DATETIME"), affinity == "numeric" && strings.Contains(t, "TIMESTAMP"):
+		return "timestamp without time zone"
+	case affinity == "numeric" && strings.Contains(t, "DATE"):
+		return "date"
+	case affinity == "real":
+		return "double precision"
+	case affinity == "blob":
+		return "bytea"
+	default:
+		return affinity
+	}
+}
+
+// entities lists the tables and views, skipping SQLite's own.
+func (s *SQLite) entities() ([]string, error) {
+	rows, err := s.DB.Query("select name from sqlite_master where type in ('table', 'view') and name not like 'sqlite_%' order by name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (s *SQLite) Entities() ([]Entity, error) {
+	rows, err := s.DB.Query("select name, type, '' from sqlite_master where type in ('table', 'view') and name not like 'sqlite_%' order by name")
+	if err != nil {
+		return nil, err
+	}
+	return scanEntities(rows)
+}
+
+// Checks is empty, SQLite keeps CHECK constraints only in the text of the
+// CREATE TABLE statement.
+func (s *SQLite) Checks() ([]Check, error) {
+	return []Check{}, nil
+}
+
+// Lineage is empty, SQLite keeps only the text of a view.
+func (s *SQLite) Lineage() (map[string][]string, error) {
+	return map[string][]string{}, nil
+}
+
+// Partitions is empty, SQLite has no partitioning.
+func (s *SQLite) Partitions() (map[string]Partitioning, error) {
+	return map[string]Partitioning{}, nil
+}
+
+// Types is empty, SQLite has no user defined types.
+func (s *SQLite) Types() (map[string]Type, error) {
+	return map[string]Type{}, nil
+}
+
+// Columns reads pragma table_info of every entity, the affinity of the
+// declared type becomes the data type IRI.
+func (s *SQLite) Columns() ([]Column, error) {
+	entities, err := s.entities()
+	if err != nil {
+		return nil, err
+	}
+	cols := []Column{}
+	for _, entity := range entities {
+		rows, err := s.DB.Query("select name, type, \"notnull\", coalesce(dflt_value, '') from pragma_table_info(?) order by cid", entity)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name, declared, def string
+			var notNull bool
+			if err := rows.Scan(&name, &declared, &notNull, &def); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			cols = append(cols, Column{Entity: entity, Name: name, DataType: sqliteType(declared), Native: sqliteAffinity(declared), NotNull: notNull, Default: def})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return cols, nil
+}
+
+// PrimaryKeys reads the key position pragma table_info gives each column.
+func (s *SQLite) PrimaryKeys() (map[string][]string, error) {
+	entities, err := s.entities()
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string][]string{}
+	for _, entity := range entities {
+		rows, err := s.DB.Query("select ?, name from pragma_table_info(?) where pk > 0 order by pk", entity, entity)
+		if err != nil {
+			return nil, err
+		}
+		found, err := scanKeys(rows)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range found {
+			keys[k] = v
+		}
+	}
+	return keys, nil
+}
+
+// ForeignKeys reads pragma foreign_key_list, a reference without columns
+// points at the primary key of the referenced table.
+func (s *SQLite) ForeignKeys() ([]ForeignKey, error) {
+	entities, err := s.entities()
+	if err != nil {
+		return nil, err
+	}
+	pks, err := s.PrimaryKeys()
+	if err != nil {
+		return nil, err
+	}
+	fks := []ForeignKey{}
+	for _, entity := range entities {
+		rows, err := s.DB.Query(`select id, seq, "table", "from", "to" from pragma_foreign_key_list(?) order by id, seq`, entity)
+		if err != nil {
+			return nil, err
+		}
+		last := -1
+		for rows.Next() {
+			var id, seq int
+			var refEntity, col string
+			var refCol sql.NullString
+			if err := rows.Scan(&id, &seq, &refEntity, &col, &refCol); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if seq == 0 {
+				// SQLite leaves foreign keys unnamed
+				fks = append(fks, ForeignKey{Name: fmt.Sprintf("%s_fk%d", entity, id), Entity: entity, RefEntity: refEntity})
+				last = len(fks) - 1
+			}
+			if !refCol.Valid && seq < len(pks[refEntity]) {
+				refCol.String = pks[refEntity][seq]
+			}
+			fks[last].Columns = append(fks[last].Columns, col)
+			fks[last].RefColumns = append(fks[last].RefColumns, refCol.String)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return fks, nil
+}
+
+// UniqueKeys reads pragma index_list and index_info, UNIQUE constraints
+// show up as automatic indexes. Partial and expression indexes are skipped.
+func (s *SQLite) UniqueKeys() (map[string][][]string, error) {
+	entities, err := s.entities()
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string][][]string{}
+	for _, entity := range entities {
+		q := `select il.name, ii.name from pragma_index_list(?) as il join pragma_index_info(il.name) as ii
+		where il."unique" and il.origin <> 'pk' and not il.partial
+		order by il.seq, ii.seqno`
+		rows, err := s.DB.Query(q, entity)
+		if err != nil {
+			return nil, err
+		}
+		indexes := map[string][]string{}
+		order := []string{}
+		expression := map[string]bool{}
+		for rows.Next() {
+			var index string
+			var col sql.NullString
+			if err := rows.Scan(&index, &col); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if _, ok := indexes[index]; !ok {
+				order = append(order, index)
+			}
+			indexes[index] = append(indexes[index], col.String)
+			expression[index] = expression[index] || !col.Valid
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		for _, index := range order {
+			if !expression[index] {
+				keys[entity] = append(keys[entity], indexes[index])
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (s *SQLite) IsUnique(entity string, cols []string) (bool, error) {
+	return isUnique(s.DB, s.quote, entity, cols)
+}
+
+func (s *SQLite) NumDistinct(entity string, col string) (int, error) {
+	var count int
+	q := fmt.Sprintf("select count(distinct %s) from %s", s.quote(col), s.quote(entity))
+	err := s.DB.QueryRow(q).Scan(&count)
+	return count, err
+}
+
+func (s *SQLite) MaxDistinctPer(entity string, col string, other string) (int, error) {
+	q := fmt.Sprintf("select max(output) from (select %s, count(distinct %s) as output from %s group by %s) as Derived",
+		s.quote(col), s.quote(other), s.quote(entity), s.quote(col))
+	var n sql.NullInt64
+	err := s.DB.QueryRow(q).Scan(&n)
+	return int(n.Int64), err
+}
+
+// Histogram truncates with a cast as floor is only there when SQLite is
+// built with its math functions, values are never below the minimum.
+//
+// example sql
+// select min(cast((population - 0) / 1e8 as integer) + 1, 10) as b, count(*) from city where population is not null group by b order by b;
+func (s *SQLite) Histogram(entity string, col string, n int, depth bool) ([]Bucket, error) {
+	col, entity = s.quote(col), s.quote(entity)
+	if depth {
+		q := fmt.Sprintf("select cast(min(%s) as real), cast(max(%s) as real), count(*) from (select %s, ntile(%d) over (order by %s) as b from %s where %s is not null) as Derived group by b order by b",
+			col, col, col, n, col, entity, col)
+		rows, err := s.DB.Query(q)
+		if err != nil {
+			return nil, err
+		}
+		return scanBuckets(rows)
+	}
+
+	var lo, hi *float64
+	q := fmt.Sprintf("select cast(min(%s) as real), cast(max(%s) as real) from %s", col, col, entity)
+	if err := s.DB.QueryRow(q).Scan(&lo, &hi); err != nil {
+		return nil, err
+	}
+	if lo == nil || hi == nil {
+		return nil, nil
+	}
+	q = fmt.Sprintf("select min(cast((%s - %v) / %v as integer) + 1, %d) as b, count(*) from %s where %s is not null group by b order by b",
+		col, *lo, (*hi-*lo)/float64(n), n, entity, col)
+	if *lo == *hi {
+		n = 1
+		q = fmt.Sprintf("select 1, count(%s) from %s", col, entity)
+	}
+	rows, err := s.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := scanCounts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return WidthBuckets(*lo, *hi, n, counts), nil
+}
+
+func (s *SQLite) TopValues(entity string, col string, k int) ([]Frequency, error) {
+	col, entity = s.quote(col), s.quote(entity)
+	q := fmt.Sprintf("select cast(%s as text), count(*) from %s where %s is not null group by %s order by 2 desc, 1 limit %d",
+		col, entity, col, col, k)
+	rows, err := s.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanFrequencies(rows)
+}
+
+// values reads the non NULL pairs of the columns as floats.
+func (s *SQLite) values(q string, n int) ([][]float64, error) {
+	rows, err := s.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := make([][]float64, n)
+	vals := make([]float64, n)
+	dest := make([]interface{}, n)
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, v := range vals {
+			cols[i] = append(cols[i], v)
+		}
+	}
+	return cols, rows.Err()
+}
+
+func (s *SQLite) Moments(entity string, col string) (Moments, bool, error) {
+	q := fmt.Sprintf("select cast(%[1]s as real) from %[2]s where %[1]s is not null", s.quote(col), s.quote(entity))
+	values, err := s.values(q, 1)
+	if err != nil {
+		return Moments{}, false, err
+	}
+	m, ok := momentsOf(values[0])
+	return m, ok, nil
+}
+
+func (s *SQLite) Correlation(entity string, col1 string, col2 string, sample float64) (sql.NullFloat64, sql.NullFloat64, error) {
+	where := ""
+	if sample > 0 && sample < 100 {
+		where = fmt.Sprintf(" and abs(random() %% 1000000) < %v", sample*10000)
+	}
+	q := fmt.Sprintf("select cast(%[1]s as real), cast(%[2]s as real) from %[3]s where %[1]s is not null and %[2]s is not null%[4]s",
+		s.quote(col1), s.quote(col2), s.quote(entity), where)
+	values, err := s.values(q, 2)
+	if err != nil {
+		return sql.NullFloat64{}, sql.NullFloat64{}, err
+	}
+	xs, ys := values[0], values[1]
+	return pearson(xs, ys), pearson(ranks(xs), ranks(ys)), nil
+}
+
+func (s *SQLite) SampleRows(entity string, cols []string, limit int) ([][]string, error) {
+	return s.readRows(entity, cols, limit, "")
+}
+
+func (s *SQLite) RandomRows(entity string, cols []string, limit int) ([][]string, error) {
+	return s.readRows(entity, cols, limit, "random()")
+}
+
+// readRows reads up to limit rows of the columns as text in the given order.
+func (s *SQLite) readRows(entity string, cols []string, limit int, order string) ([][]string, error) {
+	exprs := make([]string, len(cols))
+	for i, c := range cols {
+		exprs[i] = "cast(" + s.quote(c) + " as text)"
+	}
+	q := fmt.Sprintf("select %s from %s", strings.Join(exprs, ", "), s.quote(entity))
+	if order != "" {
+		q += " order by " + order
+	}
+	if limit > 0 {
+		q += fmt.Sprintf(" limit %d", limit)
+	}
+	rows, err := s.DB.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(rows, len(cols))
+}
+
+func (s *SQLite) ContainedInKey(entity string, cols []string, keyCols []string) (bool, error) {
+	return containedInKey(s.DB, s.quote, entity, cols, keyCols)
+}
+
+// RecursiveDepth keeps the path as a delimited string, SQLite has no arrays.
+func (s *SQLite) RecursiveDepth(entity string, cols []string, keyCols []string) (int, bool, error) {
+	qualify := func(alias string, cols []string) string {
+		qualified := make([]string, len(cols))
+		for i, c := range cols {
+			qualified[i] = "cast(" + alias + "." + s.quote(c) + " as text)"
+		}
+		return strings.Join(qualified, " || char(31) || ")
+	}
+	q := fmt.Sprintf(`with recursive walk(k, depth, path) as (
+		select %[2]s, 0, char(30) || %[2]s || char(30) from %[1]s as c
+		where not exists (select 1 from %[1]s as p where %[3]s = %[4]s)
+		union all
+		select %[2]s, w.depth + 1, w.path || %[2]s || char(30) from %[1]s as c
+		join walk as w on %[4]s = w.k
+		where instr(w.path, char(30) || %[2]s || char(30)) = 0)
+	select coalesce(max(depth), 0), count(distinct k), (select count(*) from %[1]s) from walk`,
+		s.quote(entity), qualify("c", keyCols), qualify("p", keyCols), qualify("c", cols))
+
+	var depth, reached, total int
+	if err := s.DB.QueryRow(q).Scan(&depth, &reached, &total); err != nil {
+		return 0, false, err
+	}
+	return depth, reached < total, nil
+}
+
+// Fingerprints hash the columns of a table with its row count and largest
+// rowid, SQLite keeps no write counters. With checksum every row is hashed.
+func (s *SQLite) Fingerprints(checksum bool) (map[string]string, error) {
+	cols, err := s.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]string{}
+	for _, c := range cols {
+		columns[c.Entity] += c.Name + " " + c.Native + ","
+	}
+	fingerprints := map[string]string{}
+	for entity, desc := range columns {
+		hash := fmt.Sprintf("%x", md5.Sum([]byte(desc)))
+		if checksum {
+			sum, err := s.checksum(entity)
+			if err != nil {
+				return nil, err
+			}
+			fingerprints[entity] = hash + "/" + sum
+			continue
+		}
+		var count int64
+		var maxRowid sql.NullInt64
+		q := fmt.Sprintf("select count(*), max(rowid) from %s", s.quote(entity))
+		if err := s.DB.QueryRow(q).Scan(&count, &maxRowid); err != nil {
+			// views and WITHOUT ROWID tables have no rowid
+			q = fmt.Sprintf("select count(*) from %s", s.quote(entity))
+			if err := s.DB.QueryRow(q).Scan(&count); err != nil {
+				return nil, err
+			}
+		}
+		fingerprints[entity] = fmt.Sprintf("%s/%d/%d", hash, count, maxRowid.Int64)
+	}
+	return fingerprints, nil
+}
+
+// checksum hashes the sorted hashes of every row, so row order is ignored.
+func (s *SQLite) checksum(entity string) (string, error) {
+	rows, err := s.DB.Query(fmt.Sprintf("select * from %s", s.quote(entity)))
+	if err != nil {
+		return "", err
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return "", err
+	}
+	sample, err := scanRows(rows, len(cols))
+	if err != nil {
+		return "", err
+	}
+	hashes := make([]string, len(sample))
+	for i, row := range sample {
+		hashes[i] = fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(row, "\x1f"))))
+	}
+	sort.Strings(hashes)
+	return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(hashes, ",")))), nil
+}
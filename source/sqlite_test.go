@@ -0,0 +1,221 @@
+package source
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openSQLite builds a small river network, river references its own key
+// and city references country without naming the column.
+func openSQLite(t *testing.T) *SQLite {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	stmts := []string{
+		`create table country (code varchar(4) primary key, name text, area double, founded date)`,
+		`create table city (name text, country varchar(4) references country, population integer, primary key (country, name))`,
+		`create table river (name text primary key, river text references river (name), length real)`,
+		`insert into country values ('D', 'Germany', 357000, '1871-01-18'), ('F', 'France', 547000, '1792-09-22'), ('NL', 'Netherlands', 41500, '1815-03-16')`,
+		`insert into city values ('Berlin', 'D', 3500000), ('Hamburg', 'D', 1800000), ('Paris', 'F', 2100000), ('Lyon', 'F', 500000), ('Amsterdam', 'NL', null)`,
+		`insert into river values ('Rhein', null, 1233), ('Main', 'Rhein', 524), ('Regnitz', 'Main', 162), ('Pegnitz', 'Regnitz', 115)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewSQLite(db)
+}
+
+func TestSQLiteAffinity(t *testing.T) {
+	for declared, want := range map[string][2]string{
+		"BIGINT":       {"integer", "integer"},
+		"varchar(4)":   {"text", "text"},
+		"":             {"blob", "bytea"},
+		"DOUBLE":       {"real", "double precision"},
+		"DECIMAL(8,2)": {"numeric", "numeric"},
+		"DATE":         {"numeric", "date"},
+		"DATETIME":     {"numeric", "timestamp without time zone"},
+		"JSON":         {"numeric", "json"},
+	} {
+		if got := [2]string{sqliteAffinity(declared), sqliteType(declared)}; got != want {
+			t.Errorf("%q: wanted %v got %v", declared, want, got)
+		}
+	}
+}
+
+func TestSQLiteCatalog(t *testing.T) {
+	s := openSQLite(t)
+	cols, err := s.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, c := range cols {
+		got = append(got, fmt.Sprintf("%s.%s %s %s", c.Entity, c.Name, c.Native, c.DataType))
+	}
+	want := []string{
+		"city.name text text", "city.country text text", "city.population integer integer",
+		"country.code text text", "country.name text text", "country.area real double precision", "country.founded numeric date",
+		"river.name text text", "river.river text text", "river.length real double precision",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns: wanted %v got %v", want, got)
+	}
+
+	keys, err := s.PrimaryKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKeys := map[string][]string{"city": {"country", "name"}, "country": {"code"}, "river": {"name"}}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("keys: wanted %v got %v", wantKeys, keys)
+	}
+
+	fks, err := s.ForeignKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFKs := []ForeignKey{
+		{Name: "city_fk0", Entity: "city", Columns: []string{"country"}, RefEntity: "country", RefColumns: []string{"code"}},
+		{Name: "river_fk0", Entity: "river", Columns: []string{"river"}, RefEntity: "river", RefColumns: []string{"name"}},
+	}
+	if !reflect.DeepEqual(fks, wantFKs) {
+		t.Errorf("foreign keys: wanted %v got %v", wantFKs, fks)
+	}
+}
+
+func TestSQLiteStatistics(t *testing.T) {
+	s := openSQLite(t)
+	if n, err := s.NumDistinct("city", "country"); err != nil || n != 3 {
+		t.Errorf("distinct countries: wanted 3 got %d (%v)", n, err)
+	}
+	if n, err := s.MaxDistinctPer("city", "country", "name"); err != nil || n != 2 {
+		t.Errorf("cities per country: wanted 2 got %d (%v)", n, err)
+	}
+
+	buckets, err := s.Histogram("river", "length", 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bucket{{115, 674, 3}, {674, 1233, 1}}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("histogram: wanted %v got %v", want, buckets)
+	}
+
+	top, err := s.TopValues("city", "country", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 1 || top[0] != (Frequency{Value: "D", Count: 2}) {
+		t.Errorf("top values: wanted [{D 2}] got %v", top)
+	}
+
+	m, ok, err := s.Moments("river", "length")
+	if err != nil || !ok {
+		t.Fatalf("moments: %v %v", ok, err)
+	}
+	if m.Mean != 508.5 || m.Min != 115 || m.Max != 1233 {
+		t.Errorf("moments: got %+v", m)
+	}
+
+	p, sp, err := s.Correlation("country", "area", "area", 0)
+	if err != nil || !p.Valid || !sp.Valid || p.Float64 < 0.999 || sp.Float64 < 0.999 {
+		t.Errorf("self correlation: got %v %v (%v)", p, sp, err)
+	}
+
+	ok, err = s.ContainedInKey("river", []string{"river"}, []string{"name"})
+	if err != nil || !ok {
+		t.Errorf("river.river should be contained in the key (%v)", err)
+	}
+	depth, cyclic, err := s.RecursiveDepth("river", []string{"river"}, []string{"name"})
+	if err != nil || depth != 3 || cyclic {
+		t.Errorf("recursive depth: wanted 3 acyclic got %d %v (%v)", depth, cyclic, err)
+	}
+
+	rows, err := s.SampleRows("city", []string{"name", "population"}, 0)
+	if err != nil || len(rows) != 5 || rows[4][1] != NullValue {
+		t.Errorf("sample: got %v (%v)", rows, err)
+	}
+	first, err := s.SampleRows("city", []string{"name"}, 2)
+	for i := 0; i < 10 && err == nil; i++ {
+		rows, err = s.SampleRows("city", []string{"name"}, 2)
+		if !reflect.DeepEqual(rows, first) {
+			t.Errorf("limited sample: wanted %v every time got %v", first, rows)
+		}
+	}
+	if err != nil {
+		t.Error(err)
+	}
+	rows, err = s.RandomRows("city", []string{"name"}, 2)
+	if err != nil || len(rows) != 2 {
+		t.Errorf("random sample: got %v (%v)", rows, err)
+	}
+}
+
+func TestSQLiteFingerprints(t *testing.T) {
+	s := openSQLite(t)
+	for _, checksum := range []bool{false, true} {
+		before, err := s.Fingerprints(checksum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.DB.Exec("update city set population = population + 1 where name = 'Lyon'"); err != nil {
+			t.Fatal(err)
+		}
+		after, err := s.Fingerprints(checksum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if before["country"] != after["country"] {
+			t.Errorf("checksum %v: country changed", checksum)
+		}
+		// updates keep the row count, only checksums see them
+		if checksum == (before["city"] == after["city"]) {
+			t.Errorf("checksum %v: city fingerprint %s -> %s", checksum, before["city"], after["city"])
+		}
+	}
+}
+
+func TestSQLiteUniqueKeys(t *testing.T) {
+	s := openSQLite(t)
+	for _, stmt := range []string{
+		`create unique index countryname on country (name)`,
+		`create unique index countrylower on country (lower(name))`,
+		`create table border (country1 text, country2 text, length real, unique (country1, country2))`,
+	} {
+		if _, err := s.DB.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys, err := s.UniqueKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][][]string{"country": {{"name"}}, "border": {{"country1", "country2"}}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("wanted %v got %v", want, keys)
+	}
+
+	for _, c := range []struct {
+		cols []string
+		want bool
+	}{
+		{[]string{"name"}, true},
+		{[]string{"country"}, false},
+		{[]string{"country", "name"}, true},
+		// Amsterdam has no population
+		{[]string{"population"}, false},
+	} {
+		if got, err := s.IsUnique("city", c.cols); err != nil || got != c.want {
+			t.Errorf("%v: wanted unique %v got %v (%v)", c.cols, c.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package sparql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/knakk/rdf"
+)
+
+// Exec runs the query against g.
+func (q *Query) Exec(g *graph.Graph) *Result {
+	solutions := evalGroup(g, q.Where, []graph.Binding{{}})
+	r := &Result{Form: q.Form}
+	switch q.Form {
+	case Ask:
+		r.Boolean = len(solutions) > 0
+		return r
+	case Construct:
+		solutions = q.slice(q.order(solutions))
+		r.Triples = q.construct(solutions)
+		return r
+	}
+	r.Vars = q.Vars
+	if len(r.Vars) == 0 {
+		r.Vars = patternVars(q.Where)
+	}
+	solutions = q.order(solutions)
+	projected := make([]graph.Binding, 0, len(solutions))
+	seen := map[string]bool{}
+	for _, s := range solutions {
+		b := graph.Binding{}
+		key := ""
+		for _, v := range r.Vars {
+			if t, ok := s[v]; ok {
+				b[v] = t
+				key += graph.Key(t)
+			}
+			key += "\x00"
+		}
+		if q.Distinct {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		projected = append(projected, b)
+	}
+	r.Bindings = q.slice(projected)
+	return r
+}
+
+// evalGroup joins the elements of a group in order, starting from seeds, and
+// then drops the solutions its filters reject.
+func evalGroup(g *graph.Graph, grp *group, seeds []graph.Binding) []graph.Binding {
+	solutions := seeds
+	for _, e := range grp.elements {
+		switch {
+		case e.bgp != nil:
+			solutions = g.Extend(solutions, e.bgp)
+		case e.optional != nil:
+			next := []graph.Binding{}
+			for _, s := range solutions {
+				extended := evalGroup(g, e.optional, []graph.Binding{s})
+				if len(extended) == 0 {
+					extended = []graph.Binding{s}
+				}
+				next = append(next, extended...)
+			}
+			solutions = next
+		case e.union != nil:
+			next := []graph.Binding{}
+			for _, branch := range e.union {
+				next = append(next, evalGroup(g, branch, solutions)...)
+			}
+			solutions = next
+		}
+	}
+	if len(grp.filters) == 0 {
+		return solutions
+	}
+	kept := solutions[:0:0]
+	for _, s := range solutions {
+		if accept(grp.filters, s) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// accept reports whether every filter is true for b, errors count as false.
+func accept(filters []expr, b graph.Binding) bool {
+	for _, f := range filters {
+		v, err := f.eval(b)
+		if err != nil {
+			return false
+		}
+		ok, err := effectiveBoolean(v)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// patternVars lists the variables of a group in order of appearance, leaving
+// out the hidden ones standing for blank nodes.
+func patternVars(grp *group) []string {
+	vars := []string{}
+	seen := map[string]bool{}
+	var walk func(*group)
+	walk = func(grp *group) {
+		for _, e := range grp.elements {
+			for _, p := range e.bgp {
+				for _, n := range []graph.Node{p.Subj, p.Pred, p.Obj} {
+					if n.IsVar() && !strings.HasPrefix(n.Var, "_:") && !seen[n.Var] {
+						seen[n.Var] = true
+						vars = append(vars, n.Var)
+					}
+				}
+			}
+			if e.optional != nil {
+				walk(e.optional)
+			}
+			for _, branch := range e.union {
+				walk(branch)
+			}
+		}
+	}
+	walk(grp)
+	return vars
+}
+
+func (q *Query) order(solutions []graph.Binding) []graph.Binding {
+	if len(q.Order) == 0 {
+		return solutions
+	}
+	sort.SliceStable(solutions, func(i, j int) bool {
+		for _, cond := range q.Order {
+			a, _ := cond.expr.eval(solutions[i])
+			b, _ := cond.expr.eval(solutions[j])
+			c := orderTerms(a, b)
+			if c == 0 {
+				continue
+			}
+			if cond.descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return solutions
+}
+
+// orderTerms sorts unbound values first, then blank nodes, IRIs and literals.
+func orderTerms(a rdf.Term, b rdf.Term) int {
+	rank := func(t rdf.Term) int {
+		if t == nil {
+			return 0
+		}
+		switch t.Type() {
+		case rdf.TermBlank:
+			return 1
+		case rdf.TermIRI:
+			return 2
+		}
+		return 3
+	}
+	if ra, rb := rank(a), rank(b); ra != rb || ra == 0 {
+		return ra - rb
+	}
+	if c, err := compareValues(a, b, false); err == nil {
+		return c
+	}
+	return strings.Compare(a.String(), b.String())
+}
+
+func (q *Query) slice(solutions []graph.Binding) []graph.Binding {
+	if q.Offset >= len(solutions) {
+		return nil
+	}
+	solutions = solutions[q.Offset:]
+	if q.Limit >= 0 && q.Limit < len(solutions) {
+		solutions = solutions[:q.Limit]
+	}
+	return solutions
+}
+
+// construct instantiates the template once per solution, triples with unbound
+// variables or terms that are not valid in their position are left out.
+func (q *Query) construct(solutions []graph.Binding) []rdf.Triple {
+	out := graph.New()
+	for i, s := range solutions {
+		term := func(n graph.Node) rdf.Term {
+			if !n.IsVar() {
+				return n.Term
+			}
+			if t, ok := s[n.Var]; ok {
+				return t
+			}
+			if strings.HasPrefix(n.Var, "_:") {
+				// template blank nodes are fresh for every solution
+				b, _ := rdf.NewBlank(fmt.Sprintf("%s_%d", strings.TrimPrefix(n.Var, "_:"), i))
+				return b
+			}
+			return nil
+		}
+		for _, p := range q.Template {
+			subj, pred, obj := term(p.Subj), term(p.Pred), term(p.Obj)
+			if subj == nil || pred == nil || obj == nil {
+				continue
+			}
+			st, sok := subj.(rdf.Subject)
+			pt, pok := pred.(rdf.IRI)
+			ot, ook := obj.(rdf.Object)
+			if !sok || !pok || !ook || subj.Type() == rdf.TermLiteral {
+				continue
+			}
+			out.Add(rdf.Triple{Subj: st, Pred: pt, Obj: ot})
+		}
+	}
+	return out.Triples()
+}
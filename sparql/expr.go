@@ -0,0 +1,464 @@
+package sparql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/knakk/rdf"
+)
+
+// errType is a SPARQL type error, a filter raising one is false.
+var errType = errors.New("type error")
+
+// expr is a filter or order expression evaluated against a binding, an
+// unbound variable or a type error make it fail.
+type expr interface {
+	eval(b graph.Binding) (rdf.Term, error)
+}
+
+type varExpr string
+
+func (v varExpr) eval(b graph.Binding) (rdf.Term, error) {
+	t, ok := b[string(v)]
+	if !ok {
+		return nil, fmt.Errorf("unbound variable ?%s", string(v))
+	}
+	return t, nil
+}
+
+type constExpr struct {
+	term rdf.Term
+}
+
+func (c constExpr) eval(graph.Binding) (rdf.Term, error) {
+	return c.term, nil
+}
+
+type unaryExpr struct {
+	op  string
+	arg expr
+}
+
+func (u unaryExpr) eval(b graph.Binding) (rdf.Term, error) {
+	v, err := u.arg.eval(b)
+	if err != nil {
+		return nil, err
+	}
+	switch u.op {
+	case "!":
+		ebv, err := effectiveBoolean(v)
+		if err != nil {
+			return nil, err
+		}
+		return boolean(!ebv), nil
+	case "-":
+		n, ok := numericValue(v)
+		if !ok {
+			return nil, errType
+		}
+		return number(-n, isInteger(v)), nil
+	}
+	return v, nil
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e binaryExpr) eval(b graph.Binding) (rdf.Term, error) {
+	switch e.op {
+	case "||", "&&":
+		// errors only matter if the other side does not decide the result
+		l, lerr := e.bool(e.left, b)
+		r, rerr := e.bool(e.right, b)
+		if e.op == "||" {
+			if (lerr == nil && l) || (rerr == nil && r) {
+				return boolean(true), nil
+			}
+		} else if (lerr == nil && !l) || (rerr == nil && !r) {
+			return boolean(false), nil
+		}
+		if lerr != nil {
+			return nil, lerr
+		}
+		if rerr != nil {
+			return nil, rerr
+		}
+		return boolean(e.op == "&&"), nil
+	}
+	l, err := e.left.eval(b)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.right.eval(b)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		c, err := compareValues(l, r, e.op == "=" || e.op == "!=")
+		if err != nil {
+			return nil, err
+		}
+		switch e.op {
+		case "=":
+			return boolean(c == 0), nil
+		case "!=":
+			return boolean(c != 0), nil
+		case "<":
+			return boolean(c < 0), nil
+		case "<=":
+			return boolean(c <= 0), nil
+		case ">":
+			return boolean(c > 0), nil
+		}
+		return boolean(c >= 0), nil
+	}
+	x, xok := numericValue(l)
+	y, yok := numericValue(r)
+	if !xok || !yok {
+		return nil, errType
+	}
+	integers := isInteger(l) && isInteger(r)
+	switch e.op {
+	case "+":
+		return number(x+y, integers), nil
+	case "-":
+		return number(x-y, integers), nil
+	case "*":
+		return number(x*y, integers), nil
+	case "/":
+		if y == 0 {
+			return nil, errType
+		}
+		return number(x/y, false), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", e.op)
+}
+
+func (e binaryExpr) bool(arg expr, b graph.Binding) (bool, error) {
+	v, err := arg.eval(b)
+	if err != nil {
+		return false, err
+	}
+	return effectiveBoolean(v)
+}
+
+type callExpr struct {
+	name string
+	args []expr
+	re   *regexp.Regexp
+}
+
+func (c callExpr) eval(b graph.Binding) (rdf.Term, error) {
+	if c.name == "BOUND" {
+		_, ok := b[string(c.args[0].(varExpr))]
+		return boolean(ok), nil
+	}
+	args := make([]rdf.Term, len(c.args))
+	for i, a := range c.args {
+		v, err := a.eval(b)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	switch c.name {
+	case "STR":
+		return rdf.NewLiteral(args[0].String())
+	case "LANG":
+		l, ok := args[0].(rdf.Literal)
+		if !ok {
+			return nil, errType
+		}
+		return rdf.NewLiteral(l.Lang())
+	case "DATATYPE":
+		l, ok := args[0].(rdf.Literal)
+		if !ok {
+			return nil, errType
+		}
+		return l.DataType, nil
+	case "ISIRI", "ISURI":
+		return boolean(args[0].Type() == rdf.TermIRI), nil
+	case "ISLITERAL":
+		return boolean(args[0].Type() == rdf.TermLiteral), nil
+	case "ISBLANK":
+		return boolean(args[0].Type() == rdf.TermBlank), nil
+	case "ISNUMERIC":
+		_, ok := numericValue(args[0])
+		return boolean(ok), nil
+	case "SAMETERM":
+		return boolean(graph.Key(args[0]) == graph.Key(args[1])), nil
+	case "STRLEN":
+		return rdf.NewLiteral(len([]rune(args[0].String())))
+	case "UCASE":
+		return rdf.NewLiteral(strings.ToUpper(args[0].String()))
+	case "LCASE":
+		return rdf.NewLiteral(strings.ToLower(args[0].String()))
+	case "CONTAINS":
+		return boolean(strings.Contains(args[0].String(), args[1].String())), nil
+	case "STRSTARTS":
+		return boolean(strings.HasPrefix(args[0].String(), args[1].String())), nil
+	case "STRENDS":
+		return boolean(strings.HasSuffix(args[0].String(), args[1].String())), nil
+	case "ABS":
+		n, ok := numericValue(args[0])
+		if !ok {
+			return nil, errType
+		}
+		return number(math.Abs(n), isInteger(args[0])), nil
+	case "REGEX":
+		re := c.re
+		if re == nil {
+			var err error
+			if re, err = compileRegex(args[1].String(), flags(args)); err != nil {
+				return nil, err
+			}
+		}
+		return boolean(re.MatchString(args[0].String())), nil
+	}
+	return nil, fmt.Errorf("unknown function %s", c.name)
+}
+
+func flags(args []rdf.Term) string {
+	if len(args) > 2 {
+		return args[2].String()
+	}
+	return ""
+}
+
+func compileRegex(pattern string, flags string) (*regexp.Regexp, error) {
+	if strings.Contains(flags, "i") {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// arity of the supported functions, -1 for REGEX which takes 2 or 3
+var functions = map[string]int{
+	"BOUND": 1, "STR": 1, "LANG": 1, "DATATYPE": 1,
+	"ISIRI": 1, "ISURI": 1, "ISLITERAL": 1, "ISBLANK": 1, "ISNUMERIC": 1,
+	"SAMETERM": 2, "STRLEN": 1, "UCASE": 1, "LCASE": 1,
+	"CONTAINS": 2, "STRSTARTS": 2, "STRENDS": 2, "ABS": 1, "REGEX": -1,
+}
+
+// constraint parses the expression following FILTER.
+func (p *parser) constraint() (expr, error) {
+	if p.peek().typ == tokWord {
+		return p.primary()
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return e, p.expect(")")
+}
+
+func (p *parser) expr() (expr, error) {
+	return p.binary([][]string{{"||"}, {"&&"}, {"=", "!=", "<", "<=", ">", ">="}, {"+", "-"}, {"*", "/"}})
+}
+
+// binary parses left associative operators, levels go from the loosest
+// binding operators to the tightest.
+func (p *parser) binary(levels [][]string) (expr, error) {
+	if len(levels) == 0 {
+		return p.unary()
+	}
+	left, err := p.binary(levels[1:])
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range levels[0] {
+			if p.peek().typ == tokPunct && p.peek().text == o {
+				op = o
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		p.next()
+		right, err := p.binary(levels[1:])
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	for _, op := range []string{"!", "-", "+"} {
+		if p.peek().typ == tokPunct && p.peek().text == op {
+			p.next()
+			arg, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return unaryExpr{op: op, arg: arg}, nil
+		}
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch {
+	case t.is("("):
+		p.next()
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case t.typ == tokVar:
+		p.next()
+		return varExpr(t.text), nil
+	case t.typ == tokWord && !t.is("true") && !t.is("false"):
+		p.next()
+		name := strings.ToUpper(t.text)
+		arity, ok := functions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s", t)
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		call := callExpr{name: name}
+		for !p.accept(")") {
+			if len(call.args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		if (arity >= 0 && len(call.args) != arity) || (arity < 0 && (len(call.args) < 2 || len(call.args) > 3)) {
+			return nil, fmt.Errorf("wrong number of arguments to %s", name)
+		}
+		if _, ok := call.args[0].(varExpr); name == "BOUND" && !ok {
+			return nil, fmt.Errorf("BOUND takes a variable")
+		}
+		if name == "REGEX" {
+			// compile constant patterns once
+			pattern, pok := call.args[1].(constExpr)
+			flagArg := constExpr{}
+			fok := len(call.args) == 2
+			if !fok {
+				flagArg, fok = call.args[2].(constExpr)
+			}
+			if pok && fok {
+				f := ""
+				if flagArg.term != nil {
+					f = flagArg.term.String()
+				}
+				re, err := compileRegex(pattern.term.String(), f)
+				if err != nil {
+					return nil, err
+				}
+				call.re = re
+			}
+		}
+		return call, nil
+	}
+	term, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	return constExpr{term}, nil
+}
+
+// effectiveBoolean is the truth value of a term in a filter.
+func effectiveBoolean(t rdf.Term) (bool, error) {
+	l, ok := t.(rdf.Literal)
+	if !ok {
+		return false, errType
+	}
+	switch l.DataType.String() {
+	case xsdNS + "boolean":
+		return l.String() == "true" || l.String() == "1", nil
+	case xsdNS + "string", "":
+		return l.String() != "", nil
+	}
+	if n, ok := numericValue(l); ok {
+		return n != 0 && !math.IsNaN(n), nil
+	}
+	if l.Lang() != "" {
+		return l.String() != "", nil
+	}
+	return false, errType
+}
+
+func numericValue(t rdf.Term) (float64, bool) {
+	l, ok := t.(rdf.Literal)
+	if !ok {
+		return 0, false
+	}
+	switch strings.TrimPrefix(l.DataType.String(), xsdNS) {
+	case "integer", "int", "long", "short", "decimal", "double", "float",
+		"nonNegativeInteger", "positiveInteger", "negativeInteger", "nonPositiveInteger":
+		f, err := strconv.ParseFloat(l.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func isInteger(t rdf.Term) bool {
+	l, ok := t.(rdf.Literal)
+	return ok && strings.HasSuffix(l.DataType.String(), "#integer")
+}
+
+func number(f float64, integer bool) rdf.Term {
+	if integer && f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		l, _ := rdf.NewLiteral(int(f))
+		return l
+	}
+	l, _ := rdf.NewLiteral(f)
+	return l
+}
+
+func boolean(v bool) rdf.Term {
+	l, _ := rdf.NewLiteral(v)
+	return l
+}
+
+// compareValues orders two terms, numbers by value and strings, booleans and
+// date times by their text. Other terms can only be tested for equality.
+func compareValues(a rdf.Term, b rdf.Term, equality bool) (int, error) {
+	if x, ok := numericValue(a); ok {
+		if y, ok := numericValue(b); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	la, aLit := a.(rdf.Literal)
+	lb, bLit := b.(rdf.Literal)
+	if aLit && bLit && la.DataType == lb.DataType && la.Lang() == lb.Lang() {
+		return strings.Compare(la.String(), lb.String()), nil
+	}
+	if equality {
+		if graph.Key(a) == graph.Key(b) {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	return 0, errType
+}
@@ -0,0 +1,232 @@
+package sparql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokIRI
+	tokPName
+	tokVar
+	tokBlank
+	tokString
+	tokInteger
+	tokDecimal
+	tokDouble
+	tokLang
+	tokWord
+	tokPunct
+)
+
+type token struct {
+	typ  tokenType
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.typ == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q at %d", t.text, t.pos)
+}
+
+// is reports whether the token is the given punctuation or, ignoring case,
+// the given keyword.
+func (t token) is(s string) bool {
+	switch t.typ {
+	case tokPunct:
+		return t.text == s
+	case tokWord:
+		return strings.EqualFold(t.text, s)
+	}
+	return false
+}
+
+// two character operators, checked before single characters
+var operators = []string{"&&", "||", "!=", "<=", ">=", "^^"}
+
+func lex(q string) ([]token, error) {
+	toks := []token{}
+	r := []rune(q)
+	i := 0
+	for i < len(r) {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#':
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+		case c == '<':
+			if end, ok := scanIRI(r, i); ok {
+				toks = append(toks, token{tokIRI, string(r[i+1 : end]), i})
+				i = end + 1
+				continue
+			}
+			if i+1 < len(r) && r[i+1] == '=' {
+				toks = append(toks, token{tokPunct, "<=", i})
+				i += 2
+				continue
+			}
+			toks = append(toks, token{tokPunct, "<", i})
+			i++
+		case c == '?' || c == '$':
+			start := i
+			i++
+			for i < len(r) && (unicode.IsLetter(r[i]) || unicode.IsDigit(r[i]) || r[i] == '_') {
+				i++
+			}
+			if i == start+1 {
+				return nil, fmt.Errorf("empty variable name at %d", start)
+			}
+			toks = append(toks, token{tokVar, string(r[start+1 : i]), start})
+		case c == '"' || c == '\'':
+			start := i
+			s, end, err := scanString(r, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{tokString, s, start})
+			i = end
+		case c == '@' && len(toks) > 0 && toks[len(toks)-1].typ == tokString:
+			start := i
+			i++
+			for i < len(r) && (unicode.IsLetter(r[i]) || unicode.IsDigit(r[i]) || r[i] == '-') {
+				i++
+			}
+			toks = append(toks, token{tokLang, string(r[start+1 : i]), start})
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(r) && unicode.IsDigit(r[i+1])):
+			start := i
+			typ := tokInteger
+			for i < len(r) && unicode.IsDigit(r[i]) {
+				i++
+			}
+			if i+1 < len(r) && r[i] == '.' && unicode.IsDigit(r[i+1]) {
+				typ = tokDecimal
+				i++
+				for i < len(r) && unicode.IsDigit(r[i]) {
+					i++
+				}
+			}
+			if i < len(r) && (r[i] == 'e' || r[i] == 'E') {
+				typ = tokDouble
+				i++
+				if i < len(r) && (r[i] == '+' || r[i] == '-') {
+					i++
+				}
+				for i < len(r) && unicode.IsDigit(r[i]) {
+					i++
+				}
+			}
+			toks = append(toks, token{typ, string(r[start:i]), start})
+		case c == '_' && i+1 < len(r) && r[i+1] == ':':
+			start := i
+			i += 2
+			for i < len(r) && isNameChar(r[i]) {
+				i++
+			}
+			toks = append(toks, token{tokBlank, string(r[start:i]), start})
+		case unicode.IsLetter(c) || c == ':':
+			start := i
+			for i < len(r) && isNameChar(r[i]) {
+				i++
+			}
+			if i < len(r) && r[i] == ':' {
+				// prefixed name, the local part may be empty
+				i++
+				for i < len(r) && (isNameChar(r[i]) || r[i] == '/' || r[i] == '.' || r[i] == '%') {
+					i++
+				}
+				// a trailing dot ends the triple
+				for r[i-1] == '.' {
+					i--
+				}
+				toks = append(toks, token{tokPName, string(r[start:i]), start})
+				continue
+			}
+			toks = append(toks, token{tokWord, string(r[start:i]), start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(r[i:min(i+2, len(r))]), op) {
+					toks = append(toks, token{tokPunct, op, i})
+					i += 2
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+			if !strings.ContainsRune("{}().,;*=!><+-/[]", c) {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			toks = append(toks, token{tokPunct, string(c), i})
+			i++
+		}
+	}
+	return append(toks, token{typ: tokEOF, pos: len(r)}), nil
+}
+
+func isNameChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '-'
+}
+
+// scanIRI returns the index of the closing > of an IRI starting at i, an IRI
+// cannot contain white space which tells it apart from a less than.
+func scanIRI(r []rune, i int) (int, bool) {
+	for j := i + 1; j < len(r); j++ {
+		switch {
+		case r[j] == '>':
+			return j, true
+		case unicode.IsSpace(r[j]) || strings.ContainsRune("<\"{}|^`\\", r[j]):
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+func scanString(r []rune, i int) (string, int, error) {
+	quote := r[i]
+	b := strings.Builder{}
+	for j := i + 1; j < len(r); j++ {
+		switch r[j] {
+		case quote:
+			return b.String(), j + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("unterminated string at %d", i)
+		case '\\':
+			j++
+			if j == len(r) {
+				break
+			}
+			switch r[j] {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case 'r':
+				b.WriteRune('\r')
+			default:
+				b.WriteRune(r[j])
+			}
+		default:
+			b.WriteRune(r[j])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at %d", i)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,392 @@
+package sparql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/knakk/rdf"
+)
+
+type parser struct {
+	toks     []token
+	pos      int
+	prefixes map[string]string
+	base     string
+	blanks   int
+}
+
+// Parse parses a query.
+func Parse(query string) (*Query, error) {
+	toks, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, prefixes: map[string]string{}}
+	for k, v := range DefaultPrefixes {
+		p.prefixes[k] = v
+	}
+	q, err := p.query()
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return fmt.Errorf("expected %s, found %s", s, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) query() (*Query, error) {
+	for {
+		switch {
+		case p.accept("PREFIX"):
+			name := p.next()
+			if name.typ != tokPName || !strings.HasSuffix(name.text, ":") {
+				return nil, fmt.Errorf("expected prefix name, found %s", name)
+			}
+			iri := p.next()
+			if iri.typ != tokIRI {
+				return nil, fmt.Errorf("expected IRI for prefix %s, found %s", name.text, iri)
+			}
+			p.prefixes[strings.TrimSuffix(name.text, ":")] = p.resolve(iri.text)
+			continue
+		case p.accept("BASE"):
+			iri := p.next()
+			if iri.typ != tokIRI {
+				return nil, fmt.Errorf("expected base IRI, found %s", iri)
+			}
+			p.base = iri.text
+			continue
+		}
+		break
+	}
+
+	q := &Query{Limit: -1}
+	var err error
+	switch {
+	case p.accept(Select):
+		q.Form = Select
+		if p.accept("DISTINCT") {
+			q.Distinct = true
+		} else {
+			p.accept("REDUCED")
+		}
+		if !p.accept("*") {
+			for p.peek().typ == tokVar {
+				q.Vars = append(q.Vars, p.next().text)
+			}
+			if len(q.Vars) == 0 {
+				return nil, fmt.Errorf("expected variables or * after SELECT, found %s", p.peek())
+			}
+		}
+		p.accept("WHERE")
+		if q.Where, err = p.group(); err != nil {
+			return nil, err
+		}
+	case p.accept(Ask):
+		q.Form = Ask
+		p.accept("WHERE")
+		if q.Where, err = p.group(); err != nil {
+			return nil, err
+		}
+	case p.accept(Construct):
+		q.Form = Construct
+		if p.accept("WHERE") {
+			// CONSTRUCT WHERE { bgp } uses the pattern as its own template
+			if q.Where, err = p.group(); err != nil {
+				return nil, err
+			}
+			for _, e := range q.Where.elements {
+				if e.bgp == nil || len(q.Where.filters) > 0 {
+					return nil, fmt.Errorf("CONSTRUCT WHERE only takes triple patterns")
+				}
+				q.Template = append(q.Template, e.bgp...)
+			}
+			break
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		for !p.accept("}") {
+			if p.accept(".") {
+				continue
+			}
+			triples, err := p.triples()
+			if err != nil {
+				return nil, err
+			}
+			q.Template = append(q.Template, triples...)
+		}
+		p.accept("WHERE")
+		if q.Where, err = p.group(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("expected SELECT, ASK or CONSTRUCT, found %s", p.peek())
+	}
+
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+	order:
+		for {
+			cond := orderCondition{}
+			switch t := p.peek(); {
+			case t.typ == tokVar:
+				cond.expr = varExpr(p.next().text)
+			case t.is("ASC") || t.is("DESC") || t.is("("):
+				cond.descending = p.next().is("DESC")
+				if !t.is("(") {
+					if err := p.expect("("); err != nil {
+						return nil, err
+					}
+				}
+				if cond.expr, err = p.expr(); err != nil {
+					return nil, err
+				}
+				if err := p.expect(")"); err != nil {
+					return nil, err
+				}
+			default:
+				if len(q.Order) == 0 {
+					return nil, fmt.Errorf("expected order condition, found %s", t)
+				}
+				break order
+			}
+			q.Order = append(q.Order, cond)
+		}
+	}
+	for {
+		switch {
+		case p.accept("LIMIT"):
+			if q.Limit, err = p.integer(); err != nil {
+				return nil, err
+			}
+			continue
+		case p.accept("OFFSET"):
+			if q.Offset, err = p.integer(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		break
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+	return q, nil
+}
+
+func (p *parser) integer() (int, error) {
+	t := p.next()
+	if t.typ != tokInteger {
+		return 0, fmt.Errorf("expected integer, found %s", t)
+	}
+	return strconv.Atoi(t.text)
+}
+
+func (p *parser) group() (*group, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	g := &group{}
+	for !p.accept("}") {
+		switch t := p.peek(); {
+		case t.typ == tokEOF:
+			return nil, fmt.Errorf("unterminated group")
+		case p.accept("."):
+		case p.accept("OPTIONAL"):
+			opt, err := p.group()
+			if err != nil {
+				return nil, err
+			}
+			g.elements = append(g.elements, element{optional: opt})
+		case p.accept("FILTER"):
+			e, err := p.constraint()
+			if err != nil {
+				return nil, err
+			}
+			g.filters = append(g.filters, e)
+		case t.is("{"):
+			inner, err := p.group()
+			if err != nil {
+				return nil, err
+			}
+			union := []*group{inner}
+			for p.accept("UNION") {
+				alt, err := p.group()
+				if err != nil {
+					return nil, err
+				}
+				union = append(union, alt)
+			}
+			g.elements = append(g.elements, element{union: union})
+		default:
+			triples, err := p.triples()
+			if err != nil {
+				return nil, err
+			}
+			// consecutive triples form one basic graph pattern
+			if n := len(g.elements); n > 0 && g.elements[n-1].bgp != nil {
+				g.elements[n-1].bgp = append(g.elements[n-1].bgp, triples...)
+			} else {
+				g.elements = append(g.elements, element{bgp: triples})
+			}
+		}
+	}
+	return g, nil
+}
+
+// triples parses a subject with its predicate object lists.
+func (p *parser) triples() ([]graph.Pattern, error) {
+	subj, err := p.node(false)
+	if err != nil {
+		return nil, err
+	}
+	patterns := []graph.Pattern{}
+	for {
+		pred, err := p.node(true)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			obj, err := p.node(false)
+			if err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, graph.Pattern{Subj: subj, Pred: pred, Obj: obj})
+			if !p.accept(",") {
+				break
+			}
+		}
+		if !p.accept(";") {
+			break
+		}
+		// a trailing ; is allowed
+		if t := p.peek(); t.is(".") || t.is("}") {
+			break
+		}
+	}
+	return patterns, nil
+}
+
+func (p *parser) node(predicate bool) (graph.Node, error) {
+	t := p.next()
+	switch t.typ {
+	case tokVar:
+		return graph.Variable(t.text), nil
+	case tokBlank:
+		// blank nodes in patterns behave as variables that are never projected
+		return graph.Variable(t.text), nil
+	case tokWord:
+		if predicate && t.text == "a" {
+			return graph.Constant(graph.IRI(rdfNS + "type")), nil
+		}
+	case tokPunct:
+		if t.text == "[" && p.accept("]") {
+			p.blanks++
+			return graph.Variable(fmt.Sprintf("_:b%d", p.blanks)), nil
+		}
+	}
+	p.pos--
+	term, err := p.term()
+	if err != nil {
+		return graph.Node{}, err
+	}
+	if predicate && term.Type() != rdf.TermIRI {
+		return graph.Node{}, fmt.Errorf("predicate must be an IRI, found %s", t)
+	}
+	return graph.Constant(term), nil
+}
+
+// term parses an IRI, prefixed name or literal.
+func (p *parser) term() (rdf.Term, error) {
+	t := p.next()
+	switch t.typ {
+	case tokIRI:
+		return rdf.NewIRI(p.resolve(t.text))
+	case tokPName:
+		return p.pname(t)
+	case tokString:
+		switch next := p.peek(); {
+		case next.typ == tokLang:
+			p.next()
+			return rdf.NewLangLiteral(t.text, next.text)
+		case next.is("^^"):
+			p.next()
+			dt, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			iri, ok := dt.(rdf.IRI)
+			if !ok {
+				return nil, fmt.Errorf("datatype must be an IRI, found %s", dt)
+			}
+			return rdf.NewTypedLiteral(t.text, iri), nil
+		}
+		return rdf.NewLiteral(t.text)
+	case tokInteger:
+		return rdf.NewTypedLiteral(t.text, graph.IRI(xsdNS+"integer")), nil
+	case tokDecimal:
+		return rdf.NewTypedLiteral(t.text, graph.IRI(xsdNS+"decimal")), nil
+	case tokDouble:
+		return rdf.NewTypedLiteral(t.text, graph.IRI(xsdNS+"double")), nil
+	case tokWord:
+		if t.is("true") || t.is("false") {
+			return rdf.NewLiteral(t.is("true"))
+		}
+	case tokPunct:
+		// signed numbers
+		if (t.text == "-" || t.text == "+") && p.peek().typ >= tokInteger && p.peek().typ <= tokDouble {
+			n, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			l := n.(rdf.Literal)
+			return rdf.NewTypedLiteral(strings.TrimPrefix(t.text, "+")+l.String(), l.DataType), nil
+		}
+	}
+	return nil, fmt.Errorf("expected term, found %s", t)
+}
+
+func (p *parser) pname(t token) (rdf.Term, error) {
+	i := strings.Index(t.text, ":")
+	ns, ok := p.prefixes[t.text[:i]]
+	if !ok {
+		return nil, fmt.Errorf("undeclared prefix %s in %s", t.text[:i], t)
+	}
+	return rdf.NewIRI(ns + t.text[i+1:])
+}
+
+func (p *parser) resolve(iri string) string {
+	if p.base != "" && !strings.Contains(iri, ":") {
+		return p.base + iri
+	}
+	return iri
+}
@@ -0,0 +1,93 @@
+package sparql
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+)
+
+// Media types of the supported result formats.
+const (
+	JSONType     = "application/sparql-results+json"
+	CSVType      = "text/csv"
+	NTriplesType = "application/n-triples"
+	queryType    = "application/sparql-query"
+)
+
+// Handler answers queries over HTTP following the SPARQL 1.1 Protocol. The
+// query comes from the query parameter of a GET or form POST, or is the body
+// of a POST with content type application/sparql-query. Results are JSON
+// unless text/csv is accepted or asked for with format=csv. The graph is
+// fetched for every request so it can be replaced while serving.
+func Handler(current func() *graph.Graph) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var text string
+		switch r.Method {
+		case http.MethodGet:
+			text = r.URL.Query().Get("query")
+		case http.MethodPost:
+			ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if ct == queryType {
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				text = string(b)
+			} else {
+				text = r.FormValue("query")
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if strings.TrimSpace(text) == "" {
+			http.Error(w, "missing query", http.StatusBadRequest)
+			return
+		}
+		q, err := Parse(text)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res := q.Exec(current())
+		switch {
+		case res.Form == Construct:
+			w.Header().Set("Content-Type", NTriplesType)
+			err = res.WriteNTriples(w)
+		case wantsCSV(r):
+			w.Header().Set("Content-Type", CSVType+"; charset=utf-8")
+			err = res.WriteCSV(w)
+		default:
+			w.Header().Set("Content-Type", JSONType)
+			err = res.WriteJSON(w)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func wantsCSV(r *http.Request) bool {
+	switch strings.ToLower(r.FormValue("format")) {
+	case "csv", CSVType:
+		return true
+	case "json", JSONType:
+		return false
+	}
+	// the first of the two types listed wins, anything else gets JSON
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, _ := mime.ParseMediaType(strings.TrimSpace(part))
+		switch mt {
+		case CSVType:
+			return true
+		case JSONType, "application/json":
+			return false
+		}
+	}
+	return false
+}
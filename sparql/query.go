@@ -0,0 +1,90 @@
+// Package sparql runs a subset of SPARQL 1.1 against an in-memory graph.
+//
+// SELECT, ASK and CONSTRUCT queries are supported with basic graph patterns,
+// FILTER, OPTIONAL, UNION, DISTINCT, ORDER BY, LIMIT and OFFSET. Aggregates,
+// property paths, sub queries and named graphs are not.
+//
+// Besides rdf, rdfs and xsd the prefixes dooodle, entity, pred and dim are
+// predeclared for the extractor vocabulary, so a query can be as short as
+//
+//	SELECT ?e WHERE { ?e pred:hasCompoundKey ?k }
+package sparql
+
+import (
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/vocab"
+	"github.com/knakk/rdf"
+)
+
+// Query forms.
+const (
+	Select    = "SELECT"
+	Ask       = "ASK"
+	Construct = "CONSTRUCT"
+)
+
+const (
+	rdfNS  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rdfsNS = "http://www.w3.org/2000/01/rdf-schema#"
+	xsdNS  = "http://www.w3.org/2001/XMLSchema#"
+)
+
+// DefaultPrefixes are declared in every query.
+var DefaultPrefixes = map[string]string{
+	"rdf":     rdfNS,
+	"rdfs":    rdfsNS,
+	"xsd":     xsdNS,
+	"dooodle": vocab.Root,
+	"entity":  vocab.EntityPrefix,
+	"pred":    vocab.PredicatePrefix,
+	"dim":     vocab.Root + "dimension/",
+}
+
+// Query is a parsed query.
+type Query struct {
+	Form     string
+	Vars     []string
+	Distinct bool
+	Template []graph.Pattern
+	Where    *group
+	Order    []orderCondition
+	Limit    int
+	Offset   int
+}
+
+// group is a group graph pattern, filters apply to the whole group.
+type group struct {
+	elements []element
+	filters  []expr
+}
+
+// element is one of a basic graph pattern, an optional group, a union of
+// groups or a nested group.
+type element struct {
+	bgp      []graph.Pattern
+	optional *group
+	union    []*group
+}
+
+type orderCondition struct {
+	expr       expr
+	descending bool
+}
+
+// Result holds the answer to a query, which fields are set depends on Form.
+type Result struct {
+	Form     string
+	Vars     []string
+	Bindings []graph.Binding
+	Boolean  bool
+	Triples  []rdf.Triple
+}
+
+// Exec parses and runs a query against g.
+func Exec(g *graph.Graph, query string) (*Result, error) {
+	q, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Exec(g), nil
+}
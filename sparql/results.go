@@ -0,0 +1,134 @@
+package sparql
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/knakk/rdf"
+)
+
+// WriteTable writes the result for reading in a terminal, bindings as tab
+// separated rows of N-Triples terms, ASK as true or false and CONSTRUCT as
+// N-Triples.
+func (r *Result) WriteTable(w io.Writer) error {
+	switch r.Form {
+	case Ask:
+		_, err := fmt.Fprintln(w, r.Boolean)
+		return err
+	case Construct:
+		return r.WriteNTriples(w)
+	}
+	if _, err := fmt.Fprintln(w, "?"+strings.Join(r.Vars, "\t?")); err != nil {
+		return err
+	}
+	for _, b := range r.Bindings {
+		row := make([]string, len(r.Vars))
+		for i, v := range r.Vars {
+			if t, ok := b[v]; ok {
+				row[i] = t.Serialize(rdf.NTriples)
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteNTriples writes the triples of a CONSTRUCT query.
+func (r *Result) WriteNTriples(w io.Writer) error {
+	for _, t := range r.Triples {
+		if _, err := fmt.Fprint(w, t.Serialize(rdf.NTriples)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonTerm struct {
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Lang     string `json:"xml:lang,omitempty"`
+	DataType string `json:"datatype,omitempty"`
+}
+
+type jsonResult struct {
+	Head struct {
+		Vars []string `json:"vars,omitempty"`
+	} `json:"head"`
+	Results *struct {
+		Bindings []map[string]jsonTerm `json:"bindings"`
+	} `json:"results,omitempty"`
+	Boolean *bool `json:"boolean,omitempty"`
+}
+
+// WriteJSON writes a SELECT or ASK result in the SPARQL 1.1 query results
+// JSON format.
+func (r *Result) WriteJSON(w io.Writer) error {
+	out := jsonResult{}
+	if r.Form == Ask {
+		out.Boolean = &r.Boolean
+	} else {
+		out.Head.Vars = r.Vars
+		out.Results = &struct {
+			Bindings []map[string]jsonTerm `json:"bindings"`
+		}{Bindings: []map[string]jsonTerm{}}
+		for _, b := range r.Bindings {
+			row := map[string]jsonTerm{}
+			for v, t := range b {
+				row[v] = toJSONTerm(t)
+			}
+			out.Results.Bindings = append(out.Results.Bindings, row)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func toJSONTerm(t rdf.Term) jsonTerm {
+	switch t.Type() {
+	case rdf.TermIRI:
+		return jsonTerm{Type: "uri", Value: t.String()}
+	case rdf.TermBlank:
+		return jsonTerm{Type: "bnode", Value: t.String()}
+	}
+	l := t.(rdf.Literal)
+	j := jsonTerm{Type: "literal", Value: l.String(), Lang: l.Lang()}
+	if dt := l.DataType.String(); j.Lang == "" && dt != xsdNS+"string" {
+		j.DataType = dt
+	}
+	return j
+}
+
+// WriteCSV writes a SELECT result in the SPARQL 1.1 CSV format, an ASK result
+// is written as a single boolean column.
+func (r *Result) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	// the format asks for CRLF line endings
+	cw.UseCRLF = true
+	if r.Form == Ask {
+		cw.Write([]string{"boolean"})
+		cw.Write([]string{fmt.Sprint(r.Boolean)})
+		cw.Flush()
+		return cw.Error()
+	}
+	cw.Write(r.Vars)
+	for _, b := range r.Bindings {
+		row := make([]string, len(r.Vars))
+		for i, v := range r.Vars {
+			if t, ok := b[v]; ok {
+				row[i] = t.String()
+				if t.Type() == rdf.TermBlank {
+					row[i] = "_:" + t.String()
+				}
+			}
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
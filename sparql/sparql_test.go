@@ -0,0 +1,171 @@
+package sparql
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+)
+
+const mondial = `<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/name> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/country> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/city/column/population> .
+<http://dooodle/entity/city> <http://dooodle/predicate/hasCompoundKey> <http://dooodle/entity/city/compound/country/name> .
+<http://dooodle/entity/city/column/population> <http://dooodle/predicate/hasDimension> <http://dooodle/dimension/scalar> .
+<http://dooodle/entity/city/column/population> <http://dooodle/predicate/numDistinct> "2900"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://dooodle/entity/city/column/name> <http://dooodle/predicate/numDistinct> "3000"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://dooodle/entity/city/column/country> <http://dooodle/predicate/numDistinct> "240"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://dooodle/entity/country> <http://dooodle/predicate/hasColumn> <http://dooodle/entity/country/column/code> .
+<http://dooodle/entity/country/column/code> <http://dooodle/predicate/numDistinct> "240"^^<http://www.w3.org/2001/XMLSchema#integer> .
+`
+
+func load(t *testing.T) *graph.Graph {
+	g, err := graph.Load(strings.NewReader(mondial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestSelect(t *testing.T) {
+	g := load(t)
+	r, err := Exec(g, `SELECT DISTINCT ?e WHERE {
+		?e pred:hasCompoundKey ?k ; pred:hasColumn ?c .
+		?c pred:hasDimension dim:scalar .
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Bindings) != 1 || r.Bindings[0]["e"].String() != "http://dooodle/entity/city" {
+		t.Errorf("wanted city got %v", r.Bindings)
+	}
+
+	r, err = Exec(g, `SELECT ?c ?n WHERE { ?e pred:hasColumn ?c . ?c pred:numDistinct ?n FILTER (?n >= 240 && ?n < 3000) } ORDER BY DESC(?n) ?c LIMIT 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, b := range r.Bindings {
+		got = append(got, b["c"].String())
+	}
+	want := "http://dooodle/entity/city/column/population http://dooodle/entity/city/column/country"
+	if strings.Join(got, " ") != want {
+		t.Errorf("wanted %s got %v", want, got)
+	}
+}
+
+func TestOptional(t *testing.T) {
+	g := load(t)
+	r, err := Exec(g, `SELECT * WHERE {
+		entity:city pred:hasColumn ?c
+		OPTIONAL { ?c pred:hasDimension ?d }
+		FILTER (!BOUND(?d))
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Vars) != 2 || len(r.Bindings) != 2 {
+		t.Errorf("wanted the two columns without a dimension got %v %v", r.Vars, r.Bindings)
+	}
+	for _, b := range r.Bindings {
+		if !strings.HasPrefix(b["c"].String(), "http://dooodle/entity/city/column/") {
+			t.Errorf("unexpected column %v", b["c"])
+		}
+	}
+}
+
+func TestAskAndConstruct(t *testing.T) {
+	g := load(t)
+	r, err := Exec(g, `ASK { ?e pred:hasColumn ?c FILTER regex(str(?c), "/code$") }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Boolean {
+		t.Error("wanted a code column")
+	}
+
+	r, err = Exec(g, `PREFIX ex: <http://example.org/>
+	CONSTRUCT { ?e ex:measure ?c } WHERE { ?e pred:hasColumn ?c . ?c pred:hasDimension dim:scalar }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	r.WriteNTriples(buf)
+	want := "<http://dooodle/entity/city> <http://example.org/measure> <http://dooodle/entity/city/column/population> .\n"
+	if buf.String() != want {
+		t.Errorf("wanted %q got %q", want, buf.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	g := load(t)
+	r, err := Exec(g, `SELECT ?n WHERE { entity:country pred:hasColumn ?c . ?c pred:numDistinct ?n }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := r.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"datatype": "http://www.w3.org/2001/XMLSchema#integer"`) ||
+		!strings.Contains(buf.String(), `"value": "240"`) {
+		t.Errorf("unexpected JSON %s", buf.String())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, q := range []string{
+		`SELECT WHERE { ?s ?p ?o }`,
+		`SELECT ?s WHERE { ?s unknown:p ?o }`,
+		`SELECT ?s WHERE { ?s ?p ?o FILTER (nosuch(?o)) }`,
+		`DESCRIBE ?s`,
+	} {
+		if _, err := Parse(q); err == nil {
+			t.Errorf("wanted an error for %s", q)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	g := load(t)
+	srv := httptest.NewServer(Handler(func() *graph.Graph { return g }))
+	defer srv.Close()
+	query := `SELECT ?c WHERE { entity:country pred:hasColumn ?c }`
+
+	resp, err := http.Get(srv.URL + "?query=" + url.QueryEscape(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Type") != JSONType || !strings.Contains(string(body), `"type": "uri"`) {
+		t.Errorf("unexpected JSON response %s %s", resp.Header.Get("Content-Type"), body)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(query))
+	req.Header.Set("Content-Type", "application/sparql-query")
+	req.Header.Set("Accept", "text/csv")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := "c\r\nhttp://dooodle/entity/country/column/code\r\n"; string(body) != want {
+		t.Errorf("wanted CSV %q got %q", want, body)
+	}
+
+	resp, err = http.PostForm(srv.URL, url.Values{"query": {"SELECT"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("wanted a bad request for a broken query got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,246 @@
+// Package vegalite renders recommendations as Vega-Lite specifications.
+//
+// Field types follow the dimension classification of the extraction:
+// discrete columns are nominal, or ordinal when their data type is ordered,
+// scalar columns are quantitative and temporal columns are temporal.
+package vegalite
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/recommend"
+	"github.com/dooodle/vis-extractor/vocab"
+)
+
+// Schema is the Vega-Lite version the specifications are written against.
+const Schema = "https://vega.github.io/schema/vega-lite/v4.json"
+
+// DefaultSQL selects the columns of a recommendation from its entity.
+const DefaultSQL = "select {columns} from {entity}"
+
+// DataSource says where a chart reads its rows from. URL and SQL are
+// templates in which {entity} is replaced by the entity name and {columns}
+// by the comma separated columns of the chart. A URL becomes the data url of
+// the specification, otherwise the data is named after the entity and the
+// rendered SQL is left in usermeta for the dashboard to run.
+type DataSource struct {
+	URL string
+	SQL string
+}
+
+// Spec is a single view Vega-Lite specification.
+type Spec struct {
+	Schema      string                 `json:"$schema"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Data        Data                   `json:"data"`
+	Mark        Mark                   `json:"mark"`
+	Encoding    map[string]FieldDef    `json:"encoding"`
+	UserMeta    map[string]interface{} `json:"usermeta,omitempty"`
+}
+
+// Data is either a url or a named data set provided at runtime.
+type Data struct {
+	URL  string `json:"url,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Mark is the mark type with tooltips switched on.
+type Mark struct {
+	Type    string `json:"type"`
+	Tooltip bool   `json:"tooltip,omitempty"`
+}
+
+// FieldDef is the encoding of one channel.
+type FieldDef struct {
+	Field     string      `json:"field,omitempty"`
+	Type      string      `json:"type"`
+	Aggregate string      `json:"aggregate,omitempty"`
+	Bin       bool        `json:"bin,omitempty"`
+	Sort      interface{} `json:"sort,omitempty"`
+	Scale     *Scale      `json:"scale,omitempty"`
+	Title     string      `json:"title,omitempty"`
+}
+
+// Scale overrides the default scale of a channel.
+type Scale struct {
+	Type string `json:"type"`
+}
+
+// ordered data types for which discrete values are drawn as ordinal
+var orderedTypes = []string{"int", "float", "numeric", "date", "time", "interval"}
+
+// Generate builds the specification of a recommendation, g is the graph the
+// recommendation was made from and supplies the field types.
+func Generate(g *graph.Graph, rec recommend.Recommendation, data DataSource) Spec {
+	field := func(channel string) FieldDef {
+		return fieldDef(g, rec.Entity, rec.Bindings[channel])
+	}
+	spec := Spec{
+		Schema:   Schema,
+		Title:    title(rec),
+		Mark:     Mark{Type: "bar", Tooltip: true},
+		Encoding: map[string]FieldDef{},
+	}
+	switch rec.Chart {
+	case "bar":
+		spec.Encoding["x"] = withSort(field("x"), "-y")
+		spec.Encoding["y"] = field("y")
+	case "line":
+		spec.Mark.Type = "line"
+		spec.Encoding["x"] = field("x")
+		spec.Encoding["y"] = field("y")
+		spec.Encoding["color"] = categorical(field("color"))
+	case "stackedBar":
+		spec.Encoding["x"] = field("x")
+		spec.Encoding["y"] = withAggregate(field("y"), "sum")
+		spec.Encoding["color"] = categorical(field("color"))
+	case "treemap":
+		// Vega-Lite has no treemap mark, stack the leaves within each group
+		spec.Description = "treemap drawn as stacked bars, Vega-Lite has no treemap mark"
+		spec.Encoding["x"] = withSort(field("group"), "-y")
+		spec.Encoding["color"] = categorical(field("leaf"))
+		spec.Encoding["y"] = FieldDef{Aggregate: "count", Type: "quantitative"}
+		if rec.Bindings["size"] != "" {
+			spec.Encoding["y"] = withAggregate(field("size"), "sum")
+		}
+	case "scatter":
+		spec.Mark.Type = "point"
+		spec.Encoding["x"] = field("x")
+		spec.Encoding["y"] = field("y")
+	case "histogram":
+		x := field("x")
+		x.Bin = true
+		x.Type = "quantitative"
+		x.Scale = nil
+		spec.Encoding["x"] = x
+		spec.Encoding["y"] = FieldDef{Aggregate: "count", Type: "quantitative"}
+	default:
+		spec.Description = "no layout for " + rec.Chart + " charts, showing the bound columns as bars"
+		for channel := range rec.Bindings {
+			spec.Encoding[channel] = field(channel)
+		}
+	}
+
+	columns := columns(rec)
+	render := strings.NewReplacer("{entity}", rec.Entity, "{columns}", strings.Join(columns, ", "))
+	if data.URL != "" {
+		spec.Data.URL = render.Replace(data.URL)
+	} else {
+		sql := data.SQL
+		if sql == "" {
+			sql = DefaultSQL
+		}
+		spec.Data.Name = rec.Entity
+		spec.UserMeta = map[string]interface{}{"query": render.Replace(sql)}
+	}
+	return spec
+}
+
+// fieldDef types a column from its dimension, scalar columns that are
+// heavily skewed get a log scale. The comment on a column titles its axis.
+func fieldDef(g *graph.Graph, entity string, col string) FieldDef {
+	iri := vocab.Column(entity, col)
+	def := FieldDef{Field: col, Type: "nominal"}
+	for _, c := range g.Objects(iri, vocab.Comment) {
+		def.Title = c.String()
+	}
+	for _, d := range g.Objects(iri, vocab.HasDimension) {
+		switch d.String() {
+		case vocab.ScalarDimension:
+			def.Type = "quantitative"
+		case vocab.TemporalDimension:
+			def.Type = "temporal"
+		case vocab.DiscreteDimension:
+			if ordered(g, iri) {
+				def.Type = "ordinal"
+			}
+		}
+	}
+	if def.Type == "quantitative" {
+		for _, l := range g.Objects(iri, vocab.LogScaleRecommended) {
+			if l.String() == "true" {
+				def.Scale = &Scale{Type: "log"}
+			}
+		}
+	}
+	return def
+}
+
+func ordered(g *graph.Graph, col string) bool {
+	for _, t := range g.Objects(col, vocab.HasDataType) {
+		name := strings.TrimPrefix(t.String(), vocab.DataTypePrefix)
+		for _, o := range orderedTypes {
+			if strings.HasPrefix(name, o) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// categorical makes a field usable as a colour legend.
+func categorical(def FieldDef) FieldDef {
+	if def.Type == "quantitative" || def.Type == "temporal" {
+		def.Type = "ordinal"
+		def.Scale = nil
+	}
+	return def
+}
+
+func withSort(def FieldDef, sort string) FieldDef {
+	if def.Type == "nominal" || def.Type == "ordinal" {
+		def.Sort = sort
+	}
+	return def
+}
+
+func withAggregate(def FieldDef, aggregate string) FieldDef {
+	def.Aggregate = aggregate
+	return def
+}
+
+// columns lists the bound columns once each, the usual channels first.
+func columns(rec recommend.Recommendation) []string {
+	channels := []string{"x", "y", "color", "group", "leaf", "size"}
+	others := []string{}
+	for channel := range rec.Bindings {
+		known := false
+		for _, c := range channels {
+			known = known || c == channel
+		}
+		if !known {
+			others = append(others, channel)
+		}
+	}
+	sort.Strings(others)
+	cols := []string{}
+	seen := map[string]bool{}
+	for _, channel := range append(channels, others...) {
+		if c := rec.Bindings[channel]; c != "" && !seen[c] {
+			seen[c] = true
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+func title(rec recommend.Recommendation) string {
+	b := rec.Bindings
+	switch rec.Chart {
+	case "bar", "scatter":
+		return b["y"] + " by " + b["x"]
+	case "line", "stackedBar":
+		return b["y"] + " by " + b["x"] + " and " + b["color"]
+	case "treemap":
+		if b["size"] != "" {
+			return b["size"] + " of " + b["leaf"] + " by " + b["group"]
+		}
+		return b["leaf"] + " by " + b["group"]
+	case "histogram":
+		return "distribution of " + b["x"]
+	}
+	return rec.Entity
+}
@@ -0,0 +1,50 @@
+package vegalite
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dooodle/vis-extractor/graph"
+	"github.com/dooodle/vis-extractor/recommend"
+)
+
+const extraction = `<http://dooodle/entity/pop/column/population> <http://dooodle/predicate/hasDimension> <http://dooodle/dimension/scalar> .
+<http://dooodle/entity/pop/column/population> <http://dooodle/predicate/logScaleRecommended> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+<http://dooodle/entity/pop/column/year> <http://dooodle/predicate/hasDimension> <http://dooodle/dimension/discrete> .
+<http://dooodle/entity/pop/column/year> <http://dooodle/predicate/hasDataType> <http://dooodle/dataType/int4> .
+<http://dooodle/entity/pop/column/country> <http://dooodle/predicate/hasDimension> <http://dooodle/dimension/discrete> .
+<http://dooodle/entity/pop/column/country> <http://dooodle/predicate/hasDataType> <http://dooodle/dataType/varchar> .
+<http://dooodle/entity/pop/column/population> <http://www.w3.org/2000/01/rdf-schema#comment> "Inhabitants" .
+`
+
+func TestGenerate(t *testing.T) {
+	g, err := graph.Load(strings.NewReader(extraction))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := recommend.Recommendation{
+		Chart:    "line",
+		Entity:   "pop",
+		Bindings: map[string]string{"x": "year", "color": "country", "y": "population"},
+	}
+	spec := Generate(g, rec, DataSource{})
+	b, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"$schema":"https://vega.github.io/schema/vega-lite/v4.json","title":"population by year and country",` +
+		`"data":{"name":"pop"},"mark":{"type":"line","tooltip":true},"encoding":{` +
+		`"color":{"field":"country","type":"nominal"},` +
+		`"x":{"field":"year","type":"ordinal"},` +
+		`"y":{"field":"population","type":"quantitative","scale":{"type":"log"},"title":"Inhabitants"}},` +
+		`"usermeta":{"query":"select year, population, country from pop"}}`
+	if string(b) != want {
+		t.Errorf("wanted %s\ngot    %s", want, b)
+	}
+
+	spec = Generate(g, rec, DataSource{URL: "http://data/{entity}.json"})
+	if spec.Data.URL != "http://data/pop.json" || spec.UserMeta != nil {
+		t.Errorf("wanted url data source got %+v", spec.Data)
+	}
+}
@@ -0,0 +1,156 @@
+// Package vocab holds the IRIs of the vocabulary written by the extractor so
+// that the packages reading an extraction back agree with the one writing it.
+package vocab
+
+import "strings"
+
+const (
+	Root            = "http://dooodle/"
+	EntityPrefix    = Root + "entity/"
+	PredicatePrefix = Root + "predicate/"
+	DataTypePrefix  = Root + "dataType/"
+	ColumnMiddle    = "/column/"
+
+	DiscreteDimension = Root + "dimension/discrete"
+	ScalarDimension   = Root + "dimension/scalar"
+	TemporalDimension = Root + "dimension/temporal"
+
+	TableKind            = Root + "kind/table"
+	ViewKind             = Root + "kind/view"
+	MaterializedViewKind = Root + "kind/materializedView"
+)
+
+// predicates
+const (
+	HasColumn       = PredicatePrefix + "hasColumn"
+	HasDataType     = PredicatePrefix + "hasDataType"
+	NumDistinct     = PredicatePrefix + "numDistinct"
+	HasDimension    = PredicatePrefix + "hasDimension"
+	HasKey          = PredicatePrefix + "hasKey"
+	HasSingleKey    = PredicatePrefix + "hasSingleKey"
+	HasCompoundKey  = PredicatePrefix + "hasCompoundKey"
+	HasStrongKey    = PredicatePrefix + "hasStrongKey"
+	HasWeakKey      = PredicatePrefix + "hasWeakKey"
+	HasOne2ManyKey  = PredicatePrefix + "hasOne2ManyKey"
+	HasMany2ManyKey = PredicatePrefix + "hasMany2ManyKey"
+	HasOneKey       = PredicatePrefix + "hasOneKey"
+	HasManyKey      = PredicatePrefix + "hasManyKey"
+
+	HasHistogram        = PredicatePrefix + "hasHistogram"
+	HasFrequentValue    = PredicatePrefix + "hasFrequentValue"
+	LogScaleRecommended = PredicatePrefix + "logScaleRecommended"
+	HasCorrelation      = PredicatePrefix + "hasCorrelation"
+	HasCorrelatedColumn = PredicatePrefix + "hasCorrelatedColumn"
+	Pearson             = PredicatePrefix + "pearson"
+	Spearman            = PredicatePrefix + "spearman"
+	HasHierarchy        = PredicatePrefix + "hasHierarchy"
+	HasCandidateKey     = PredicatePrefix + "hasCandidateKey"
+	HasKeyColumn        = PredicatePrefix + "hasKeyColumn"
+	HasEntityKind       = PredicatePrefix + "hasEntityKind"
+	DependsOn           = PredicatePrefix + "dependsOn"
+	PartitionStrategy   = PredicatePrefix + "partitionStrategy"
+	HasPartitionKey     = PredicatePrefix + "hasPartitionKey"
+	HasPartition        = PredicatePrefix + "hasPartition"
+	PartitionBound      = PredicatePrefix + "partitionBound"
+	IsOrdinal           = PredicatePrefix + "isOrdinal"
+	ArrayDimensions     = PredicatePrefix + "arrayDimensions"
+	HasTypeKind         = PredicatePrefix + "hasTypeKind"
+	HasLabel            = PredicatePrefix + "hasLabel"
+	LabelOrder          = PredicatePrefix + "labelOrder"
+	HasBaseType         = PredicatePrefix + "hasBaseType"
+	HasCheck            = PredicatePrefix + "hasCheck"
+	HasElementType      = PredicatePrefix + "hasElementType"
+	HasField            = PredicatePrefix + "hasField"
+	HasJSONPath         = PredicatePrefix + "hasJSONPath"
+	JSONPath            = PredicatePrefix + "jsonPath"
+	JSONValueType       = PredicatePrefix + "jsonValueType"
+	PathFrequency       = PredicatePrefix + "pathFrequency"
+	SampleSize          = PredicatePrefix + "sampleSize"
+	NotNull             = PredicatePrefix + "notNull"
+	HasDefault          = PredicatePrefix + "hasDefault"
+
+	Skewness           = PredicatePrefix + "skewness"
+	Kurtosis           = PredicatePrefix + "kurtosis"
+	InterquartileRange = PredicatePrefix + "interquartileRange"
+	LowerFence         = PredicatePrefix + "lowerFence"
+	UpperFence         = PredicatePrefix + "upperFence"
+	NumOutliers        = PredicatePrefix + "numOutliers"
+
+	HistogramKind = PredicatePrefix + "histogramKind"
+	NumBuckets    = PredicatePrefix + "numBuckets"
+	HasBucket     = PredicatePrefix + "hasBucket"
+	BucketIndex   = PredicatePrefix + "bucketIndex"
+	LowerBound    = PredicatePrefix + "lowerBound"
+	UpperBound    = PredicatePrefix + "upperBound"
+	Frequency     = PredicatePrefix + "frequency"
+	Value         = PredicatePrefix + "value"
+	Rank          = PredicatePrefix + "rank"
+
+	HasForeignKey       = PredicatePrefix + "hasForeignKey"
+	HasForeignKeyColumn = PredicatePrefix + "hasForeignKeyColumn"
+	ReferencesEntity    = PredicatePrefix + "referencesEntity"
+	ReferencesColumn    = PredicatePrefix + "referencesColumn"
+	IsDeclared          = PredicatePrefix + "isDeclared"
+
+	HasFunctionalDependency = PredicatePrefix + "hasFunctionalDependency"
+	HasDeterminant          = PredicatePrefix + "hasDeterminant"
+	HasDependent            = PredicatePrefix + "hasDependent"
+	FDError                 = PredicatePrefix + "fdError"
+
+	NumLevels   = PredicatePrefix + "numLevels"
+	HasLevel    = PredicatePrefix + "hasLevel"
+	LevelIndex  = PredicatePrefix + "levelIndex"
+	LevelColumn = PredicatePrefix + "levelColumn"
+
+	HasRecursiveRelationship = PredicatePrefix + "hasRecursiveRelationship"
+	HasReferencingColumn     = PredicatePrefix + "hasReferencingColumn"
+	HasReferencedColumn      = PredicatePrefix + "hasReferencedColumn"
+	MaxDepth                 = PredicatePrefix + "maxDepth"
+	RecursiveStructure       = PredicatePrefix + "recursiveStructure"
+
+	FieldName  = PredicatePrefix + "fieldName"
+	FieldIndex = PredicatePrefix + "fieldIndex"
+
+	// Comment holds the description of an entity or column.
+	Comment = "http://www.w3.org/2000/01/rdf-schema#comment"
+
+	// Unavailable points at a predicate that could not be computed for its
+	// subject because the source has no data, such as a schema dump, or the
+	// view was left out of profiling.
+	Unavailable = PredicatePrefix + "unavailable"
+)
+
+// EntityName returns the entity of an entity, column or relationship IRI,
+// or "" for IRIs outside the entity namespace.
+func EntityName(iri string) string {
+	if !strings.HasPrefix(iri, EntityPrefix) {
+		return ""
+	}
+	name := strings.TrimPrefix(iri, EntityPrefix)
+	if i := strings.Index(name, "/"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// ColumnName returns the column of a column IRI, or "" if iri is not one.
+func ColumnName(iri string) string {
+	if !strings.HasPrefix(iri, EntityPrefix) {
+		return ""
+	}
+	i := strings.Index(iri, ColumnMiddle)
+	if i < 0 {
+		return ""
+	}
+	return iri[i+len(ColumnMiddle):]
+}
+
+// Column returns the IRI of a column of an entity.
+func Column(entity string, col string) string {
+	return EntityPrefix + entity + ColumnMiddle + col
+}
+
+// Entity returns the IRI of an entity.
+func Entity(entity string) string {
+	return EntityPrefix + entity
+}